@@ -13,7 +13,9 @@ package appsec
 
 import (
 	"context"
+	"errors"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
 )
@@ -30,3 +32,41 @@ func MonitorParsedHTTPBody(ctx context.Context, body interface{}) {
 	}
 	// bonus: use sync.Once to log a debug message once if AppSec is disabled
 }
+
+// MonitorHTTPResponseBody runs the security monitoring rules on the given
+// JSON-encoded HTTP response body, in order to detect data leakage such as
+// stack traces or credit card numbers in the responses. The given context must
+// be the HTTP request context as returned by the Context() method of an HTTP
+// request. Bodies larger than 64KB or that are not valid JSON are ignored.
+// Calls to this function are ignored if AppSec is disabled or the given
+// context is incorrect.
+func MonitorHTTPResponseBody(ctx context.Context, body []byte) {
+	if appsec.Enabled() {
+		httpsec.MonitorResponseBody(ctx, body)
+	}
+}
+
+// ErrUserBlocked is the error returned by SetUser when the user must be
+// blocked. The request handler should then stop processing the request and
+// return as soon as possible, the HTTP middleware function writing the
+// blocked response when no response was written.
+var ErrUserBlocked = errors.New("appsec: user blocked")
+
+// SetUser wraps tracer.SetUser() and extends it with user blocking. It sets
+// the user information, along with the given tracer.UserMonitoringOption
+// options, on the service entry span found in the given context and, when
+// AppSec is enabled, runs the security monitoring rules on the user id. It
+// returns ErrUserBlocked when the user must be blocked. The given context must
+// be the HTTP request context as returned by the Context() method of an HTTP
+// request.
+func SetUser(ctx context.Context, id string, opts ...tracer.UserMonitoringOption) error {
+	span := getRootSpan(ctx)
+	if span == nil {
+		return nil
+	}
+	tracer.SetUser(span, id, opts...)
+	if appsec.Enabled() && httpsec.MonitorUser(ctx, id) {
+		return ErrUserBlocked
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package appsec
+
+import (
+	"context"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+// TrackUserLoginSuccessEvent sets a successful user login event, with the
+// given user id and optional metadata, as service entry span tags. It also
+// calls SetUser() to set the currently authenticated user, along with the
+// given tracer.UserMonitoringOption options, and returns its error when the
+// user must be blocked. The trace is forced to be kept so that the event
+// gets reported. The given context must be the request context the service
+// entry span belongs to.
+func TrackUserLoginSuccessEvent(ctx context.Context, uid string, md map[string]string, opts ...tracer.UserMonitoringOption) error {
+	span := getRootSpan(ctx)
+	if span == nil {
+		return nil
+	}
+	const tagPrefix = "appsec.events.users.login.success."
+	span.SetTag(tagPrefix+"track", true)
+	for k, v := range md {
+		span.SetTag(tagPrefix+k, v)
+	}
+	span.SetTag(ext.ManualKeep, samplernames.AppSec)
+	return SetUser(ctx, uid, opts...)
+}
+
+// TrackUserLoginFailureEvent sets a failed user login event, with the given
+// user id, whether the user exists, and optional metadata, as service entry
+// span tags. The trace is forced to be kept so that the event gets reported.
+// The given context must be the request context the service entry span
+// belongs to.
+func TrackUserLoginFailureEvent(ctx context.Context, uid string, exists bool, md map[string]string) {
+	span := getRootSpan(ctx)
+	if span == nil {
+		return
+	}
+	const tagPrefix = "appsec.events.users.login.failure."
+	span.SetTag(tagPrefix+"track", true)
+	span.SetTag(tagPrefix+"usr.id", uid)
+	span.SetTag(tagPrefix+"usr.exists", exists)
+	for k, v := range md {
+		span.SetTag(tagPrefix+k, v)
+	}
+	span.SetTag(ext.ManualKeep, samplernames.AppSec)
+}
+
+// TrackCustomEvent sets a custom event, with the given name and optional
+// metadata, as service entry span tags. The trace is forced to be kept so that
+// the event gets reported. The given context must be the request context the
+// service entry span belongs to.
+func TrackCustomEvent(ctx context.Context, name string, md map[string]string) {
+	span := getRootSpan(ctx)
+	if span == nil {
+		return
+	}
+	tagPrefix := "appsec.events." + name + "."
+	span.SetTag(tagPrefix+"track", true)
+	for k, v := range md {
+		span.SetTag(tagPrefix+k, v)
+	}
+	span.SetTag(ext.ManualKeep, samplernames.AppSec)
+}
+
+// getRootSpan returns the root span of the trace the span found in ctx
+// belongs to, or nil if ctx has no span.
+func getRootSpan(ctx context.Context) ddtrace.Span {
+	span, ok := tracer.SpanFromContext(ctx)
+	if !ok {
+		log.Error("appsec: could not find a span in the given context: the request handler is not being monitored by a middleware function or the provided context is not the expected request context")
+		return nil
+	}
+	if r, ok := span.(interface{ Root() ddtrace.Span }); ok {
+		return r.Root()
+	}
+	return span
+}
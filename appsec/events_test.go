@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package appsec_test
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackUserLoginSuccessEvent(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	root := tracer.StartSpan("root")
+	child, ctx := tracer.StartSpanFromContext(tracer.ContextWithSpan(context.Background(), root), "child")
+	err := appsec.TrackUserLoginSuccessEvent(ctx, "user id", map[string]string{"region": "us-east-1"}, tracer.WithUserName("username"))
+	require.NoError(t, err)
+	child.Finish()
+	root.Finish()
+
+	finished := mt.FinishedSpans()
+	require.Len(t, finished, 2)
+	span := finished[1]
+	require.Equal(t, "root", span.OperationName())
+	require.Equal(t, true, span.Tag("appsec.events.users.login.success.track"))
+	require.Equal(t, "us-east-1", span.Tag("appsec.events.users.login.success.region"))
+	require.Equal(t, "user id", span.Tag("usr.id"))
+	require.Equal(t, "username", span.Tag("usr.name"))
+	require.Equal(t, samplernames.AppSec, span.Tag(ext.ManualKeep))
+	require.Nil(t, finished[0].Tag("appsec.events.users.login.success.track"))
+}
+
+func TestTrackUserLoginFailureEvent(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	appsec.TrackUserLoginFailureEvent(ctx, "user id", false, map[string]string{"region": "us-east-1"})
+	span.Finish()
+
+	finished := mt.FinishedSpans()
+	require.Len(t, finished, 1)
+	require.Equal(t, true, finished[0].Tag("appsec.events.users.login.failure.track"))
+	require.Equal(t, "user id", finished[0].Tag("appsec.events.users.login.failure.usr.id"))
+	require.Equal(t, false, finished[0].Tag("appsec.events.users.login.failure.usr.exists"))
+	require.Equal(t, "us-east-1", finished[0].Tag("appsec.events.users.login.failure.region"))
+	require.Equal(t, samplernames.AppSec, finished[0].Tag(ext.ManualKeep))
+	require.Nil(t, finished[0].Tag("usr.id"))
+}
+
+func TestTrackCustomEvent(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	appsec.TrackCustomEvent(ctx, "my-event", map[string]string{"key": "value"})
+	span.Finish()
+
+	finished := mt.FinishedSpans()
+	require.Len(t, finished, 1)
+	require.Equal(t, true, finished[0].Tag("appsec.events.my-event.track"))
+	require.Equal(t, "value", finished[0].Tag("appsec.events.my-event.key"))
+	require.Equal(t, samplernames.AppSec, finished[0].Tag(ext.ManualKeep))
+}
+
+func TestSetUserNoSpan(t *testing.T) {
+	require.NoError(t, appsec.SetUser(context.Background(), "user id"))
+}
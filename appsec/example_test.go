@@ -60,3 +60,35 @@ func ExampleMonitorParsedHTTPBody_customContext() {
 
 	r.Start(":8080")
 }
+
+// Monitor and block users with the user monitoring SDK
+func ExampleSetUser() {
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// The user must be blocked when a non-nil error is returned, in
+		// which case the request handler must stop processing the request
+		if err := appsec.SetUser(r.Context(), "user-id"); err != nil {
+			return
+		}
+		w.Write([]byte("User monitored using AppSec SDK\n"))
+	})
+	http.ListenAndServe(":8080", mux)
+}
+
+// Track user login events with the user monitoring SDK
+func ExampleTrackUserLoginSuccessEvent() {
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		user, pass := r.FormValue("user"), r.FormValue("password")
+		if user != "admin" || pass != "admin" {
+			appsec.TrackUserLoginFailureEvent(r.Context(), user, user == "admin", nil)
+			http.Error(w, "login failure", http.StatusUnauthorized)
+			return
+		}
+		if err := appsec.TrackUserLoginSuccessEvent(r.Context(), user, map[string]string{"login.method": "password"}); err != nil {
+			return
+		}
+		w.Write([]byte("Logged in\n"))
+	})
+	http.ListenAndServe(":8080", mux)
+}
@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package gqlgen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
+)
+
+const appsecRules = `{
+  "version": "2.2",
+  "metadata": {"rules_version": "1.4.2"},
+  "rules": [
+    {
+      "id": "graphql-test",
+      "name": "GraphQL test rule",
+      "tags": {"type": "security_scanner", "category": "attack_attempt"},
+      "conditions": [
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "graphql.server.all_resolvers"}], "regex": "graphql-attack"}
+        }
+      ],
+      "transformers": []
+    }
+  ]
+}`
+
+func TestAppSecInterceptField(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(rules, []byte(appsecRules), 0644))
+	t.Setenv("DD_APPSEC_RULES", rules)
+	appsec.Start()
+	defer appsec.Stop()
+
+	if !appsec.Enabled() {
+		t.Skip("appsec disabled")
+	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available: these tests rely on the waf rules, the denylist fallback is tested by internal/appsec")
+	}
+
+	tracer := NewTracer().(*gqlTracer)
+	for _, tc := range []struct {
+		name   string
+		arg    string
+		attack bool
+	}{
+		{name: "no-attack", arg: "world"},
+		{name: "attack", arg: "graphql-attack", attack: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, op := graphqlsec.StartRequestOperation(context.Background(), graphqlsec.RequestOperationArgs{})
+			ctx = graphql.WithFieldContext(ctx, &graphql.FieldContext{
+				Object: "Query",
+				Field:  graphql.CollectedField{Field: &ast.Field{Name: "hello"}},
+				Args:   map[string]interface{}{"name": tc.arg},
+			})
+			res, err := tracer.InterceptField(ctx, func(context.Context) (interface{}, error) {
+				return "Hello", nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, "Hello", res)
+
+			events := op.Finish(graphqlsec.RequestOperationRes{})
+			if !tc.attack {
+				require.Empty(t, events)
+				return
+			}
+			require.Len(t, events, 1)
+			require.Contains(t, string(events[0]), "graphql-test")
+		})
+	}
+}
@@ -52,6 +52,9 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
 )
 
 const (
@@ -119,7 +122,27 @@ func (t *gqlTracer) InterceptResponse(ctx context.Context, next graphql.Response
 	}
 	var span ddtrace.Span
 	span, ctx = tracer.StartSpanFromContext(ctx, name, opts...)
+	var op *graphqlsec.RequestOperation
+	if appsec.Enabled() {
+		instrumentation.SetAppSecEnabledTags(span)
+		var args graphqlsec.RequestOperationArgs
+		if octx != nil {
+			args = graphqlsec.RequestOperationArgs{
+				RawQuery:      octx.RawQuery,
+				OperationName: octx.OperationName,
+				Variables:     octx.Variables,
+			}
+		}
+		ctx, op = graphqlsec.StartRequestOperation(ctx, args)
+	}
 	defer func() {
+		if op != nil {
+			events := op.Finish(graphqlsec.RequestOperationRes{})
+			instrumentation.SetTags(span, op.Tags())
+			if len(events) > 0 {
+				graphqlsec.SetSecurityEventTags(span, events)
+			}
+		}
 		var errs []string
 		for _, err := range graphql.GetErrors(ctx) {
 			errs = append(errs, err.Message)
@@ -149,8 +172,30 @@ func (t *gqlTracer) InterceptResponse(ctx context.Context, next graphql.Response
 	return next(ctx)
 }
 
+// InterceptField monitors the resolved field arguments with AppSec when it is
+// enabled.
+func (t *gqlTracer) InterceptField(ctx context.Context, next graphql.Resolver) (res interface{}, err error) {
+	if !appsec.Enabled() {
+		return next(ctx)
+	}
+	fctx := graphql.GetFieldContext(ctx)
+	if fctx == nil || fctx.Field.Field == nil {
+		return next(ctx)
+	}
+	op := graphqlsec.StartResolveOperation(ctx, graphqlsec.ResolveOperationArgs{
+		TypeName:  fctx.Object,
+		FieldName: fctx.Field.Name,
+		Arguments: fctx.Args,
+	})
+	if op != nil {
+		defer op.Finish(graphqlsec.ResolveOperationRes{})
+	}
+	return next(ctx)
+}
+
 // Ensure all of these interfaces are implemented.
 var _ interface {
 	graphql.HandlerExtension
 	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
 } = &gqlTracer{}
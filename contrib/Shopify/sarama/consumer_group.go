@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sarama
+
+import (
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/Shopify/sarama"
+)
+
+type consumerGroupHandler struct {
+	sarama.ConsumerGroupHandler
+	cfg *config
+}
+
+// WrapConsumerGroupHandler wraps a sarama.ConsumerGroupHandler causing each
+// message of the claims it consumes to be traced. The span of a message is
+// finished when the next message of the claim is fetched, or when the claim
+// consumption ends. Use WithGroupID to tag the spans with the consumer group.
+func WrapConsumerGroupHandler(handler sarama.ConsumerGroupHandler, opts ...Option) sarama.ConsumerGroupHandler {
+	cfg := new(config)
+	defaults(cfg)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	log.Debug("contrib/Shopify/sarama: Wrapping Consumer Group Handler: %#v", cfg)
+	return &consumerGroupHandler{
+		ConsumerGroupHandler: handler,
+		cfg:                  cfg,
+	}
+}
+
+// ConsumeClaim calls the wrapped handler ConsumeClaim with a claim whose
+// messages are traced.
+func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	wrapped := &consumerGroupClaim{
+		ConsumerGroupClaim: claim,
+		messages:           make(chan *sarama.ConsumerMessage),
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(wrapped.messages)
+		var prev ddtrace.Span
+		// finish any remaining span
+		defer func() {
+			if prev != nil {
+				prev.Finish()
+			}
+		}()
+		msgs := claim.Messages()
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					// the session ended
+					return
+				}
+				next := startConsumeSpan(h.cfg, msg, tracer.Tag("member_id", session.MemberID()))
+				select {
+				case wrapped.messages <- msg:
+				case <-done:
+					// the handler stopped consuming the claim
+					next.Finish()
+					return
+				}
+				// if the next message was received, finish the previous span
+				if prev != nil {
+					prev.Finish()
+				}
+				prev = next
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := h.ConsumerGroupHandler.ConsumeClaim(session, wrapped)
+	close(done)
+	wg.Wait()
+	return err
+}
+
+type consumerGroupClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+// Messages returns the read channel for the messages of the claim.
+func (c *consumerGroupClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
@@ -6,6 +6,7 @@
 package sarama_test
 
 import (
+	"context"
 	"log"
 
 	"github.com/Shopify/sarama"
@@ -82,3 +83,33 @@ func Example_consumer() {
 		consumed++
 	}
 }
+
+type exampleHandler struct{}
+
+func (exampleHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (exampleHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+func (exampleHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		log.Printf("Consumed message offset %d\n", msg.Offset)
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func Example_consumerGroup() {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V0_11_0_0 // minimum version that supports headers which are required for tracing
+
+	group, err := sarama.NewConsumerGroup([]string{"localhost:9092"}, "some-group", cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer group.Close()
+
+	handler := saramatrace.WrapConsumerGroupHandler(exampleHandler{}, saramatrace.WithGroupID("some-group"))
+	for {
+		if err := group.Consume(context.Background(), []string{"some-topic"}, handler); err != nil {
+			panic(err)
+		}
+	}
+}
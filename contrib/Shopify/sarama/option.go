@@ -16,6 +16,7 @@ type config struct {
 	consumerServiceName string
 	producerServiceName string
 	analyticsRate       float64
+	groupID             string
 }
 
 func defaults(cfg *config) {
@@ -43,6 +44,15 @@ func WithServiceName(name string) Option {
 	}
 }
 
+// WithGroupID sets the consumer group the consumed messages are tagged with.
+// It should be the group ID given to sarama.NewConsumerGroup when tracing a
+// ConsumerGroupHandler.
+func WithGroupID(groupID string) Option {
+	return func(cfg *config) {
+		cfg.groupID = groupID
+	}
+}
+
 // WithAnalytics enables Trace Analytics for all started spans.
 func WithAnalytics(on bool) Option {
 	return func(cfg *config) {
@@ -7,11 +7,14 @@
 package sarama // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/Shopify/sarama"
 
 import (
+	"context"
 	"math"
+	"strconv"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
 	"github.com/Shopify/sarama"
@@ -46,27 +49,7 @@ func WrapPartitionConsumer(pc sarama.PartitionConsumer, opts ...Option) sarama.P
 		var prev ddtrace.Span
 		for msg := range msgs {
 			// create the next span from the message
-			opts := []tracer.StartSpanOption{
-				tracer.ServiceName(cfg.consumerServiceName),
-				tracer.ResourceName("Consume Topic " + msg.Topic),
-				tracer.SpanType(ext.SpanTypeMessageConsumer),
-				tracer.Tag("partition", msg.Partition),
-				tracer.Tag("offset", msg.Offset),
-				tracer.Tag(ext.Component, "Shopify/sarama"),
-				tracer.Tag(ext.SpanKind, ext.SpanKindConsumer),
-				tracer.Measured(),
-			}
-			if !math.IsNaN(cfg.analyticsRate) {
-				opts = append(opts, tracer.Tag(ext.EventSampleRate, cfg.analyticsRate))
-			}
-			// kafka supports headers, so try to extract a span context
-			carrier := NewConsumerMessageCarrier(msg)
-			if spanctx, err := tracer.Extract(carrier); err == nil {
-				opts = append(opts, tracer.ChildOf(spanctx))
-			}
-			next := tracer.StartSpan("kafka.consume", opts...)
-			// reinject the span context so consumers can pick it up
-			tracer.Inject(next.Context(), carrier)
+			next := startConsumeSpan(cfg, msg)
 
 			wrapped.messages <- msg
 
@@ -85,6 +68,37 @@ func WrapPartitionConsumer(pc sarama.PartitionConsumer, opts ...Option) sarama.P
 	return wrapped
 }
 
+// startConsumeSpan starts the span of the consumed message, child of the span
+// context propagated in its headers, and re-injects it into the headers.
+func startConsumeSpan(cfg *config, msg *sarama.ConsumerMessage, opts ...tracer.StartSpanOption) ddtrace.Span {
+	opts = append([]tracer.StartSpanOption{
+		tracer.ServiceName(cfg.consumerServiceName),
+		tracer.ResourceName("Consume Topic " + msg.Topic),
+		tracer.SpanType(ext.SpanTypeMessageConsumer),
+		tracer.Tag("partition", msg.Partition),
+		tracer.Tag("offset", msg.Offset),
+		tracer.Tag(ext.Component, "Shopify/sarama"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindConsumer),
+		tracer.Measured(),
+	}, opts...)
+	if cfg.groupID != "" {
+		opts = append(opts, tracer.Tag("group_id", cfg.groupID))
+	}
+	if !math.IsNaN(cfg.analyticsRate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, cfg.analyticsRate))
+	}
+	// kafka supports headers, so try to extract a span context
+	carrier := NewConsumerMessageCarrier(msg)
+	if spanctx, err := tracer.Extract(carrier); err == nil {
+		opts = append(opts, tracer.ChildOf(spanctx))
+	}
+	span := tracer.StartSpan("kafka.consume", opts...)
+	// reinject the span context so consumers can pick it up
+	tracer.Inject(span.Context(), carrier)
+	setConsumeCheckpoint(cfg.groupID, msg)
+	return span
+}
+
 type consumer struct {
 	sarama.Consumer
 	opts []Option
@@ -274,6 +288,7 @@ func startProducerSpan(cfg *config, version sarama.KafkaVersion, msg *sarama.Pro
 	if version.IsAtLeast(sarama.V0_11_0_0) {
 		// re-inject the span context so consumers can pick it up
 		tracer.Inject(span.Context(), carrier)
+		setProduceCheckpoint(msg)
 	}
 	return span
 }
@@ -293,3 +308,67 @@ func getSpanContext(msg *sarama.ProducerMessage) (ddtrace.SpanContext, bool) {
 
 	return spanctx, true
 }
+
+// setProduceCheckpoint sets a data streams checkpoint on the pathway of the
+// produced message, see InjectPathway, or on a new pathway when it carries
+// none, and propagates it in the message headers.
+func setProduceCheckpoint(msg *sarama.ProducerMessage) {
+	if !datastreams.Enabled() {
+		return
+	}
+	edges := []string{"direction:out", "topic:" + msg.Topic, "type:kafka"}
+	carrier := NewProducerMessageCarrier(msg)
+	p, ok := datastreams.Extract(carrier)
+	if ok {
+		p = p.SetCheckpoint(edges...)
+	} else {
+		p = datastreams.NewPathway(edges...)
+	}
+	datastreams.Inject(p, carrier)
+}
+
+// setConsumeCheckpoint sets a data streams checkpoint on the pathway of the
+// consumed message, and re-injects it so that the messages produced while
+// handling it can continue the pathway, see ContextWithPathway.
+func setConsumeCheckpoint(groupID string, msg *sarama.ConsumerMessage) {
+	if !datastreams.Enabled() {
+		return
+	}
+	edges := []string{"direction:in"}
+	if groupID != "" {
+		edges = append(edges, "group:"+groupID)
+	}
+	edges = append(edges, "partition:"+strconv.Itoa(int(msg.Partition)), "topic:"+msg.Topic, "type:kafka")
+	carrier := NewConsumerMessageCarrier(msg)
+	p, ok := datastreams.Extract(carrier)
+	if ok {
+		p = p.SetCheckpoint(edges...)
+	} else {
+		p = datastreams.NewPathway(edges...)
+	}
+	datastreams.Inject(p, carrier)
+}
+
+// ContextWithPathway returns a copy of ctx carrying the data streams pathway of
+// the consumed message msg. Passing the returned context to InjectPathway
+// makes the messages produced while handling msg continue its pathway, so that
+// the latency of pipelines spanning several topics can be measured end to end.
+func ContextWithPathway(ctx context.Context, msg *sarama.ConsumerMessage) context.Context {
+	if p, ok := datastreams.Extract(NewConsumerMessageCarrier(msg)); ok {
+		return datastreams.ContextWithPathway(ctx, p)
+	}
+	return ctx
+}
+
+// InjectPathway injects the data streams pathway carried by ctx, as returned by
+// ContextWithPathway, into the headers of the message msg to be produced, so
+// that the producer continues it. It does nothing when ctx carries no pathway
+// or when Data Streams Monitoring is disabled.
+func InjectPathway(ctx context.Context, msg *sarama.ProducerMessage) {
+	if !datastreams.Enabled() {
+		return
+	}
+	if p, ok := datastreams.PathwayFromContext(ctx); ok {
+		datastreams.Inject(p, NewProducerMessageCarrier(msg))
+	}
+}
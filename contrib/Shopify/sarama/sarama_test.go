@@ -7,15 +7,19 @@ package sarama
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
 
 	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConsumer(t *testing.T) {
@@ -316,3 +320,173 @@ func waitForSpans(mt mocktracer.Tracer, sz int, maxWait time.Duration) {
 		time.Sleep(time.Millisecond * 100)
 	}
 }
+
+func TestDataStreamsCheckpoints(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		msg := &sarama.ProducerMessage{Topic: "my_topic"}
+		setProduceCheckpoint(msg)
+		assert.Len(t, msg.Headers, 0)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer srv.Close()
+		p := datastreams.NewProcessor("service", "env", srv.URL, nil)
+		p.Start()
+		defer p.Stop()
+
+		msg := &sarama.ProducerMessage{Topic: "my_topic"}
+		setProduceCheckpoint(msg)
+		produced, ok := datastreams.Extract(NewProducerMessageCarrier(msg))
+		require.True(t, ok)
+
+		consumed := &sarama.ConsumerMessage{
+			Topic:     "my_topic",
+			Partition: 1,
+			Headers:   []*sarama.RecordHeader{&msg.Headers[0]},
+		}
+		setConsumeCheckpoint("", consumed)
+		require.Len(t, consumed.Headers, 1)
+		pathway, ok := datastreams.Extract(NewConsumerMessageCarrier(consumed))
+		require.True(t, ok)
+		assert.NotEqual(t, produced.Hash(), pathway.Hash())
+		assert.Equal(t, produced.PathwayStart(), pathway.PathwayStart())
+	})
+}
+
+func TestDataStreamsPathwayChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	p := datastreams.NewProcessor("service", "env", srv.URL, nil)
+	p.Start()
+	defer p.Stop()
+
+	// consume is the consumer side of a hop, passing the pathway of the
+	// consumed message to the message produced while handling it.
+	consume := func(produced *sarama.ProducerMessage) *sarama.ConsumerMessage {
+		msg := &sarama.ConsumerMessage{Topic: produced.Topic, Partition: 1}
+		for i := range produced.Headers {
+			msg.Headers = append(msg.Headers, &produced.Headers[i])
+		}
+		setConsumeCheckpoint("group", msg)
+		return msg
+	}
+
+	first := &sarama.ProducerMessage{Topic: "topic_a"}
+	setProduceCheckpoint(first)
+	start, ok := datastreams.Extract(NewProducerMessageCarrier(first))
+	require.True(t, ok)
+
+	ctx := ContextWithPathway(context.Background(), consume(first))
+	second := &sarama.ProducerMessage{Topic: "topic_b"}
+	InjectPathway(ctx, second)
+	setProduceCheckpoint(second)
+	end, ok := datastreams.Extract(NewConsumerMessageCarrier(consume(second)))
+	require.True(t, ok)
+
+	want := start.
+		SetCheckpoint("direction:in", "group:group", "partition:1", "topic:topic_a", "type:kafka").
+		SetCheckpoint("direction:out", "topic:topic_b", "type:kafka").
+		SetCheckpoint("direction:in", "group:group", "partition:1", "topic:topic_b", "type:kafka")
+	assert.Equal(t, want.Hash(), end.Hash())
+	assert.Equal(t, start.PathwayStart(), end.PathwayStart())
+}
+
+type testConsumerGroupSession struct {
+	sarama.ConsumerGroupSession
+}
+
+func (testConsumerGroupSession) MemberID() string { return "member-1" }
+
+type testConsumerGroupClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c testConsumerGroupClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+type testConsumerGroupHandler struct {
+	sarama.ConsumerGroupHandler
+	consume func(msg *sarama.ConsumerMessage) bool
+}
+
+func (h testConsumerGroupHandler) ConsumeClaim(_ sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		if !h.consume(msg) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestConsumerGroupHandler(t *testing.T) {
+	t.Run("session-end", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		// propagate a producer span context in the first message headers
+		producer := tracer.StartSpan("kafka.produce")
+		msg1 := &sarama.ConsumerMessage{Topic: "test-topic", Partition: 1, Offset: 1}
+		tracer.Inject(producer.Context(), NewConsumerMessageCarrier(msg1))
+		producer.Finish()
+		msg2 := &sarama.ConsumerMessage{Topic: "test-topic", Partition: 1, Offset: 2}
+
+		claim := testConsumerGroupClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+		claim.messages <- msg1
+		claim.messages <- msg2
+		close(claim.messages)
+
+		var consumed []*sarama.ConsumerMessage
+		handler := WrapConsumerGroupHandler(testConsumerGroupHandler{
+			consume: func(msg *sarama.ConsumerMessage) bool {
+				consumed = append(consumed, msg)
+				return true
+			},
+		}, WithGroupID("group-1"))
+		require.NoError(t, handler.ConsumeClaim(testConsumerGroupSession{}, claim))
+		assert.Equal(t, []*sarama.ConsumerMessage{msg1, msg2}, consumed)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 3)
+		for i, s := range spans[1:] {
+			assert.Equal(t, "kafka.consume", s.OperationName())
+			assert.Equal(t, "Consume Topic test-topic", s.Tag(ext.ResourceName))
+			assert.Equal(t, "group-1", s.Tag("group_id"))
+			assert.Equal(t, "member-1", s.Tag("member_id"))
+			assert.Equal(t, int32(1), s.Tag("partition"))
+			assert.Equal(t, int64(i+1), s.Tag("offset"))
+			assert.Equal(t, "Shopify/sarama", s.Tag(ext.Component))
+			assert.Equal(t, ext.SpanKindConsumer, s.Tag(ext.SpanKind))
+
+			spanctx, err := tracer.Extract(NewConsumerMessageCarrier(consumed[i]))
+			require.NoError(t, err)
+			assert.Equal(t, s.SpanID(), spanctx.SpanID(),
+				"span context should be injected into the consumer message headers")
+		}
+		assert.Equal(t, producer.Context().TraceID(), spans[1].TraceID())
+		assert.Equal(t, producer.Context().SpanID(), spans[1].ParentID())
+	})
+
+	t.Run("handler-return", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		claim := testConsumerGroupClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+		claim.messages <- &sarama.ConsumerMessage{Topic: "test-topic", Offset: 1}
+		claim.messages <- &sarama.ConsumerMessage{Topic: "test-topic", Offset: 2}
+
+		handler := WrapConsumerGroupHandler(testConsumerGroupHandler{
+			consume: func(*sarama.ConsumerMessage) bool { return false },
+		})
+		require.NoError(t, handler.ConsumeClaim(testConsumerGroupSession{}, claim))
+
+		// all the started spans are finished when the handler stops consuming
+		assert.Len(t, mt.OpenSpans(), 0)
+		spans := mt.FinishedSpans()
+		require.NotEmpty(t, spans)
+		for _, s := range spans {
+			assert.Equal(t, "kafka.consume", s.OperationName())
+			assert.Nil(t, s.Tag("group_id"))
+		}
+	})
+}
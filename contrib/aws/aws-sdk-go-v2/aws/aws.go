@@ -11,6 +11,7 @@ import (
 	"math"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/internal/tags"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -43,6 +44,9 @@ func AppendMiddleware(awsCfg *aws.Config, opts ...Option) {
 
 	tm := traceMiddleware{cfg: cfg}
 	awsCfg.APIOptions = append(awsCfg.APIOptions, tm.initTraceMiddleware, tm.startTraceMiddleware, tm.deserializeTraceMiddleware)
+	if cfg.injectMessageAttributes {
+		awsCfg.APIOptions = append(awsCfg.APIOptions, tm.injectTraceMiddleware)
+	}
 }
 
 type traceMiddleware struct {
@@ -81,6 +85,9 @@ func (mw *traceMiddleware) startTraceMiddleware(stack *middleware.Stack) error {
 			tracer.Tag(ext.Component, "aws/aws-sdk-go-v2/aws"),
 			tracer.Tag(ext.SpanKind, ext.SpanKindClient),
 		}
+		for k, v := range tags.Tags(serviceID, in.Parameters) {
+			opts = append(opts, tracer.Tag(k, v))
+		}
 		if !math.IsNaN(mw.cfg.analyticsRate) {
 			opts = append(opts, tracer.Tag(ext.EventSampleRate, mw.cfg.analyticsRate))
 		}
@@ -94,6 +101,21 @@ func (mw *traceMiddleware) startTraceMiddleware(stack *middleware.Stack) error {
 	}), middleware.After)
 }
 
+func (mw *traceMiddleware) injectTraceMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("InjectTraceMiddleware", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (
+		out middleware.InitializeOutput, metadata middleware.Metadata, err error,
+	) {
+		// Inject the span context into the message attributes, and request them
+		// when receiving messages.
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			in.Parameters = injectMessageAttributes(in.Parameters, span.Context())
+		}
+		return next.HandleInitialize(ctx, in)
+	}), middleware.After)
+}
+
 func (mw *traceMiddleware) deserializeTraceMiddleware(stack *middleware.Stack) error {
 	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("DeserializeTraceMiddleware", func(
 		ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler,
@@ -9,6 +9,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awscfg "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 
@@ -26,3 +27,29 @@ func Example() {
 	sqsClient := sqs.NewFromConfig(awsCfg)
 	sqsClient.ListQueues(context.Background(), &sqs.ListQueuesInput{})
 }
+
+// To propagate the traces through SQS queues, enable the injection of the span
+// context into the message attributes, and start the spans processing the
+// received messages with awstrace.StartSQSMessageSpan.
+func ExampleStartSQSMessageSpan() {
+	awsCfg, err := awscfg.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	awstrace.AppendMiddleware(&awsCfg, awstrace.WithMessageAttributesInjection(true))
+
+	sqsClient := sqs.NewFromConfig(awsCfg)
+	out, err := sqsClient.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{
+		QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/123456789012/queue"),
+	})
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	for _, msg := range out.Messages {
+		span, ctx := awstrace.StartSQSMessageSpan(context.Background(), msg)
+		// process the message using ctx...
+		_ = ctx
+		span.Finish()
+	}
+}
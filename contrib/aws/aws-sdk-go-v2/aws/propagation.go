@@ -0,0 +1,214 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// datadogAttributeKey is the message attribute carrying the span context.
+	datadogAttributeKey = "_datadog"
+	// maxMessageAttributes is the maximum number of message attributes of
+	// an SQS message or an SNS notification delivered to SQS.
+	maxMessageAttributes = 10
+
+	tagSQSMessageID = "aws.sqs.message_id"
+)
+
+// errNoSpanContext is returned when a message carries no span context.
+var errNoSpanContext = errors.New("no span context in the message attributes")
+
+// encodeSpanContext returns the JSON encoding of the span context, as carried by
+// the _datadog message attribute.
+func encodeSpanContext(spanctx ddtrace.SpanContext) (string, bool) {
+	carrier := tracer.TextMapCarrier{}
+	if err := tracer.Inject(spanctx, carrier); err != nil {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: failed to inject the span context: %v", err)
+		return "", false
+	}
+	data, err := json.Marshal(carrier)
+	if err != nil {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: failed to encode the span context: %v", err)
+		return "", false
+	}
+	return string(data), true
+}
+
+// decodeSpanContext extracts the span context from its JSON encoding.
+func decodeSpanContext(data []byte) (ddtrace.SpanContext, error) {
+	carrier := tracer.TextMapCarrier{}
+	if err := json.Unmarshal(data, &carrier); err != nil {
+		return nil, err
+	}
+	return tracer.Extract(carrier)
+}
+
+// injectMessageAttributes returns a copy of the params of a request sending
+// messages, with the span context injected into the message attributes of
+// every message having room for it. The params of a request receiving messages
+// are returned with the _datadog message attribute requested.
+func injectMessageAttributes(params interface{}, spanctx ddtrace.SpanContext) interface{} {
+	if params, ok := params.(*sqs.ReceiveMessageInput); ok {
+		return withDatadogAttributeName(params)
+	}
+	switch params.(type) {
+	case *sqs.SendMessageInput, *sqs.SendMessageBatchInput, *sns.PublishInput:
+	default:
+		return params
+	}
+	value, ok := encodeSpanContext(spanctx)
+	if !ok {
+		return params
+	}
+	switch params := params.(type) {
+	case *sqs.SendMessageInput:
+		in := *params
+		in.MessageAttributes = injectSQSAttribute(in.MessageAttributes, value)
+		return &in
+	case *sqs.SendMessageBatchInput:
+		in := *params
+		in.Entries = make([]sqstypes.SendMessageBatchRequestEntry, len(params.Entries))
+		for i, entry := range params.Entries {
+			entry.MessageAttributes = injectSQSAttribute(entry.MessageAttributes, value)
+			in.Entries[i] = entry
+		}
+		return &in
+	case *sns.PublishInput:
+		in := *params
+		in.MessageAttributes = injectSNSAttribute(in.MessageAttributes, value)
+		return &in
+	}
+	return params
+}
+
+// injectSQSAttribute returns a copy of attrs with the _datadog attribute set to
+// value, or attrs when there's no room left for it.
+func injectSQSAttribute(attrs map[string]sqstypes.MessageAttributeValue, value string) map[string]sqstypes.MessageAttributeValue {
+	if _, ok := attrs[datadogAttributeKey]; !ok && len(attrs) >= maxMessageAttributes {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: the message has too many attributes to inject the span context")
+		return attrs
+	}
+	injected := make(map[string]sqstypes.MessageAttributeValue, len(attrs)+1)
+	for k, v := range attrs {
+		injected[k] = v
+	}
+	injected[datadogAttributeKey] = sqstypes.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+	return injected
+}
+
+// injectSNSAttribute returns a copy of attrs with the _datadog attribute set to
+// value, or attrs when there's no room left for it.
+func injectSNSAttribute(attrs map[string]snstypes.MessageAttributeValue, value string) map[string]snstypes.MessageAttributeValue {
+	if _, ok := attrs[datadogAttributeKey]; !ok && len(attrs) >= maxMessageAttributes {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: the notification has too many attributes to inject the span context")
+		return attrs
+	}
+	injected := make(map[string]snstypes.MessageAttributeValue, len(attrs)+1)
+	for k, v := range attrs {
+		injected[k] = v
+	}
+	injected[datadogAttributeKey] = snstypes.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+	return injected
+}
+
+// withDatadogAttributeName returns a copy of the params of a ReceiveMessage
+// request also requesting the _datadog message attribute.
+func withDatadogAttributeName(params *sqs.ReceiveMessageInput) *sqs.ReceiveMessageInput {
+	for _, name := range params.MessageAttributeNames {
+		switch name {
+		case datadogAttributeKey, "All", ".*":
+			return params
+		}
+	}
+	in := *params
+	in.MessageAttributeNames = append(append([]string(nil), params.MessageAttributeNames...), datadogAttributeKey)
+	return &in
+}
+
+// snsNotification is the SQS message body of an SNS notification delivered
+// without raw message delivery.
+type snsNotification struct {
+	Type              string
+	MessageAttributes map[string]struct {
+		Type  string
+		Value string
+	}
+}
+
+// ExtractSQSMessageSpanContext extracts the span context injected into the
+// _datadog message attribute of the received SQS message. The attribute is
+// also looked up in the body of the SNS notifications delivered to SQS without
+// raw message delivery.
+func ExtractSQSMessageSpanContext(msg sqstypes.Message) (ddtrace.SpanContext, error) {
+	if attr, ok := msg.MessageAttributes[datadogAttributeKey]; ok {
+		if attr.StringValue != nil {
+			return decodeSpanContext([]byte(*attr.StringValue))
+		}
+		return decodeSpanContext(attr.BinaryValue)
+	}
+	var n snsNotification
+	if err := json.Unmarshal([]byte(aws.ToString(msg.Body)), &n); err == nil && n.Type == "Notification" {
+		if attr, ok := n.MessageAttributes[datadogAttributeKey]; ok {
+			return decodeSpanContext([]byte(attr.Value))
+		}
+	}
+	return nil, errNoSpanContext
+}
+
+// StartSQSMessageSpan starts a span processing the received SQS message, child
+// of the span context injected into its message attributes if any, and returns
+// it along with a copy of ctx carrying it. Any span started from the returned
+// context is part of the trace of the message producer. The span must be
+// finished by the caller once the message is processed.
+func StartSQSMessageSpan(ctx context.Context, msg sqstypes.Message, opts ...Option) (ddtrace.Span, context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cfg := &config{}
+	defaults(cfg)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	spanOpts := []ddtrace.StartSpanOption{
+		tracer.SpanType(ext.SpanTypeMessageConsumer),
+		tracer.ServiceName(serviceName(cfg, "SQS")),
+		tracer.ResourceName("SQS.ReceiveMessage"),
+		tracer.Tag(tagSQSMessageID, aws.ToString(msg.MessageId)),
+		tracer.Tag(ext.Component, "aws/aws-sdk-go-v2/aws"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindConsumer),
+		tracer.Measured(),
+	}
+	if !math.IsNaN(cfg.analyticsRate) {
+		spanOpts = append(spanOpts, tracer.Tag(ext.EventSampleRate, cfg.analyticsRate))
+	}
+	if spanctx, err := ExtractSQSMessageSpanContext(msg); err == nil {
+		// the message producer takes precedence over any parent in ctx
+		span := tracer.StartSpan("SQS.process", append(spanOpts, tracer.ChildOf(spanctx))...)
+		return span, tracer.ContextWithSpan(ctx, span)
+	}
+	return tracer.StartSpanFromContext(ctx, "SQS.process", spanOpts...)
+}
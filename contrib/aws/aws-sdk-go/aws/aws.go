@@ -7,9 +7,11 @@
 package aws // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/aws-sdk-go/aws"
 
 import (
+	"context"
 	"math"
 	"strconv"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/internal/tags"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -17,6 +19,8 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
 )
 
 const (
@@ -29,8 +33,14 @@ const (
 	SendHandlerName = "gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/aws-sdk-go/aws/handlers.Send"
 	// CompleteHandlerName is the name of the Datadog NamedHandler for the Complete phase of an awsv1 request
 	CompleteHandlerName = "gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/aws-sdk-go/aws/handlers.Complete"
+	// BuildHandlerName is the name of the Datadog NamedHandler for the Build phase of an awsv1 request
+	BuildHandlerName = "gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/aws-sdk-go/aws/handlers.Build"
 )
 
+// spanStartedKey is the context key marking the requests whose span was
+// started before the Send phase.
+type spanStartedKey struct{}
+
 type handlers struct {
 	cfg *config
 }
@@ -45,6 +55,12 @@ func WrapSession(s *session.Session, opts ...Option) *session.Session {
 	log.Debug("contrib/aws/aws-sdk-go/aws: Wrapping Session: %#v", cfg)
 	h := &handlers{cfg: cfg}
 	s = s.Copy()
+	if cfg.injectMessageAttributes {
+		s.Handlers.Build.PushFrontNamed(request.NamedHandler{
+			Name: BuildHandlerName,
+			Fn:   h.Build,
+		})
+	}
 	s.Handlers.Send.PushFrontNamed(request.NamedHandler{
 		Name: SendHandlerName,
 		Fn:   h.Send,
@@ -56,10 +72,31 @@ func WrapSession(s *session.Session, opts ...Option) *session.Session {
 	return s
 }
 
+// Build starts the span of the requests sending messages, so that its context
+// can be injected into the message attributes before they get serialized, and
+// requests the message attribute carrying it when receiving messages.
+func (h *handlers) Build(req *request.Request) {
+	switch params := req.Params.(type) {
+	case *sqs.ReceiveMessageInput:
+		req.Params = withDatadogAttributeName(params)
+	case *sqs.SendMessageInput, *sqs.SendMessageBatchInput, *sns.PublishInput:
+		span := h.startSpan(req)
+		req.SetContext(context.WithValue(req.Context(), spanStartedKey{}, true))
+		req.Params = injectMessageAttributes(params, span.Context())
+	}
+}
+
 func (h *handlers) Send(req *request.Request) {
 	if req.RetryCount != 0 {
 		return
 	}
+	if started, _ := req.Context().Value(spanStartedKey{}).(bool); started {
+		return
+	}
+	h.startSpan(req)
+}
+
+func (h *handlers) startSpan(req *request.Request) ddtrace.Span {
 	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeHTTP),
 		tracer.ServiceName(h.serviceName(req)),
@@ -72,11 +109,15 @@ func (h *handlers) Send(req *request.Request) {
 		tracer.Tag(ext.Component, "aws/aws-sdk-go/aws"),
 		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
 	}
+	for k, v := range tags.Tags(h.awsService(req), req.Params) {
+		opts = append(opts, tracer.Tag(k, v))
+	}
 	if !math.IsNaN(h.cfg.analyticsRate) {
 		opts = append(opts, tracer.Tag(ext.EventSampleRate, h.cfg.analyticsRate))
 	}
-	_, ctx := tracer.StartSpanFromContext(req.Context(), h.operationName(req), opts...)
+	span, ctx := tracer.StartSpanFromContext(req.Context(), h.operationName(req), opts...)
 	req.SetContext(ctx)
+	return span
 }
 
 func (h *handlers) Complete(req *request.Request) {
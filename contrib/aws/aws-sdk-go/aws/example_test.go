@@ -6,9 +6,12 @@
 package aws_test
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
 
 	awstrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/aws-sdk-go/aws"
 )
@@ -25,3 +28,26 @@ func Example() {
 		Bucket: aws.String("some-bucket-name"),
 	})
 }
+
+// To propagate the traces through SQS queues, enable the injection of the span
+// context into the message attributes, and start the spans processing the
+// received messages with awstrace.StartSQSMessageSpan.
+func ExampleStartSQSMessageSpan() {
+	cfg := aws.NewConfig().WithRegion("us-west-2")
+	sess := session.Must(session.NewSession(cfg))
+	sess = awstrace.WrapSession(sess, awstrace.WithMessageAttributesInjection(true))
+
+	sqsapi := sqs.New(sess)
+	out, err := sqsapi.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl: aws.String("https://sqs.us-west-2.amazonaws.com/123456789012/queue"),
+	})
+	if err != nil {
+		return
+	}
+	for _, msg := range out.Messages {
+		span, ctx := awstrace.StartSQSMessageSpan(context.Background(), msg)
+		// process the message using ctx...
+		_ = ctx
+		span.Finish()
+	}
+}
@@ -12,8 +12,9 @@ import (
 )
 
 type config struct {
-	serviceName   string
-	analyticsRate float64
+	serviceName             string
+	analyticsRate           float64
+	injectMessageAttributes bool
 }
 
 // Option represents an option that can be passed to Dial.
@@ -59,3 +60,15 @@ func WithAnalyticsRate(rate float64) Option {
 		}
 	}
 }
+
+// WithMessageAttributesInjection enables the injection of the span context into
+// the _datadog message attribute of the messages sent with the SQS
+// SendMessage and SendMessageBatch and the SNS Publish operations, and the
+// request of this attribute in the SQS ReceiveMessage operation. The attribute
+// is not injected into the messages already carrying the maximum of 10 message
+// attributes. Received messages can then be traced using StartSQSMessageSpan.
+func WithMessageAttributesInjection(on bool) Option {
+	return func(cfg *config) {
+		cfg.injectMessageAttributes = on
+	}
+}
@@ -0,0 +1,247 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package aws
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/aws/internal/tags"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// sqsStandIn is a local stand-in of the SQS and SNS query APIs, keeping the
+// message attributes of the last message sent, and returning them when
+// receiving messages.
+type sqsStandIn struct {
+	mu        sync.Mutex
+	attrs     map[string]string // message attribute names to string values
+	attrNames []string          // message attribute names requested when receiving
+}
+
+func (s *sqsStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Form.Get("Action") {
+	case "SendMessage":
+		s.attrs = formAttributes(r.Form, "MessageAttribute.%d.Name", "MessageAttribute.%d.Value.StringValue")
+		fmt.Fprintf(w, `<SendMessageResponse><SendMessageResult><MD5OfMessageBody>%s</MD5OfMessageBody><MessageId>1</MessageId></SendMessageResult></SendMessageResponse>`, md5Hex(r.Form.Get("MessageBody")))
+	case "Publish":
+		s.attrs = formAttributes(r.Form, "MessageAttributes.entry.%d.Name", "MessageAttributes.entry.%d.Value.StringValue")
+		fmt.Fprint(w, `<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult></PublishResponse>`)
+	case "ReceiveMessage":
+		s.attrNames = nil
+		for i := 1; r.Form.Get(fmt.Sprintf("MessageAttributeName.%d", i)) != ""; i++ {
+			s.attrNames = append(s.attrNames, r.Form.Get(fmt.Sprintf("MessageAttributeName.%d", i)))
+		}
+		var attrs strings.Builder
+		for k, v := range s.attrs {
+			attrs.WriteString("<MessageAttribute><Name>" + k + "</Name><Value><DataType>String</DataType><StringValue>")
+			xml.EscapeText(&attrs, []byte(v))
+			attrs.WriteString("</StringValue></Value></MessageAttribute>")
+		}
+		fmt.Fprintf(w, `<ReceiveMessageResponse><ReceiveMessageResult><Message><MessageId>1</MessageId><Body>hello</Body><MD5OfBody>%s</MD5OfBody>%s</Message></ReceiveMessageResult></ReceiveMessageResponse>`, md5Hex("hello"), attrs.String())
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func formAttributes(form url.Values, nameFormat, valueFormat string) map[string]string {
+	attrs := make(map[string]string)
+	for i := 1; form.Get(fmt.Sprintf(nameFormat, i)) != ""; i++ {
+		attrs[form.Get(fmt.Sprintf(nameFormat, i))] = form.Get(fmt.Sprintf(valueFormat, i))
+	}
+	return attrs
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func newStandInSession(t *testing.T, opts ...Option) (*session.Session, *sqsStandIn) {
+	standIn := new(sqsStandIn)
+	srv := httptest.NewServer(standIn)
+	t.Cleanup(srv.Close)
+	cfg := aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(srv.URL).
+		WithCredentials(credentials.AnonymousCredentials)
+	return WrapSession(session.Must(session.NewSession(cfg)), opts...), standIn
+}
+
+func TestMessageAttributesInjection(t *testing.T) {
+	t.Run("sqs", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		sess, standIn := newStandInSession(t, WithMessageAttributesInjection(true))
+		client := sqs.New(sess)
+
+		root, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+		attrs := map[string]*sqs.MessageAttributeValue{
+			"key": {DataType: aws.String("String"), StringValue: aws.String("value")},
+		}
+		_, err := client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+			QueueUrl:          aws.String("http://queue"),
+			MessageBody:       aws.String("hello"),
+			MessageAttributes: attrs,
+		})
+		require.NoError(t, err)
+		root.Finish()
+		assert.Len(t, attrs, 1, "the input message attributes should not be modified")
+		assert.Equal(t, "value", standIn.attrs["key"])
+		assert.Contains(t, standIn.attrs, datadogAttributeKey)
+
+		out, err := client.ReceiveMessage(&sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String("http://queue"),
+			MessageAttributeNames: []*string{aws.String("key")},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"key", datadogAttributeKey}, standIn.attrNames)
+		require.Len(t, out.Messages, 1)
+
+		span, _ := StartSQSMessageSpan(context.Background(), out.Messages[0])
+		span.Finish()
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 4)
+		send, process := spans[0], spans[3]
+		assert.Equal(t, "sqs.command", send.OperationName())
+		assert.Equal(t, "SendMessage", send.Tag(tagAWSOperation))
+		assert.Equal(t, "http://queue", send.Tag(tags.SQSQueueURL))
+		assert.Equal(t, "queue", send.Tag(tags.SQSQueueName))
+		assert.Equal(t, root.Context().TraceID(), send.TraceID())
+		assert.Equal(t, "sqs.process", process.OperationName())
+		assert.Equal(t, send.TraceID(), process.TraceID())
+		assert.Equal(t, send.SpanID(), process.ParentID())
+		assert.Equal(t, "1", process.Tag(tagSQSMessageID))
+		assert.Equal(t, "aws.sqs", process.Tag(ext.ServiceName))
+		assert.Equal(t, ext.SpanKindConsumer, process.Tag(ext.SpanKind))
+	})
+
+	t.Run("sns", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		sess, standIn := newStandInSession(t, WithMessageAttributesInjection(true))
+
+		_, err := sns.New(sess).Publish(&sns.PublishInput{
+			TopicArn: aws.String("arn:aws:sns:us-west-2:123456789012:topic"),
+			Message:  aws.String("hello"),
+		})
+		require.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		spanctx, err := decodeSpanContext([]byte(standIn.attrs[datadogAttributeKey]))
+		require.NoError(t, err)
+		assert.Equal(t, spans[0].SpanID(), spanctx.SpanID())
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		sess, standIn := newStandInSession(t, WithMessageAttributesInjection(true))
+
+		attrs := make(map[string]*sqs.MessageAttributeValue)
+		for i := 0; i < maxMessageAttributes; i++ {
+			attrs[fmt.Sprintf("key%d", i)] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("value")}
+		}
+		_, err := sqs.New(sess).SendMessage(&sqs.SendMessageInput{
+			QueueUrl:          aws.String("http://queue"),
+			MessageBody:       aws.String("hello"),
+			MessageAttributes: attrs,
+		})
+		require.NoError(t, err)
+		assert.Len(t, standIn.attrs, maxMessageAttributes)
+		assert.NotContains(t, standIn.attrs, datadogAttributeKey)
+		assert.Len(t, mt.FinishedSpans(), 1)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		sess, standIn := newStandInSession(t)
+
+		_, err := sqs.New(sess).SendMessage(&sqs.SendMessageInput{
+			QueueUrl:    aws.String("http://queue"),
+			MessageBody: aws.String("hello"),
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, standIn.attrs, datadogAttributeKey)
+		assert.Len(t, mt.FinishedSpans(), 1)
+	})
+}
+
+func TestInjectSendMessageBatch(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	span := tracer.StartSpan("test")
+	in := &sqs.SendMessageBatchInput{
+		Entries: []*sqs.SendMessageBatchRequestEntry{{Id: aws.String("1")}, {Id: aws.String("2")}},
+	}
+	injected := injectMessageAttributes(in, span.Context()).(*sqs.SendMessageBatchInput)
+	for i, e := range injected.Entries {
+		assert.Nil(t, in.Entries[i].MessageAttributes)
+		spanctx, err := decodeSpanContext([]byte(aws.StringValue(e.MessageAttributes[datadogAttributeKey].StringValue)))
+		require.NoError(t, err)
+		assert.Equal(t, span.Context().SpanID(), spanctx.SpanID())
+	}
+}
+
+func TestExtractSQSMessageSpanContext(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	span := tracer.StartSpan("test")
+	value, ok := encodeSpanContext(span.Context())
+	require.True(t, ok)
+
+	t.Run("attribute", func(t *testing.T) {
+		spanctx, err := ExtractSQSMessageSpanContext(&sqs.Message{
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				datadogAttributeKey: {DataType: aws.String("String"), StringValue: aws.String(value)},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, span.Context().SpanID(), spanctx.SpanID())
+	})
+
+	t.Run("sns-notification", func(t *testing.T) {
+		body := fmt.Sprintf(`{"Type":"Notification","Message":"hello","MessageAttributes":{"_datadog":{"Type":"String","Value":%q}}}`, value)
+		spanctx, err := ExtractSQSMessageSpanContext(&sqs.Message{Body: aws.String(body)})
+		require.NoError(t, err)
+		assert.Equal(t, span.Context().SpanID(), spanctx.SpanID())
+	})
+
+	t.Run("none", func(t *testing.T) {
+		_, err := ExtractSQSMessageSpanContext(&sqs.Message{Body: aws.String("hello")})
+		assert.Equal(t, errNoSpanContext, err)
+	})
+}
@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+// Package tags extracts the service specific span tags from the inputs of the
+// AWS SDK operations. The inputs are inspected by field name, so that both the
+// aws-sdk-go and aws-sdk-go-v2 operation inputs are supported without
+// depending on every service package.
+package tags
+
+import (
+	"reflect"
+	"strings"
+)
+
+const (
+	// SQSQueueURL is the URL of the SQS queue.
+	SQSQueueURL = "aws.sqs.queue_url"
+	// SQSQueueName is the name of the SQS queue.
+	SQSQueueName = "aws.sqs.queue_name"
+	// S3BucketName is the name of the S3 bucket.
+	S3BucketName = "aws.s3.bucket_name"
+	// S3ObjectKey is the key of the S3 object.
+	S3ObjectKey = "aws.s3.object_key"
+	// DynamoDBTableName is the name of the DynamoDB table.
+	DynamoDBTableName = "aws.dynamodb.table_name"
+	// SNSTopicARN is the ARN of the SNS topic.
+	SNSTopicARN = "aws.sns.topic_arn"
+	// SNSTargetARN is the ARN of the SNS endpoint targeted by a direct publish.
+	SNSTargetARN = "aws.sns.target_arn"
+	// KinesisStreamName is the name of the Kinesis stream.
+	KinesisStreamName = "aws.kinesis.stream_name"
+	// LambdaFunctionName is the name of the Lambda function.
+	LambdaFunctionName = "aws.lambda.function_name"
+)
+
+// Tags returns the tags identifying the resource targeted by the given input
+// of an operation of the given AWS service, such as "sqs" or "DynamoDB". It
+// returns nil when the service is not supported or the input holds no
+// resource.
+func Tags(service string, input interface{}) map[string]string {
+	v := reflect.ValueOf(input)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	tags := make(map[string]string)
+	set := func(tag, field string) {
+		if s, ok := stringField(v, field); ok {
+			tags[tag] = s
+		}
+	}
+	switch strings.ToLower(service) {
+	case "sqs":
+		if url, ok := stringField(v, "QueueUrl"); ok {
+			tags[SQSQueueURL] = url
+			tags[SQSQueueName] = url[strings.LastIndex(url, "/")+1:]
+		} else {
+			set(SQSQueueName, "QueueName")
+		}
+	case "s3":
+		set(S3BucketName, "Bucket")
+		set(S3ObjectKey, "Key")
+	case "dynamodb":
+		set(DynamoDBTableName, "TableName")
+	case "sns":
+		set(SNSTopicARN, "TopicArn")
+		set(SNSTargetARN, "TargetArn")
+	case "kinesis":
+		set(KinesisStreamName, "StreamName")
+	case "lambda":
+		set(LambdaFunctionName, "FunctionName")
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// stringField returns the value of the non-empty string or *string field of v
+// with the given name.
+func stringField(v reflect.Value, name string) (string, bool) {
+	f := v.FieldByName(name)
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return "", false
+		}
+		f = f.Elem()
+	}
+	if f.Kind() != reflect.String || f.String() == "" {
+		return "", false
+	}
+	return f.String(), true
+}
@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tags
+
+import (
+	"testing"
+
+	sqsv2 "github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		service string
+		input   interface{}
+		tags    map[string]string
+	}{
+		{
+			name:    "sqs-url",
+			service: "sqs",
+			input:   &sqs.SendMessageInput{QueueUrl: aws.String("https://sqs.us-west-2.amazonaws.com/123456789012/queue")},
+			tags: map[string]string{
+				SQSQueueURL:  "https://sqs.us-west-2.amazonaws.com/123456789012/queue",
+				SQSQueueName: "queue",
+			},
+		},
+		{
+			name:    "sqs-name",
+			service: "sqs",
+			input:   &sqs.GetQueueUrlInput{QueueName: aws.String("queue")},
+			tags:    map[string]string{SQSQueueName: "queue"},
+		},
+		{
+			name:    "sqs-v2",
+			service: "SQS",
+			input:   &sqsv2.DeleteQueueInput{QueueUrl: aws.String("https://sqs.us-west-2.amazonaws.com/123456789012/queue")},
+			tags: map[string]string{
+				SQSQueueURL:  "https://sqs.us-west-2.amazonaws.com/123456789012/queue",
+				SQSQueueName: "queue",
+			},
+		},
+		{
+			name:    "s3",
+			service: "s3",
+			input:   &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")},
+			tags:    map[string]string{S3BucketName: "bucket", S3ObjectKey: "key"},
+		},
+		{
+			name:    "s3-multipart",
+			service: "S3",
+			input:   &s3.CompleteMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("key")},
+			tags:    map[string]string{S3BucketName: "bucket", S3ObjectKey: "key"},
+		},
+		{
+			name:    "dynamodb",
+			service: "DynamoDB",
+			input:   &struct{ TableName *string }{aws.String("table")},
+			tags:    map[string]string{DynamoDBTableName: "table"},
+		},
+		{
+			name:    "sns-topic",
+			service: "sns",
+			input:   &struct{ TopicArn, TargetArn *string }{TopicArn: aws.String("arn:topic")},
+			tags:    map[string]string{SNSTopicARN: "arn:topic"},
+		},
+		{
+			name:    "sns-target",
+			service: "SNS",
+			input:   &struct{ TopicArn, TargetArn *string }{TargetArn: aws.String("arn:target")},
+			tags:    map[string]string{SNSTargetARN: "arn:target"},
+		},
+		{
+			name:    "kinesis",
+			service: "Kinesis",
+			input:   struct{ StreamName string }{"stream"},
+			tags:    map[string]string{KinesisStreamName: "stream"},
+		},
+		{
+			name:    "lambda",
+			service: "lambda",
+			input:   &struct{ FunctionName *string }{aws.String("function")},
+			tags:    map[string]string{LambdaFunctionName: "function"},
+		},
+		{
+			name:    "no-resource",
+			service: "sqs",
+			input:   &sqs.ListQueuesInput{},
+		},
+		{
+			name:    "unsupported-service",
+			service: "ec2",
+			input:   &struct{ TableName *string }{aws.String("table")},
+		},
+		{
+			name:    "nil",
+			service: "sqs",
+			input:   (*sqs.SendMessageInput)(nil),
+		},
+		{
+			name:    "not-a-struct",
+			service: "sqs",
+			input:   "queue",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.tags, Tags(tt.service, tt.input))
+		})
+	}
+}
@@ -7,12 +7,16 @@
 package kafka // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/confluentinc/confluent-kafka-go/kafka"
 
 import (
+	"context"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
@@ -24,6 +28,11 @@ func NewConsumer(conf *kafka.ConfigMap, opts ...Option) (*Consumer, error) {
 	if err != nil {
 		return nil, err
 	}
+	if groupID, err := conf.Get("group.id", ""); err == nil {
+		if groupID, ok := groupID.(string); ok && groupID != "" {
+			opts = append([]Option{withGroupID(groupID)}, opts...)
+		}
+	}
 	return WrapConsumer(c, opts...), nil
 }
 
@@ -108,6 +117,9 @@ func (c *Consumer) startSpan(msg *kafka.Message) ddtrace.Span {
 	if !math.IsNaN(c.cfg.analyticsRate) {
 		opts = append(opts, tracer.Tag(ext.EventSampleRate, c.cfg.analyticsRate))
 	}
+	if lag, ok := c.lag(msg); ok {
+		opts = append(opts, tracer.Tag("consumer_lag", lag))
+	}
 	// kafka supports headers, so try to extract a span context
 	carrier := NewMessageCarrier(msg)
 	if spanctx, err := tracer.Extract(carrier); err == nil {
@@ -116,9 +128,77 @@ func (c *Consumer) startSpan(msg *kafka.Message) ddtrace.Span {
 	span, _ := tracer.StartSpanFromContext(c.cfg.ctx, "kafka.consume", opts...)
 	// reinject the span context so consumers can pick it up
 	tracer.Inject(span.Context(), carrier)
+	c.setConsumeCheckpoint(msg)
 	return span
 }
 
+// lag returns the number of messages of the partition of msg remaining to be
+// consumed after it, according to the high watermark cached by the consumer.
+func (c *Consumer) lag(msg *kafka.Message) (int64, bool) {
+	tp := msg.TopicPartition
+	if tp.Topic == nil || tp.Offset < 0 {
+		return 0, false
+	}
+	_, high, err := c.Consumer.GetWatermarkOffsets(*tp.Topic, tp.Partition)
+	if err != nil || high < 0 {
+		return 0, false
+	}
+	if lag := high - int64(tp.Offset) - 1; lag > 0 {
+		return lag, true
+	}
+	return 0, true
+}
+
+// setConsumeCheckpoint sets a data streams checkpoint on the pathway of the
+// consumed message, and re-injects it so that the messages produced while
+// handling it can continue the pathway, see ContextWithPathway.
+func (c *Consumer) setConsumeCheckpoint(msg *kafka.Message) {
+	if !datastreams.Enabled() {
+		return
+	}
+	edges := []string{"direction:in"}
+	if c.cfg.groupID != "" {
+		edges = append(edges, "group:"+c.cfg.groupID)
+	}
+	edges = append(edges, "partition:"+strconv.Itoa(int(msg.TopicPartition.Partition)))
+	if msg.TopicPartition.Topic != nil {
+		edges = append(edges, "topic:"+*msg.TopicPartition.Topic)
+	}
+	edges = append(edges, "type:kafka")
+	carrier := NewMessageCarrier(msg)
+	p, ok := datastreams.Extract(carrier)
+	if ok {
+		p = p.SetCheckpoint(edges...)
+	} else {
+		p = datastreams.NewPathway(edges...)
+	}
+	datastreams.Inject(p, carrier)
+}
+
+// ContextWithPathway returns a copy of ctx carrying the data streams pathway of
+// the consumed message msg. Passing the returned context to InjectPathway
+// makes the messages produced while handling msg continue its pathway, so that
+// the latency of pipelines spanning several topics can be measured end to end.
+func ContextWithPathway(ctx context.Context, msg *kafka.Message) context.Context {
+	if p, ok := datastreams.Extract(NewMessageCarrier(msg)); ok {
+		return datastreams.ContextWithPathway(ctx, p)
+	}
+	return ctx
+}
+
+// InjectPathway injects the data streams pathway carried by ctx, as returned by
+// ContextWithPathway, into the headers of the message msg to be produced, so
+// that the producer continues it. It does nothing when ctx carries no pathway
+// or when Data Streams Monitoring is disabled.
+func InjectPathway(ctx context.Context, msg *kafka.Message) {
+	if !datastreams.Enabled() {
+		return
+	}
+	if p, ok := datastreams.PathwayFromContext(ctx); ok {
+		datastreams.Inject(p, NewMessageCarrier(msg))
+	}
+}
+
 // Close calls the underlying Consumer.Close and if polling is enabled, finishes
 // any remaining span.
 func (c *Consumer) Close() error {
@@ -167,6 +247,64 @@ func (c *Consumer) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
 	return msg, nil
 }
 
+// Commit calls the underlying Consumer.Commit and traces the commit of the
+// offsets.
+func (c *Consumer) Commit() ([]kafka.TopicPartition, error) {
+	span := c.startCommitSpan(nil)
+	tps, err := c.Consumer.Commit()
+	finishCommitSpan(span, tps, err)
+	return tps, err
+}
+
+// CommitMessage calls the underlying Consumer.CommitMessage and traces the
+// commit of the offset of the message, as a child of its consume span.
+func (c *Consumer) CommitMessage(msg *kafka.Message) ([]kafka.TopicPartition, error) {
+	span := c.startCommitSpan(msg)
+	tps, err := c.Consumer.CommitMessage(msg)
+	finishCommitSpan(span, tps, err)
+	return tps, err
+}
+
+// CommitOffsets calls the underlying Consumer.CommitOffsets and traces the
+// commit of the offsets.
+func (c *Consumer) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	span := c.startCommitSpan(nil)
+	tps, err := c.Consumer.CommitOffsets(offsets)
+	finishCommitSpan(span, tps, err)
+	return tps, err
+}
+
+// startCommitSpan starts the span of an offsets commit, child of the consume
+// span of msg when given.
+func (c *Consumer) startCommitSpan(msg *kafka.Message) ddtrace.Span {
+	opts := []tracer.StartSpanOption{
+		tracer.ServiceName(c.cfg.consumerServiceName),
+		tracer.ResourceName("Commit Offsets"),
+		tracer.SpanType(ext.SpanTypeMessageConsumer),
+		tracer.Tag(ext.Component, "confluentinc/confluent-kafka-go/kafka"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
+	}
+	if msg != nil {
+		if spanctx, err := tracer.Extract(NewMessageCarrier(msg)); err == nil {
+			opts = append(opts, tracer.ChildOf(spanctx))
+		}
+	}
+	span, _ := tracer.StartSpanFromContext(c.cfg.ctx, "kafka.commit", opts...)
+	return span
+}
+
+// finishCommitSpan tags the span with the committed offsets and finishes it.
+func finishCommitSpan(span ddtrace.Span, tps []kafka.TopicPartition, err error) {
+	if len(tps) > 0 {
+		offsets := make([]string, len(tps))
+		for i, tp := range tps {
+			offsets[i] = tp.String()
+		}
+		span.SetTag("offsets", strings.Join(offsets, ","))
+	}
+	span.Finish(tracer.WithError(err))
+}
+
 // A Producer wraps a kafka.Producer.
 type Producer struct {
 	*kafka.Producer
@@ -223,9 +361,32 @@ func (p *Producer) startSpan(msg *kafka.Message) ddtrace.Span {
 	span, _ := tracer.StartSpanFromContext(p.cfg.ctx, "kafka.produce", opts...)
 	// inject the span context so consumers can pick it up
 	tracer.Inject(span.Context(), carrier)
+	setProduceCheckpoint(msg)
 	return span
 }
 
+// setProduceCheckpoint sets a data streams checkpoint on the pathway of the
+// produced message, see InjectPathway, or on a new pathway when it carries
+// none, and propagates it in the message headers.
+func setProduceCheckpoint(msg *kafka.Message) {
+	if !datastreams.Enabled() {
+		return
+	}
+	edges := []string{"direction:out"}
+	if msg.TopicPartition.Topic != nil {
+		edges = append(edges, "topic:"+*msg.TopicPartition.Topic)
+	}
+	edges = append(edges, "type:kafka")
+	carrier := NewMessageCarrier(msg)
+	p, ok := datastreams.Extract(carrier)
+	if ok {
+		p = p.SetCheckpoint(edges...)
+	} else {
+		p = datastreams.NewPathway(edges...)
+	}
+	datastreams.Inject(p, carrier)
+}
+
 // Close calls the underlying Producer.Close and also closes the internal
 // wrapping producer channel.
 func (p *Producer) Close() {
@@ -8,6 +8,8 @@ package kafka
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -15,10 +17,12 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -90,6 +94,128 @@ func TestConsumerChannel(t *testing.T) {
 	}
 }
 
+func TestConsumerCommit(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	c, err := NewConsumer(&kafka.ConfigMap{
+		"go.events.channel.enable": true, // required for the events channel to be turned on
+		"group.id":                 testGroupID,
+		"socket.timeout.ms":        10,
+		"session.timeout.ms":       10,
+		"enable.auto.offset.store": false,
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	// nothing to commit without any assigned partition
+	_, err = c.Commit()
+	assert.Error(t, err)
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	s := spans[0]
+	assert.Equal(t, "kafka.commit", s.OperationName())
+	assert.Equal(t, "Commit Offsets", s.Tag(ext.ResourceName))
+	assert.Equal(t, "kafka", s.Tag(ext.ServiceName))
+	assert.Equal(t, "confluentinc/confluent-kafka-go/kafka", s.Tag(ext.Component))
+	assert.Equal(t, ext.SpanKindClient, s.Tag(ext.SpanKind))
+	assert.Equal(t, err, s.Tag(ext.Error))
+	assert.Nil(t, s.Tag("offsets"))
+
+	tps := []kafka.TopicPartition{
+		{Topic: &testTopic, Partition: 0, Offset: 2},
+		{Topic: &testTopic, Partition: 1, Offset: 5},
+	}
+	span := tracer.StartSpan("kafka.commit")
+	finishCommitSpan(span, tps, nil)
+	assert.Equal(t, "gotest[0]@2,gotest[1]@5", mt.FinishedSpans()[1].Tag("offsets"))
+}
+
+func TestConsumerDataStreams(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	p := datastreams.NewProcessor("service", "env", srv.URL, nil)
+	p.Start()
+	defer p.Stop()
+
+	c, err := NewConsumer(&kafka.ConfigMap{
+		"go.events.channel.enable": true, // required for the events channel to be turned on
+		"group.id":                 testGroupID,
+		"socket.timeout.ms":        10,
+		"session.timeout.ms":       10,
+		"enable.auto.offset.store": false,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testGroupID, c.cfg.groupID)
+
+	produced := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &testTopic, Partition: 1},
+	}
+	setProduceCheckpoint(produced)
+	producedPathway, ok := datastreams.Extract(NewMessageCarrier(produced))
+	require.True(t, ok)
+
+	go func() {
+		c.Consumer.Events() <- &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &testTopic, Partition: 1, Offset: 1},
+			Headers:        produced.Headers,
+		}
+	}()
+	msg := (<-c.Events()).(*kafka.Message)
+	c.Close()
+	<-c.Events()
+
+	consumedPathway, ok := datastreams.Extract(NewMessageCarrier(msg))
+	require.True(t, ok)
+	assert.NotEqual(t, producedPathway.Hash(), consumedPathway.Hash())
+	assert.Equal(t, producedPathway.PathwayStart(), consumedPathway.PathwayStart())
+}
+
+func TestDataStreamsPathwayChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	p := datastreams.NewProcessor("service", "env", srv.URL, nil)
+	p.Start()
+	defer p.Stop()
+
+	c, err := NewConsumer(&kafka.ConfigMap{
+		"group.id":           testGroupID,
+		"socket.timeout.ms":  10,
+		"session.timeout.ms": 10,
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	// consume is the consumer side of a hop, passing the pathway of the
+	// consumed message to the message produced while handling it.
+	consume := func(produced *kafka.Message) *kafka.Message {
+		msg := &kafka.Message{TopicPartition: produced.TopicPartition, Headers: produced.Headers}
+		c.setConsumeCheckpoint(msg)
+		return msg
+	}
+
+	topicA, topicB := "topic_a", "topic_b"
+	first := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topicA, Partition: 1}}
+	setProduceCheckpoint(first)
+	start, ok := datastreams.Extract(NewMessageCarrier(first))
+	require.True(t, ok)
+
+	ctx := ContextWithPathway(context.Background(), consume(first))
+	second := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topicB, Partition: 1}}
+	InjectPathway(ctx, second)
+	setProduceCheckpoint(second)
+	end, ok := datastreams.Extract(NewMessageCarrier(consume(second)))
+	require.True(t, ok)
+
+	want := start.
+		SetCheckpoint("direction:in", "group:"+testGroupID, "partition:1", "topic:topic_a", "type:kafka").
+		SetCheckpoint("direction:out", "topic:topic_b", "type:kafka").
+		SetCheckpoint("direction:in", "group:"+testGroupID, "partition:1", "topic:topic_b", "type:kafka")
+	assert.Equal(t, want.Hash(), end.Hash())
+	assert.Equal(t, start.PathwayStart(), end.PathwayStart())
+}
+
 /*
 to run the integration test locally:
 
@@ -210,6 +336,7 @@ func TestConsumerFunctional(t *testing.T) {
 			assert.Equal(t, nil, s1.Tag(ext.EventSampleRate))
 			assert.Equal(t, "queue", s1.Tag(ext.SpanType))
 			assert.Equal(t, int32(0), s1.Tag("partition"))
+			assert.Equal(t, int64(0), s1.Tag("consumer_lag"))
 			assert.Equal(t, "confluentinc/confluent-kafka-go/kafka", s1.Tag(ext.Component))
 			assert.Equal(t, ext.SpanKindConsumer, s1.Tag(ext.SpanKind))
 		})
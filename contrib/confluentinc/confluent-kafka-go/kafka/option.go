@@ -20,6 +20,7 @@ type config struct {
 	consumerServiceName string
 	producerServiceName string
 	analyticsRate       float64
+	groupID             string
 	tagFns              map[string]func(msg *kafka.Message) interface{}
 }
 
@@ -55,6 +56,14 @@ func WithContext(ctx context.Context) Option {
 	}
 }
 
+// withGroupID sets the consumer group of the consumer, used to identify its
+// data streams checkpoints.
+func withGroupID(groupID string) Option {
+	return func(cfg *config) {
+		cfg.groupID = groupID
+	}
+}
+
 // WithServiceName sets the config service name to serviceName.
 func WithServiceName(serviceName string) Option {
 	return func(cfg *config) {
@@ -41,28 +41,62 @@ const (
 type tracedConn struct {
 	driver.Conn
 	*traceParams
+
+	// tx holds the transaction in progress on the connection, if any.
+	tx *tracedTx
 }
 
 func (tc *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
 	start := time.Now()
+	txSpan, ctx := tc.startTransaction(ctx, start)
 	if connBeginTx, ok := tc.Conn.(driver.ConnBeginTx); ok {
 		tx, err = connBeginTx.BeginTx(ctx, opts)
-		tc.tryTrace(ctx, queryTypeBegin, "", start, err)
-		if err != nil {
-			return nil, err
-		}
-		return &tracedTx{tx, tc.traceParams, ctx}, nil
+	} else {
+		tx, err = tc.Conn.Begin()
 	}
-	tx, err = tc.Conn.Begin()
 	tc.tryTrace(ctx, queryTypeBegin, "", start, err)
 	if err != nil {
+		if txSpan != nil {
+			tc.finishSpan(txSpan, err)
+		}
 		return nil, err
 	}
-	return &tracedTx{tx, tc.traceParams, ctx}, nil
+	t := &tracedTx{Tx: tx, traceParams: tc.traceParams, ctx: ctx, conn: tc, span: txSpan}
+	if txSpan != nil {
+		tc.tx = t
+	}
+	return t, nil
+}
+
+// startTransaction starts the span of a transaction when transaction tracing is
+// enabled, and returns it along with a copy of ctx holding it.
+func (tc *tracedConn) startTransaction(ctx context.Context, start time.Time) (ddtrace.Span, context.Context) {
+	if !tc.cfg.traceTransactions {
+		return nil, ctx
+	}
+	if _, exists := tracer.SpanFromContext(ctx); tc.cfg.childSpansOnly && !exists {
+		return nil, ctx
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, fmt.Sprintf("%s.transaction", tc.driverName),
+		append(tc.spanOptions(start), tracer.ResourceName("Transaction"))...)
+	for k, v := range tc.meta {
+		span.SetTag(k, v)
+	}
+	return span, ctx
+}
+
+// txContext returns a copy of ctx holding the span of the transaction in progress
+// on the connection, so that the queries run within the transaction are its children.
+func (tc *tracedConn) txContext(ctx context.Context) context.Context {
+	if tc.tx == nil {
+		return ctx
+	}
+	return tracer.ContextWithSpan(ctx, tc.tx.span)
 }
 
 func (tc *tracedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
 	start := time.Now()
+	ctx = tc.txContext(ctx)
 	mode := tc.cfg.dbmPropagationMode
 	if mode == tracer.DBMPropagationModeFull {
 		// no context other than service in prepared statements
@@ -75,18 +109,19 @@ func (tc *tracedConn) PrepareContext(ctx context.Context, query string) (stmt dr
 		if err != nil {
 			return nil, err
 		}
-		return &tracedStmt{Stmt: stmt, traceParams: tc.traceParams, ctx: ctx, query: query}, nil
+		return &tracedStmt{Stmt: stmt, traceParams: tc.traceParams, ctx: ctx, query: query, conn: tc}, nil
 	}
 	stmt, err = tc.Prepare(cquery)
 	tc.tryTrace(ctx, queryTypePrepare, query, start, err, append(withDBMTraceInjectedTag(mode), tracer.WithSpanID(spanID))...)
 	if err != nil {
 		return nil, err
 	}
-	return &tracedStmt{Stmt: stmt, traceParams: tc.traceParams, ctx: ctx, query: query}, nil
+	return &tracedStmt{Stmt: stmt, traceParams: tc.traceParams, ctx: ctx, query: query, conn: tc}, nil
 }
 
 func (tc *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (r driver.Result, err error) {
 	start := time.Now()
+	ctx = tc.txContext(ctx)
 	if execContext, ok := tc.Conn.(driver.ExecerContext); ok {
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		r, err := execContext.ExecContext(ctx, cquery, args)
@@ -114,6 +149,7 @@ func (tc *tracedConn) ExecContext(ctx context.Context, query string, args []driv
 // tracedConn has a Ping method in order to implement the pinger interface
 func (tc *tracedConn) Ping(ctx context.Context) (err error) {
 	start := time.Now()
+	ctx = tc.txContext(ctx)
 	if pinger, ok := tc.Conn.(driver.Pinger); ok {
 		err = pinger.Ping(ctx)
 	}
@@ -123,11 +159,12 @@ func (tc *tracedConn) Ping(ctx context.Context) (err error) {
 
 func (tc *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
 	start := time.Now()
+	ctx = tc.txContext(ctx)
 	if queryerContext, ok := tc.Conn.(driver.QueryerContext); ok {
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		rows, err := queryerContext.QueryContext(ctx, cquery, args)
-		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
-		return rows, err
+		span := tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
+		return tc.wrapRows(rows, span, query), err
 	}
 	if queryer, ok := tc.Conn.(driver.Queryer); ok {
 		dargs, err := namedValueToValue(args)
@@ -141,8 +178,8 @@ func (tc *tracedConn) QueryContext(ctx context.Context, query string, args []dri
 		}
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		rows, err = queryer.Query(cquery, dargs)
-		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
-		return rows, err
+		span := tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
+		return tc.wrapRows(rows, span, query), err
 	}
 	return nil, driver.ErrSkip
 }
@@ -170,6 +207,7 @@ type traceParams struct {
 	cfg        *config
 	driverName string
 	meta       map[string]string
+	poller     *dbStatsPoller // nil unless WithDBStats is used
 }
 
 type contextKey int
@@ -210,33 +248,20 @@ func withDBMTraceInjectedTag(mode tracer.DBMPropagationMode) []tracer.StartSpanO
 }
 
 // tryTrace will create a span using the given arguments, but will act as a no-op when err is driver.ErrSkip.
-func (tp *traceParams) tryTrace(ctx context.Context, qtype queryType, query string, startTime time.Time, err error, spanOpts ...ddtrace.StartSpanOption) {
+// It returns the finished span, or nil if none was created.
+func (tp *traceParams) tryTrace(ctx context.Context, qtype queryType, query string, startTime time.Time, err error, spanOpts ...ddtrace.StartSpanOption) ddtrace.Span {
 	if err == driver.ErrSkip {
 		// Not a user error: driver is telling sql package that an
 		// optional interface method is not implemented. There is
 		// nothing to trace here.
 		// See: https://github.com/DataDog/dd-trace-go/issues/270
-		return
+		return nil
 	}
 	if _, exists := tracer.SpanFromContext(ctx); tp.cfg.childSpansOnly && !exists {
-		return
+		return nil
 	}
 	name := fmt.Sprintf("%s.query", tp.driverName)
-	opts := append(spanOpts,
-		tracer.ServiceName(tp.cfg.serviceName),
-		tracer.SpanType(ext.SpanTypeSQL),
-		tracer.StartTime(startTime),
-		tracer.Tag(ext.Component, "database/sql"),
-		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
-	)
-	if tp.cfg.tags != nil {
-		for key, tag := range tp.cfg.tags {
-			opts = append(opts, tracer.Tag(key, tag))
-		}
-	}
-	if !math.IsNaN(tp.cfg.analyticsRate) {
-		opts = append(opts, tracer.Tag(ext.EventSampleRate, tp.cfg.analyticsRate))
-	}
+	opts := append(spanOpts, tp.spanOptions(startTime)...)
 	span, _ := tracer.StartSpanFromContext(ctx, name, opts...)
 	resource := string(qtype)
 	if query != "" {
@@ -252,6 +277,38 @@ func (tp *traceParams) tryTrace(ctx context.Context, qtype queryType, query stri
 			span.SetTag(k, v)
 		}
 	}
+	if tp.poller != nil {
+		if count, wait := tp.poller.takeWait(); count > 0 {
+			span.SetTag(tagPoolWaitCount, count)
+			span.SetTag(tagPoolWaitDuration, wait.Nanoseconds())
+		}
+	}
+	tp.finishSpan(span, err)
+	return span
+}
+
+// spanOptions returns the options common to all the spans started at startTime.
+func (tp *traceParams) spanOptions(startTime time.Time) []ddtrace.StartSpanOption {
+	opts := []ddtrace.StartSpanOption{
+		tracer.ServiceName(tp.cfg.serviceName),
+		tracer.SpanType(ext.SpanTypeSQL),
+		tracer.StartTime(startTime),
+		tracer.Tag(ext.Component, "database/sql"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
+	}
+	if tp.cfg.tags != nil {
+		for key, tag := range tp.cfg.tags {
+			opts = append(opts, tracer.Tag(key, tag))
+		}
+	}
+	if !math.IsNaN(tp.cfg.analyticsRate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, tp.cfg.analyticsRate))
+	}
+	return opts
+}
+
+// finishSpan finishes span, marking it as an error when err passes the error check.
+func (tp *traceParams) finishSpan(span ddtrace.Span, err error) {
 	if err != nil && (tp.cfg.errCheck == nil || tp.cfg.errCheck(err)) {
 		span.SetTag(ext.Error, err)
 	}
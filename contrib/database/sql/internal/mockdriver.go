@@ -15,6 +15,8 @@ import (
 type MockDriver struct {
 	Prepared []string
 	Executed []string
+	// Rows is the number of rows returned by queries.
+	Rows int
 }
 
 // Open implements the Conn interface
@@ -35,7 +37,7 @@ func (m *mockConn) Prepare(query string) (driver.Stmt, error) {
 // QueryContext implements the QueryerContext interface
 func (m *mockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	m.driver.Executed = append(m.driver.Executed, query)
-	return &rows{}, nil
+	return &rows{n: m.driver.Rows}, nil
 }
 
 // ExecContext implements the ExecerContext interface
@@ -54,7 +56,9 @@ func (m *mockConn) Begin() (driver.Tx, error) {
 	return &mockTx{driver: m.driver}, nil
 }
 
-type rows struct{}
+type rows struct {
+	n int
+}
 
 // Columns implements the Rows interface
 func (r *rows) Columns() []string {
@@ -68,7 +72,11 @@ func (r *rows) Close() error {
 
 // Next implements the Rows interface
 func (r *rows) Next(dest []driver.Value) error {
-	return io.EOF
+	if r.n == 0 {
+		return io.EOF
+	}
+	r.n--
+	return nil
 }
 
 type mockTx struct {
@@ -109,7 +117,7 @@ func (s *mockStmt) Exec(args []driver.Value) (driver.Result, error) {
 // Query implements the Stmt interface
 func (s *mockStmt) Query(args []driver.Value) (driver.Rows, error) {
 	s.driver.Executed = append(s.driver.Executed, s.stmt)
-	return &rows{}, nil
+	return &rows{n: s.driver.Rows}, nil
 }
 
 // ExecContext implements the StmtExecContext interface
@@ -121,7 +129,7 @@ func (s *mockStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (d
 // QueryContext implements the StmtQueryContext interface
 func (s *mockStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
 	s.driver.Executed = append(s.driver.Executed, s.stmt)
-	return &rows{}, nil
+	return &rows{n: s.driver.Rows}, nil
 }
 
 type mockResult struct{}
@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+)
+
+// Metrics reported from sql.DBStats when WithDBStats is used.
+const (
+	metricMaxOpenConnections = "datadog.tracer.sql.db.connections.max_open"
+	metricOpenConnections    = "datadog.tracer.sql.db.connections.open"
+	metricInUse              = "datadog.tracer.sql.db.connections.in_use"
+	metricIdle               = "datadog.tracer.sql.db.connections.idle"
+	metricWaitCount          = "datadog.tracer.sql.db.connections.waiting"
+	metricWaitDuration       = "datadog.tracer.sql.db.connections.wait_duration"
+	metricMaxIdleClosed      = "datadog.tracer.sql.db.connections.closed.max_idle_conns"
+	metricMaxIdleTimeClosed  = "datadog.tracer.sql.db.connections.closed.max_idle_time"
+	metricMaxLifetimeClosed  = "datadog.tracer.sql.db.connections.closed.max_lifetime"
+)
+
+// Metrics set on the next query span after the poller observed callers waiting
+// for a free connection.
+const (
+	tagPoolWaitCount    = "db.pool.wait_count"
+	tagPoolWaitDuration = "db.pool.wait_duration"
+)
+
+// dbStatsInterval is the interval at which sql.DBStats are reported; replaced in tests.
+var dbStatsInterval = 10 * time.Second
+
+// dbStatsPoller periodically reports the connection pool statistics of a sql.DB
+// to the statsd client of the running tracer.
+type dbStatsPoller struct {
+	driverName string
+	cfg        *config
+	exit       chan struct{}
+	done       chan struct{}
+
+	last sql.DBStats
+
+	waiting   int32         // waiting is 1 when waitCount is non-zero, so that spans can skip locking mu
+	mu        sync.Mutex    // guards waitCount and waitTotal
+	waitCount int64         // waitCount is the number of waits observed and not yet set on a span
+	waitTotal time.Duration // waitTotal is the duration of these waits
+}
+
+func newDBStatsPoller(driverName string, cfg *config) *dbStatsPoller {
+	return &dbStatsPoller{
+		driverName: driverName,
+		cfg:        cfg,
+		exit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// start reports the statistics of db until stop is called.
+func (p *dbStatsPoller) start(db *sql.DB) {
+	go func() {
+		defer close(p.done)
+		tick := time.NewTicker(dbStatsInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				p.report(db.Stats())
+			case <-p.exit:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops reporting statistics and waits for the poller to return.
+func (p *dbStatsPoller) stop() {
+	close(p.exit)
+	<-p.done
+}
+
+// report sends s to the tracer's statsd client. The cumulative counters of s are
+// reported as their increase since the last report. The waits for a free
+// connection are additionally kept to be set on the next query span.
+func (p *dbStatsPoller) report(s sql.DBStats) {
+	last := p.last
+	p.last = s
+	if client := globalconfig.Statsd(); client != nil {
+		tags := []string{"db.system:" + p.driverName, "service:" + p.cfg.serviceName}
+		client.Gauge(metricMaxOpenConnections, float64(s.MaxOpenConnections), tags, 1)
+		client.Gauge(metricOpenConnections, float64(s.OpenConnections), tags, 1)
+		client.Gauge(metricInUse, float64(s.InUse), tags, 1)
+		client.Gauge(metricIdle, float64(s.Idle), tags, 1)
+		client.Count(metricWaitCount, s.WaitCount-last.WaitCount, tags, 1)
+		client.Timing(metricWaitDuration, s.WaitDuration-last.WaitDuration, tags, 1)
+		client.Count(metricMaxIdleClosed, s.MaxIdleClosed-last.MaxIdleClosed, tags, 1)
+		client.Count(metricMaxIdleTimeClosed, s.MaxIdleTimeClosed-last.MaxIdleTimeClosed, tags, 1)
+		client.Count(metricMaxLifetimeClosed, s.MaxLifetimeClosed-last.MaxLifetimeClosed, tags, 1)
+	}
+	if count := s.WaitCount - last.WaitCount; count > 0 {
+		p.mu.Lock()
+		p.waitCount += count
+		p.waitTotal += s.WaitDuration - last.WaitDuration
+		atomic.StoreInt32(&p.waiting, 1)
+		p.mu.Unlock()
+	}
+}
+
+// takeWait returns the number and the total duration of the waits for a free
+// connection observed since the last call, as database/sql does not expose
+// the acquisition of individual connections.
+func (p *dbStatsPoller) takeWait() (count int64, total time.Duration) {
+	if atomic.LoadInt32(&p.waiting) == 0 {
+		return 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	count, total = p.waitCount, p.waitTotal
+	p.waitCount, p.waitTotal = 0, 0
+	atomic.StoreInt32(&p.waiting, 0)
+	return count, total
+}
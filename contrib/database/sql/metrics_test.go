@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+)
+
+type testStatsdClient struct {
+	mu     sync.Mutex
+	values map[string]float64
+	tags   []string
+}
+
+func newTestStatsdClient() *testStatsdClient {
+	return &testStatsdClient{values: make(map[string]float64)}
+}
+
+func (c *testStatsdClient) record(name string, value float64, tags []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[name] = value
+	c.tags = tags
+	return nil
+}
+
+func (c *testStatsdClient) Count(name string, value int64, tags []string, _ float64) error {
+	return c.record(name, float64(value), tags)
+}
+
+func (c *testStatsdClient) Gauge(name string, value float64, tags []string, _ float64) error {
+	return c.record(name, value, tags)
+}
+
+func (c *testStatsdClient) Timing(name string, value time.Duration, tags []string, _ float64) error {
+	return c.record(name, float64(value), tags)
+}
+
+func (c *testStatsdClient) value(name string) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[name]
+	return v, ok
+}
+
+func TestDBStatsReport(t *testing.T) {
+	client := newTestStatsdClient()
+	globalconfig.SetStatsd(client)
+	defer globalconfig.SetStatsd(nil)
+
+	p := newDBStatsPoller("postgres", &config{serviceName: "postgres.db"})
+	p.report(sql.DBStats{MaxOpenConnections: 4, OpenConnections: 4, InUse: 3, Idle: 1, WaitCount: 2, WaitDuration: time.Second})
+	p.report(sql.DBStats{MaxOpenConnections: 4, OpenConnections: 4, InUse: 4, WaitCount: 5, WaitDuration: 3 * time.Second, MaxIdleClosed: 1})
+
+	for name, want := range map[string]float64{
+		metricMaxOpenConnections: 4,
+		metricOpenConnections:    4,
+		metricInUse:              4,
+		metricIdle:               0,
+		metricWaitCount:          3,
+		metricWaitDuration:       float64(2 * time.Second),
+		metricMaxIdleClosed:      1,
+		metricMaxIdleTimeClosed:  0,
+		metricMaxLifetimeClosed:  0,
+	} {
+		v, ok := client.value(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, want, v, name)
+	}
+	assert.Equal(t, []string{"db.system:postgres", "service:postgres.db"}, client.tags)
+
+	count, wait := p.takeWait()
+	assert.Equal(t, int64(5), count)
+	assert.Equal(t, 3*time.Second, wait)
+	count, wait = p.takeWait()
+	assert.Zero(t, count)
+	assert.Zero(t, wait)
+
+}
+
+func TestDBStatsWaitSpanMetrics(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	p := newDBStatsPoller("postgres", &config{serviceName: "postgres.db"})
+	tp := &traceParams{cfg: p.cfg, driverName: "postgres", poller: p}
+	p.report(sql.DBStats{WaitCount: 2, WaitDuration: time.Second})
+	tp.tryTrace(context.Background(), queryTypeQuery, "SELECT 1", time.Now(), nil)
+	tp.tryTrace(context.Background(), queryTypeQuery, "SELECT 1", time.Now(), nil)
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, int64(2), spans[0].Tag(tagPoolWaitCount))
+	assert.Equal(t, time.Second.Nanoseconds(), spans[0].Tag(tagPoolWaitDuration))
+	// the waits are only set on the first span following the report
+	assert.Nil(t, spans[1].Tag(tagPoolWaitCount))
+	assert.Nil(t, spans[1].Tag(tagPoolWaitDuration))
+}
+
+func TestWithDBStats(t *testing.T) {
+	defer func(interval time.Duration) { dbStatsInterval = interval }(dbStatsInterval)
+	dbStatsInterval = time.Millisecond
+	client := newTestStatsdClient()
+	globalconfig.SetStatsd(client)
+	defer globalconfig.SetStatsd(nil)
+
+	Register("test", &internal.MockDriver{}, WithDBStats())
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(2)
+
+	assert.Eventually(t, func() bool {
+		v, ok := client.value(metricMaxOpenConnections)
+		return ok && v == 2
+	}, time.Second, time.Millisecond)
+	require.NoError(t, db.Close())
+}
@@ -21,6 +21,9 @@ type config struct {
 	errCheck           func(err error) bool
 	tags               map[string]interface{}
 	dbmPropagationMode tracer.DBMPropagationMode
+	dbStats            bool
+	traceRows          bool
+	traceTransactions  bool
 }
 
 // Option represents an option that can be passed to Register, Open or OpenDB.
@@ -134,3 +137,32 @@ func WithDBMPropagation(mode tracer.DBMPropagationMode) Option {
 		cfg.dbmPropagationMode = mode
 	}
 }
+
+// WithDBStats enables reporting the connection pool statistics of the database (sql.DBStats)
+// to the statsd client of the running tracer every 10 seconds. The metrics are tagged with
+// the driver name as db.system and with the service name. When callers had to wait for a free
+// connection, the number and the total duration of these waits are also set on the next query
+// span as the db.pool.wait_count and db.pool.wait_duration metrics.
+func WithDBStats() Option {
+	return func(cfg *config) {
+		cfg.dbStats = true
+	}
+}
+
+// WithRowsTracing enables tracing the iteration of the rows returned by queries. The rows
+// fetched by a query are recorded as a child span of the query span, starting when the query
+// returns and finishing when the rows are closed, and tagged with the number of rows fetched.
+func WithRowsTracing() Option {
+	return func(cfg *config) {
+		cfg.traceRows = true
+	}
+}
+
+// WithTransactionTracing enables tracing transactions as a whole. The span of a transaction
+// starts with Begin, finishes with Commit or Rollback and is the parent of the queries and
+// statements executed within it.
+func WithTransactionTracing() Option {
+	return func(cfg *config) {
+		cfg.traceTransactions = true
+	}
+}
@@ -0,0 +1,136 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// tagRowCount holds the number of rows fetched from the rows of a query.
+const tagRowCount = "db.row_count"
+
+var (
+	_ driver.Rows                           = (*tracedRows)(nil)
+	_ driver.RowsNextResultSet              = (*tracedRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*tracedRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*tracedRows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*tracedRows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*tracedRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*tracedRows)(nil)
+)
+
+// tracedRows is a traced version of driver.Rows, recording the fetching of the
+// rows as a child span of the query span.
+type tracedRows struct {
+	driver.Rows
+	*traceParams
+	span  ddtrace.Span
+	count int
+	err   error
+}
+
+// wrapRows returns rows traced as a child of the query span when rows tracing
+// is enabled.
+func (tp *traceParams) wrapRows(rows driver.Rows, span ddtrace.Span, query string) driver.Rows {
+	if !tp.cfg.traceRows || rows == nil || span == nil {
+		return rows
+	}
+	opts := append(tp.spanOptions(time.Now()),
+		tracer.ChildOf(span.Context()),
+		tracer.ResourceName(query),
+	)
+	rs := tracer.StartSpan(fmt.Sprintf("%s.rows", tp.driverName), opts...)
+	for k, v := range tp.meta {
+		rs.SetTag(k, v)
+	}
+	return &tracedRows{Rows: rows, traceParams: tp, span: rs}
+}
+
+// Next counts the fetched rows and records the first error.
+func (r *tracedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case err != io.EOF && r.err == nil:
+		r.err = err
+	}
+	return err
+}
+
+// Close finishes the span of the rows.
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	if r.err == nil {
+		r.err = err
+	}
+	r.span.SetTag(tagRowCount, r.count)
+	r.finishSpan(r.span, r.err)
+	return err
+}
+
+// HasNextResultSet implements driver.RowsNextResultSet.
+func (r *tracedRows) HasNextResultSet() bool {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+// NextResultSet implements driver.RowsNextResultSet.
+func (r *tracedRows) NextResultSet() error {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (r *tracedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rs.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+// ColumnTypeLength implements driver.RowsColumnTypeLength.
+func (r *tracedRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return rs.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+// ColumnTypeNullable implements driver.RowsColumnTypeNullable.
+func (r *tracedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rs.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+// ColumnTypePrecisionScale implements driver.RowsColumnTypePrecisionScale.
+func (r *tracedRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return rs.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType.
+func (r *tracedRows) ColumnTypeScanType(index int) reflect.Type {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return rs.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
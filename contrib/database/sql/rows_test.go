@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestRowsTracing(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("test", &internal.MockDriver{Rows: 3}, WithRowsTracing())
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Run("query", func(t *testing.T) {
+		mt.Reset()
+		rows, err := db.QueryContext(context.Background(), "SELECT 1")
+		require.NoError(t, err)
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		require.NoError(t, rows.Close())
+		assert.Equal(t, 3, n)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 3) // connect, query, rows
+		query, rs := spans[1], spans[2]
+		assert.Equal(t, "test.query", query.OperationName())
+		assert.Equal(t, "test.rows", rs.OperationName())
+		assert.Equal(t, query.SpanID(), rs.ParentID())
+		assert.Equal(t, "SELECT 1", rs.Tag(ext.ResourceName))
+		assert.Equal(t, "test.db", rs.Tag(ext.ServiceName))
+		assert.Equal(t, ext.SpanTypeSQL, rs.Tag(ext.SpanType))
+		assert.Equal(t, "database/sql", rs.Tag(ext.Component))
+		assert.Equal(t, 3, rs.Tag(tagRowCount))
+		assert.Nil(t, rs.Tag(ext.Error))
+	})
+
+	t.Run("stmt", func(t *testing.T) {
+		mt.Reset()
+		stmt, err := db.PrepareContext(context.Background(), "SELECT 2")
+		require.NoError(t, err)
+		defer stmt.Close()
+		rows, err := stmt.QueryContext(context.Background())
+		require.NoError(t, err)
+		rows.Next()
+		require.NoError(t, rows.Close())
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 3) // prepare, query, rows
+		query, rs := spans[1], spans[2]
+		assert.Equal(t, "Query", query.Tag("sql.query_type"))
+		assert.Equal(t, query.SpanID(), rs.ParentID())
+		assert.Equal(t, "SELECT 2", rs.Tag(ext.ResourceName))
+		assert.Equal(t, 1, rs.Tag(tagRowCount))
+	})
+}
+
+func TestRowsTracingDisabled(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("test", &internal.MockDriver{Rows: 3})
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	for _, s := range mt.FinishedSpans() {
+		assert.Equal(t, "test.query", s.OperationName())
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"io"
 	"math"
 	"reflect"
 	"sync"
@@ -142,12 +143,14 @@ type tracedConnector struct {
 	connector  driver.Connector
 	driverName string
 	cfg        *config
+	poller     *dbStatsPoller
 }
 
 func (t *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
 	tp := &traceParams{
 		driverName: t.driverName,
 		cfg:        t.cfg,
+		poller:     t.poller,
 	}
 	if dc, ok := t.connector.(*dsnConnector); ok {
 		tp.meta, _ = internal.ParseDSN(t.driverName, dc.dsn)
@@ -160,13 +163,25 @@ func (t *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &tracedConn{conn, tp}, err
+	return &tracedConn{Conn: conn, traceParams: tp}, err
 }
 
 func (t *tracedConnector) Driver() driver.Driver {
 	return t.connector.Driver()
 }
 
+// Close is called by sql.DB.Close. It stops reporting the statistics of the
+// database and closes the underlying connector if it implements io.Closer.
+func (t *tracedConnector) Close() error {
+	if t.poller != nil {
+		t.poller.stop()
+	}
+	if c, ok := t.connector.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // from Go stdlib implementation of sql.Open
 type dsnConnector struct {
 	dsn    string
@@ -208,12 +223,28 @@ func OpenDB(c driver.Connector, opts ...Option) *sql.DB {
 		cfg.dbmPropagationMode = rc.dbmPropagationMode
 	}
 	cfg.childSpansOnly = rc.childSpansOnly
+	if !cfg.traceRows {
+		cfg.traceRows = rc.traceRows
+	}
+	if !cfg.traceTransactions {
+		cfg.traceTransactions = rc.traceTransactions
+	}
+	if !cfg.dbStats {
+		cfg.dbStats = rc.dbStats
+	}
 	tc := &tracedConnector{
 		connector:  c,
 		driverName: name,
 		cfg:        cfg,
 	}
-	return sql.OpenDB(tc)
+	if cfg.dbStats {
+		tc.poller = newDBStatsPoller(name, cfg)
+	}
+	db := sql.OpenDB(tc)
+	if tc.poller != nil {
+		tc.poller.start(db)
+	}
+	return db
 }
 
 // Open returns connection to a DB using the traced version of the given driver. In order for Open
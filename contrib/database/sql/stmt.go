@@ -20,6 +20,16 @@ type tracedStmt struct {
 	*traceParams
 	ctx   context.Context
 	query string
+	conn  *tracedConn
+}
+
+// txContext returns a copy of ctx holding the span of the transaction in
+// progress on the connection of the statement, if any.
+func (s *tracedStmt) txContext(ctx context.Context) context.Context {
+	if s.conn == nil {
+		return ctx
+	}
+	return s.conn.txContext(ctx)
 }
 
 // Close sends a span before closing a statement
@@ -33,6 +43,7 @@ func (s *tracedStmt) Close() (err error) {
 // ExecContext is needed to implement the driver.StmtExecContext interface
 func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
 	start := time.Now()
+	ctx = s.txContext(ctx)
 	if stmtExecContext, ok := s.Stmt.(driver.StmtExecContext); ok {
 		res, err := stmtExecContext.ExecContext(ctx, args)
 		s.tryTrace(ctx, queryTypeExec, s.query, start, err)
@@ -55,10 +66,11 @@ func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue)
 // QueryContext is needed to implement the driver.StmtQueryContext interface
 func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
 	start := time.Now()
+	ctx = s.txContext(ctx)
 	if stmtQueryContext, ok := s.Stmt.(driver.StmtQueryContext); ok {
 		rows, err := stmtQueryContext.QueryContext(ctx, args)
-		s.tryTrace(ctx, queryTypeQuery, s.query, start, err)
-		return rows, err
+		span := s.tryTrace(ctx, queryTypeQuery, s.query, start, err)
+		return s.wrapRows(rows, span, s.query), err
 	}
 	dargs, err := namedValueToValue(args)
 	if err != nil {
@@ -70,8 +82,8 @@ func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue)
 	default:
 	}
 	rows, err = s.Query(dargs)
-	s.tryTrace(ctx, queryTypeQuery, s.query, start, err)
-	return rows, err
+	span := s.tryTrace(ctx, queryTypeQuery, s.query, start, err)
+	return s.wrapRows(rows, span, s.query), err
 }
 
 // copied from stdlib database/sql package: src/database/sql/ctxutil.go
@@ -9,6 +9,8 @@ import (
 	"context"
 	"database/sql/driver"
 	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 )
 
 var _ driver.Tx = (*tracedTx)(nil)
@@ -17,7 +19,10 @@ var _ driver.Tx = (*tracedTx)(nil)
 type tracedTx struct {
 	driver.Tx
 	*traceParams
-	ctx context.Context
+	ctx  context.Context
+	conn *tracedConn
+	// span covers the whole transaction when transaction tracing is enabled.
+	span ddtrace.Span
 }
 
 // Commit sends a span at the end of the transaction
@@ -25,6 +30,7 @@ func (t *tracedTx) Commit() (err error) {
 	start := time.Now()
 	err = t.Tx.Commit()
 	t.tryTrace(t.ctx, queryTypeCommit, "", start, err)
+	t.finish(err)
 	return err
 }
 
@@ -33,5 +39,18 @@ func (t *tracedTx) Rollback() (err error) {
 	start := time.Now()
 	err = t.Tx.Rollback()
 	t.tryTrace(t.ctx, queryTypeRollback, "", start, err)
+	t.finish(err)
 	return err
 }
+
+// finish finishes the span of the transaction, if any, and detaches the
+// transaction from its connection.
+func (t *tracedTx) finish(err error) {
+	if t.span == nil {
+		return
+	}
+	if t.conn.tx == t {
+		t.conn.tx = nil
+	}
+	t.finishSpan(t.span, err)
+}
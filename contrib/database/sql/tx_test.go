@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestTransactionTracing(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("test", &internal.MockDriver{}, WithTransactionTracing())
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Ping())
+
+	for _, end := range []string{"Commit", "Rollback"} {
+		t.Run(end, func(t *testing.T) {
+			mt.Reset()
+			root, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+			tx, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, "INSERT 1")
+			require.NoError(t, err)
+			stmt, err := tx.PrepareContext(ctx, "INSERT 2")
+			require.NoError(t, err)
+			_, err = stmt.ExecContext(ctx)
+			require.NoError(t, err)
+			require.NoError(t, stmt.Close())
+			if end == "Commit" {
+				require.NoError(t, tx.Commit())
+			} else {
+				require.NoError(t, tx.Rollback())
+			}
+			// queries run after the transaction are not its children
+			_, err = db.ExecContext(ctx, "INSERT 3")
+			require.NoError(t, err)
+			root.Finish()
+
+			spans := mt.FinishedSpans()
+			var txSpan mocktracer.Span
+			for _, s := range spans {
+				if s.OperationName() == "test.transaction" {
+					txSpan = s
+				}
+			}
+			require.NotNil(t, txSpan)
+			assert.Equal(t, root.Context().SpanID(), txSpan.ParentID())
+			assert.Equal(t, "Transaction", txSpan.Tag(ext.ResourceName))
+			assert.Equal(t, "test.db", txSpan.Tag(ext.ServiceName))
+			assert.Equal(t, "database/sql", txSpan.Tag(ext.Component))
+
+			byType := make(map[string]mocktracer.Span)
+			for _, s := range spans {
+				if s.OperationName() != "test.query" {
+					continue
+				}
+				byType[s.Tag("sql.query_type").(string)+" "+s.Tag(ext.ResourceName).(string)] = s
+			}
+			for _, k := range []string{"Begin Begin", "Exec INSERT 1", "Prepare INSERT 2", "Exec INSERT 2", "Close Close", end + " " + end} {
+				s, ok := byType[k]
+				require.True(t, ok, k)
+				assert.Equal(t, txSpan.SpanID(), s.ParentID(), k)
+			}
+			assert.Equal(t, root.Context().SpanID(), byType["Exec INSERT 3"].ParentID())
+		})
+	}
+}
@@ -16,7 +16,10 @@ import (
 )
 
 // useAppSec executes the AppSec logic related to the operation start and
-// returns the  function to be executed upon finishing the operation
+// returns the  function to be executed upon finishing the operation. The
+// request is aborted with the blocked response when AppSec blocks it, and the
+// blocked response is written upon finishing when AppSec blocked it while it
+// was handled and no response was written.
 func useAppSec(c *gin.Context, span tracer.Span) func() {
 	req := c.Request
 	instrumentation.SetAppSecEnabledTags(span)
@@ -30,8 +33,20 @@ func useAppSec(c *gin.Context, span tracer.Span) func() {
 	args := httpsec.MakeHandlerOperationArgs(req, params)
 	ctx, op := httpsec.StartOperation(req.Context(), args)
 	c.Request = req.WithContext(ctx)
+	if op.Blocked() {
+		httpsec.WriteBlockedResponse(c.Writer, c.Request)
+		c.Abort()
+	} else {
+		httpsec.MonitorRequestBody(c.Request)
+	}
 	return func() {
-		events := op.Finish(httpsec.HandlerOperationRes{Status: c.Writer.Status()})
+		// The request can also get blocked while being handled, for instance
+		// by the user blocking SDK. Write the blocked response when the
+		// handler did not already write a response.
+		if op.Blocked() && !c.Writer.Written() {
+			httpsec.WriteBlockedResponse(c.Writer, c.Request)
+		}
+		events := op.Finish(httpsec.HandlerOperationRes{Status: c.Writer.Status(), Headers: httpsec.MakeResponseHeaders(c.Writer.Header())})
 		if len(events) > 0 {
 			remoteIP, _, err := net.SplitHostPort(req.RemoteAddr)
 			if err != nil {
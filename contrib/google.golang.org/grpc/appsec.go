@@ -15,12 +15,16 @@ import (
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 // UnaryHandler wrapper to use when AppSec is enabled to monitor its execution.
-func appsecUnaryHandlerMiddleware(span ddtrace.Span, handler grpc.UnaryHandler) grpc.UnaryHandler {
+// The handler isn't called when AppSec blocks the RPC, in which case the
+// blockedCode status error is returned.
+func appsecUnaryHandlerMiddleware(span ddtrace.Span, handler grpc.UnaryHandler, blockedCode codes.Code) grpc.UnaryHandler {
 	instrumentation.SetAppSecEnabledTags(span)
 	return func(ctx context.Context, req interface{}) (interface{}, error) {
 		md, _ := metadata.FromIncomingContext(ctx)
@@ -33,13 +37,21 @@ func appsecUnaryHandlerMiddleware(span ddtrace.Span, handler grpc.UnaryHandler)
 			}
 			setAppSecTags(ctx, span, events)
 		}()
-		defer grpcsec.StartReceiveOperation(grpcsec.ReceiveOperationArgs{}, op).Finish(grpcsec.ReceiveOperationRes{Message: req})
+		// The message of unary RPCs is known before calling the handler, so
+		// that it can be blocked before it gets executed.
+		grpcsec.StartReceiveOperation(grpcsec.ReceiveOperationArgs{}, op).Finish(grpcsec.ReceiveOperationRes{Message: req})
+		if op.Blocked() {
+			return nil, blockedError(blockedCode)
+		}
 		return handler(ctx, req)
 	}
 }
 
 // StreamHandler wrapper to use when AppSec is enabled to monitor its execution.
-func appsecStreamHandlerMiddleware(span ddtrace.Span, handler grpc.StreamHandler) grpc.StreamHandler {
+// The handler isn't called when AppSec blocks the RPC based on its metadata,
+// and its calls to RecvMsg return the blockedCode status error once a received
+// message gets blocked.
+func appsecStreamHandlerMiddleware(span ddtrace.Span, handler grpc.StreamHandler, blockedCode codes.Code) grpc.StreamHandler {
 	instrumentation.SetAppSecEnabledTags(span)
 	return func(srv interface{}, stream grpc.ServerStream) error {
 		md, _ := metadata.FromIncomingContext(stream.Context())
@@ -52,23 +64,37 @@ func appsecStreamHandlerMiddleware(span ddtrace.Span, handler grpc.StreamHandler
 			}
 			setAppSecTags(stream.Context(), span, events)
 		}()
-		return handler(srv, appsecServerStream{ServerStream: stream, handlerOperation: op})
+		if op.Blocked() {
+			return blockedError(blockedCode)
+		}
+		return handler(srv, appsecServerStream{ServerStream: stream, handlerOperation: op, blockedCode: blockedCode})
 	}
 }
 
 type appsecServerStream struct {
 	grpc.ServerStream
 	handlerOperation *grpcsec.HandlerOperation
+	blockedCode      codes.Code
 }
 
 // RecvMsg implements grpc.ServerStream interface method to monitor its
 // execution with AppSec.
 func (ss appsecServerStream) RecvMsg(m interface{}) error {
+	if ss.handlerOperation.Blocked() {
+		return blockedError(ss.blockedCode)
+	}
 	op := grpcsec.StartReceiveOperation(grpcsec.ReceiveOperationArgs{}, ss.handlerOperation)
-	defer func() {
-		op.Finish(grpcsec.ReceiveOperationRes{Message: m})
-	}()
-	return ss.ServerStream.RecvMsg(m)
+	err := ss.ServerStream.RecvMsg(m)
+	op.Finish(grpcsec.ReceiveOperationRes{Message: m})
+	if err == nil && ss.handlerOperation.Blocked() {
+		return blockedError(ss.blockedCode)
+	}
+	return err
+}
+
+// blockedError returns the status error of the RPCs blocked by AppSec.
+func blockedError(code codes.Code) error {
+	return status.Error(code, "Request blocked")
 }
 
 // Set the AppSec tags when security events were found.
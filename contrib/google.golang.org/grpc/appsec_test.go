@@ -7,14 +7,26 @@ package grpc
 
 import (
 	"context"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/grpcsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestAppSec(t *testing.T) {
@@ -23,6 +35,9 @@ func TestAppSec(t *testing.T) {
 	if !appsec.Enabled() {
 		t.Skip("appsec disabled")
 	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available and its denylist fallback only protects http requests")
+	}
 
 	rig, err := newRig(false)
 	require.NoError(t, err)
@@ -91,6 +106,277 @@ func TestAppSec(t *testing.T) {
 		require.NotNil(t, event)
 		require.True(t, strings.Contains(event, "crs-941-110")) // XSS attack attempt
 		require.True(t, strings.Contains(event, "crs-942-100")) // SQL-injection attack attempt
-		require.True(t, strings.Contains(event, "ua0-600-55x")) // canary rule attack attempt
+		// The canary rule only involves the metadata and must be reported once
+		// regardless of the number of messages.
+		require.Equal(t, 1, strings.Count(event, "ua0-600-55x"))
+	})
+}
+
+// blockingRules is a ruleset blocking the RPCs whose metadata or message
+// contains "blocking-test", or whose metadata and message both contain
+// "combined-test".
+const blockingRules = `{
+  "version": "2.2",
+  "metadata": {"rules_version": "1.4.2"},
+  "rules": [
+    {
+      "id": "block-metadata",
+      "name": "Block metadata",
+      "tags": {"type": "security_scanner", "category": "attack_attempt"},
+      "conditions": [
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "grpc.server.request.metadata", "key_path": ["dd-block"]}], "regex": "blocking-test"}
+        }
+      ],
+      "transformers": [],
+      "on_match": ["block"]
+    },
+    {
+      "id": "block-message",
+      "name": "Block message",
+      "tags": {"type": "security_scanner", "category": "attack_attempt"},
+      "conditions": [
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "grpc.server.request.message"}], "regex": "blocking-test"}
+        }
+      ],
+      "transformers": [],
+      "on_match": ["block"]
+    },
+    {
+      "id": "block-combined",
+      "name": "Block metadata and message",
+      "tags": {"type": "security_scanner", "category": "attack_attempt"},
+      "conditions": [
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "grpc.server.request.metadata", "key_path": ["dd-combined"]}], "regex": "combined-test"}
+        },
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "grpc.server.request.message"}], "regex": "combined-test"}
+        }
+      ],
+      "transformers": [],
+      "on_match": ["block"]
+    }
+  ]
+}`
+
+func TestAppSecBlocking(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(rules, []byte(blockingRules), 0644))
+	t.Setenv("DD_APPSEC_RULES", rules)
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("appsec disabled")
+	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available and its denylist fallback only protects http requests")
+	}
+
+	rig, err := newRig(false, WithBlockedStatusCode(codes.PermissionDenied))
+	require.NoError(t, err)
+	defer rig.Close()
+	client := rig.client
+
+	t.Run("unary-metadata", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("dd-block", "blocking-test"))
+		_, err := client.Ping(ctx, &FixtureRequest{Name: "hello"})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		finished := mt.FinishedSpans()
+		require.Len(t, finished, 1)
+		require.Equal(t, true, finished[0].Tag(instrumentation.BlockedRequestTag))
+		require.Contains(t, finished[0].Tag("_dd.appsec.json"), "block-metadata")
+	})
+
+	t.Run("unary-message", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		_, err := client.Ping(context.Background(), &FixtureRequest{Name: "blocking-test"})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		finished := mt.FinishedSpans()
+		require.Len(t, finished, 1)
+		require.Equal(t, true, finished[0].Tag(instrumentation.BlockedRequestTag))
+		require.Contains(t, finished[0].Tag("_dd.appsec.json"), "block-message")
+	})
+
+	t.Run("unary-metadata-and-message", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		// neither the metadata nor the message alone are enough to match
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("dd-combined", "combined-test"))
+		_, err := client.Ping(ctx, &FixtureRequest{Name: "hello"})
+		require.NoError(t, err)
+		_, err = client.Ping(context.Background(), &FixtureRequest{Name: "combined-test"})
+		require.NoError(t, err)
+
+		_, err = client.Ping(ctx, &FixtureRequest{Name: "combined-test"})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		finished := mt.FinishedSpans()
+		require.Len(t, finished, 3)
+		require.Equal(t, true, finished[2].Tag(instrumentation.BlockedRequestTag))
+		require.Contains(t, finished[2].Tag("_dd.appsec.json"), "block-combined")
+	})
+
+	t.Run("stream-message", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		stream, err := client.StreamPing(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&FixtureRequest{Name: "hello"}))
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "passed", res.Message)
+
+		require.NoError(t, stream.Send(&FixtureRequest{Name: "blocking-test"}))
+		_, err = stream.Recv()
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		waitForSpans(mt, 4, 0)
+		finished := mt.FinishedSpans()
+		var blocked bool
+		for _, s := range finished {
+			if s.Tag(instrumentation.BlockedRequestTag) == true {
+				blocked = true
+				require.Contains(t, s.Tag("_dd.appsec.json"), "block-message")
+			}
+		}
+		require.True(t, blocked)
+	})
+}
+
+// TestAppSecBlockingMiddleware checks the AppSec middlewares honour the
+// blocking decisions of the grpcsec operation listeners, independently of the
+// WAF.
+func TestAppSecBlockingMiddleware(t *testing.T) {
+	// Block the RPCs sent with the dd-block metadata key or with a message
+	// named "block".
+	unregister := dyngo.Register(grpcsec.OnHandlerOperationStart(func(op *grpcsec.HandlerOperation, args grpcsec.HandlerOperationArgs) {
+		if _, ok := args.Metadata["dd-block"]; ok {
+			op.Block()
+		}
+		op.On(grpcsec.OnReceiveOperationFinish(func(_ grpcsec.ReceiveOperation, res grpcsec.ReceiveOperationRes) {
+			if req, ok := res.Message.(*FixtureRequest); ok && req.Name == "block" {
+				op.Block()
+			}
+		}))
+	}))
+	defer unregister()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var handlerCalls int32
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			span := tracer.StartSpan("grpc.server")
+			defer span.Finish()
+			handler = func(handler grpc.UnaryHandler) grpc.UnaryHandler {
+				return func(ctx context.Context, req interface{}) (interface{}, error) {
+					atomic.AddInt32(&handlerCalls, 1)
+					return handler(ctx, req)
+				}
+			}(handler)
+			return appsecUnaryHandlerMiddleware(span, handler, codes.PermissionDenied)(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			span := tracer.StartSpan("grpc.server")
+			defer span.Finish()
+			handler = func(handler grpc.StreamHandler) grpc.StreamHandler {
+				return func(srv interface{}, ss grpc.ServerStream) error {
+					atomic.AddInt32(&handlerCalls, 1)
+					return handler(srv, ss)
+				}
+			}(handler)
+			return appsecStreamHandlerMiddleware(span, handler, codes.Aborted)(srv, ss)
+		}),
+	)
+	RegisterFixtureServer(server, new(fixtureServer))
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(li)
+	defer server.Stop()
+	conn, err := grpc.Dial(li.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := NewFixtureClient(conn)
+
+	blockedTag := func(t *testing.T) interface{} {
+		t.Helper()
+		waitForSpans(mt, 1, 0)
+		finished := mt.FinishedSpans()
+		require.Len(t, finished, 1)
+		return finished[0].Tag(instrumentation.BlockedRequestTag)
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		defer mt.Reset()
+		atomic.StoreInt32(&handlerCalls, 0)
+		res, err := client.Ping(context.Background(), &FixtureRequest{Name: "hello"})
+		require.NoError(t, err)
+		require.Equal(t, "passed", res.Message)
+		require.Nil(t, blockedTag(t))
+		require.Equal(t, int32(1), atomic.LoadInt32(&handlerCalls))
+	})
+
+	t.Run("unary-metadata", func(t *testing.T) {
+		defer mt.Reset()
+		atomic.StoreInt32(&handlerCalls, 0)
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("dd-block", "1"))
+		_, err := client.Ping(ctx, &FixtureRequest{Name: "hello"})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		require.Equal(t, true, blockedTag(t))
+		require.Zero(t, atomic.LoadInt32(&handlerCalls))
+	})
+
+	t.Run("unary-message", func(t *testing.T) {
+		defer mt.Reset()
+		atomic.StoreInt32(&handlerCalls, 0)
+		_, err := client.Ping(context.Background(), &FixtureRequest{Name: "block"})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		require.Equal(t, true, blockedTag(t))
+		require.Zero(t, atomic.LoadInt32(&handlerCalls))
+	})
+
+	t.Run("stream-metadata", func(t *testing.T) {
+		defer mt.Reset()
+		atomic.StoreInt32(&handlerCalls, 0)
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("dd-block", "1"))
+		stream, err := client.StreamPing(ctx)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.Aborted, status.Code(err))
+		require.Equal(t, true, blockedTag(t))
+		require.Zero(t, atomic.LoadInt32(&handlerCalls))
+	})
+
+	t.Run("stream-message", func(t *testing.T) {
+		defer mt.Reset()
+		stream, err := client.StreamPing(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&FixtureRequest{Name: "hello"}))
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "passed", res.Message)
+
+		require.NoError(t, stream.Send(&FixtureRequest{Name: "block"}))
+		_, err = stream.Recv()
+		require.Equal(t, codes.Aborted, status.Code(err))
+		require.Equal(t, true, blockedTag(t))
 	})
 }
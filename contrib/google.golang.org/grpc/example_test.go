@@ -53,3 +53,25 @@ func Example_server() {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+func Example_statsHandler() {
+	// Create the stats handlers using the grpc trace package. They can be used instead
+	// of the interceptors and also record message counts, sizes and time to first byte.
+	ch := grpctrace.NewClientStatsHandler(grpctrace.WithServiceName("my-grpc-client"))
+	sh := grpctrace.NewServerStatsHandler(grpctrace.WithServiceName("my-grpc-server"))
+
+	// Initialize the grpc server as normal, using the tracing stats handler.
+	s := grpc.NewServer(grpc.StatsHandler(sh))
+	defer s.Stop()
+
+	// ... register your services and start serving
+
+	// Dial in using the client stats handler.
+	conn, err := grpc.Dial("localhost:50051", grpc.WithInsecure(), grpc.WithStatsHandler(ch))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	// And continue using the connection as normal.
+}
@@ -24,6 +24,7 @@ type config struct {
 	analyticsRate       float64
 	traceStreamCalls    bool
 	traceStreamMessages bool
+	payloadEvents       bool
 	noDebugStack        bool
 	ignoredMethods      map[string]struct{}
 	untracedMethods     map[string]struct{}
@@ -31,6 +32,7 @@ type config struct {
 	ignoredMetadata     map[string]struct{}
 	withRequestTags     bool
 	tags                map[string]interface{}
+	blockedCode         codes.Code
 }
 
 func (cfg *config) serverServiceName() string {
@@ -60,6 +62,7 @@ func defaults(cfg *config) {
 	cfg.traceStreamCalls = true
 	cfg.traceStreamMessages = true
 	cfg.nonErrorCodes = map[codes.Code]bool{codes.Canceled: true}
+	cfg.blockedCode = codes.Aborted
 	// cfg.analyticsRate = globalconfig.AnalyticsRate()
 	if internal.BoolEnv("DD_TRACE_GRPC_ANALYTICS_ENABLED", false) {
 		cfg.analyticsRate = 1.0
@@ -96,6 +99,15 @@ func WithStreamMessages(enabled bool) Option {
 	}
 }
 
+// WithPayloadEvents enables or disables recording a "grpc.message" span for every message
+// sent or received during an RPC. This option only applies to the stats handlers, which
+// always record the aggregated message counts and sizes on the RPC span.
+func WithPayloadEvents(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.payloadEvents = enabled
+	}
+}
+
 // NoDebugStack disables debug stacks for traces with errors. This is useful in situations
 // where errors are frequent and the overhead of calling debug.Stack may affect performance.
 func NoDebugStack() Option {
@@ -154,7 +166,8 @@ func WithIgnoredMethods(ms ...string) Option {
 }
 
 // WithUntracedMethods specifies full methods to be ignored by the server side and client
-// side interceptors. When a request's full method is in ms, no spans will be created.
+// side interceptors and stats handlers. When a request's full method is in ms, no spans
+// will be created.
 func WithUntracedMethods(ms ...string) Option {
 	ums := make(map[string]struct{}, len(ms))
 	for _, e := range ms {
@@ -198,3 +211,12 @@ func WithCustomTag(key string, value interface{}) Option {
 		cfg.tags[key] = value
 	}
 }
+
+// WithBlockedStatusCode sets the status code returned by the server
+// interceptors for the RPCs blocked by AppSec, such as codes.PermissionDenied.
+// Defaults to codes.Aborted.
+func WithBlockedStatusCode(code codes.Code) Option {
+	return func(cfg *config) {
+		cfg.blockedCode = code
+	}
+}
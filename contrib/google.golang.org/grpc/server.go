@@ -108,7 +108,7 @@ func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
 			}
 			defer func() { finishWithError(span, err, cfg) }()
 			if appsec.Enabled() {
-				handler = appsecStreamHandlerMiddleware(span, handler)
+				handler = appsecStreamHandlerMiddleware(span, handler, cfg.blockedCode)
 			}
 		}
 
@@ -164,7 +164,7 @@ func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
 			}
 		}
 		if appsec.Enabled() {
-			handler = appsecUnaryHandlerMiddleware(span, handler)
+			handler = appsecUnaryHandlerMiddleware(span, handler, cfg.blockedCode)
 		}
 		resp, err := handler(ctx, req)
 		finishWithError(span, err, cfg)
@@ -0,0 +1,131 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc/stats"
+)
+
+type rpcStatsKey struct{}
+
+// rpcStats holds the span of an RPC traced by a stats handler along with the
+// message and byte counts observed while the RPC was in flight. Stats handler
+// events of a single RPC may be reported concurrently, hence the mutex.
+type rpcStats struct {
+	span ddtrace.Span
+
+	mu            sync.Mutex
+	begin         time.Time
+	firstByte     time.Time
+	sentMessages  int
+	recvMessages  int
+	sentBytes     int
+	recvBytes     int
+	sentWireBytes int
+	recvWireBytes int
+}
+
+func contextWithRPCStats(ctx context.Context, span ddtrace.Span) context.Context {
+	return context.WithValue(ctx, rpcStatsKey{}, &rpcStats{span: span})
+}
+
+func rpcStatsFromContext(ctx context.Context) (*rpcStats, bool) {
+	s, ok := ctx.Value(rpcStatsKey{}).(*rpcStats)
+	return s, ok
+}
+
+// handle records rs. Inbound events are used as the first byte on the client
+// side, while outbound events are used as the first byte on the server side.
+func (s *rpcStats) handle(rs stats.RPCStats, cfg *config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch rs := rs.(type) {
+	case *stats.Begin:
+		s.begin = rs.BeginTime
+	case *stats.InHeader:
+		s.recvWireBytes += rs.WireLength
+		if rs.Compression != "" {
+			s.span.SetTag(tagCompression, rs.Compression)
+		}
+		if rs.Client {
+			s.markFirstByte(time.Now())
+		}
+	case *stats.OutHeader:
+		if rs.Compression != "" {
+			s.span.SetTag(tagCompression, rs.Compression)
+		}
+		if !rs.Client {
+			s.markFirstByte(time.Now())
+		}
+	case *stats.InPayload:
+		s.recvMessages++
+		s.recvBytes += rs.Length
+		s.recvWireBytes += rs.WireLength
+		if rs.Client {
+			s.markFirstByte(rs.RecvTime)
+		}
+		if cfg.payloadEvents {
+			s.payloadEvent(messageReceived, rs.RecvTime, s.recvMessages, rs.Length, rs.WireLength)
+		}
+	case *stats.OutPayload:
+		s.sentMessages++
+		s.sentBytes += rs.Length
+		s.sentWireBytes += rs.WireLength
+		if !rs.Client {
+			s.markFirstByte(rs.SentTime)
+		}
+		if cfg.payloadEvents {
+			s.payloadEvent(messageSent, rs.SentTime, s.sentMessages, rs.Length, rs.WireLength)
+		}
+	case *stats.InTrailer:
+		s.recvWireBytes += rs.WireLength
+	}
+}
+
+func (s *rpcStats) markFirstByte(t time.Time) {
+	if !s.firstByte.IsZero() || s.begin.IsZero() {
+		return
+	}
+	s.firstByte = t
+	s.span.SetTag(tagTimeToFirstByte, t.Sub(s.begin).Nanoseconds())
+}
+
+// payloadEvent records a message sent or received by the RPC as an instantaneous
+// child span of the RPC span.
+func (s *rpcStats) payloadEvent(direction string, t time.Time, seq, length, wireLength int) {
+	span := tracer.StartSpan("grpc.message",
+		tracer.ChildOf(s.span.Context()),
+		tracer.StartTime(t),
+		tracer.Tag(ext.Component, "google.golang.org/grpc"),
+		tracer.Tag(tagMessageDirection, direction),
+		tracer.Tag(tagMessageSeq, seq),
+		tracer.Tag(tagMessageSize, length),
+		tracer.Tag(tagMessageWireSize, wireLength),
+		spanTypeRPC,
+	)
+	span.Finish(tracer.FinishTime(t))
+}
+
+// finish sets the aggregated counts on the RPC span and finishes it.
+func (s *rpcStats) finish(end *stats.End, cfg *config) {
+	s.mu.Lock()
+	s.span.SetTag(tagMessagesSent, s.sentMessages)
+	s.span.SetTag(tagMessagesReceived, s.recvMessages)
+	s.span.SetTag(tagBytesSent, s.sentBytes)
+	s.span.SetTag(tagBytesReceived, s.recvBytes)
+	s.span.SetTag(tagWireBytesSent, s.sentWireBytes)
+	s.span.SetTag(tagWireBytesReceived, s.recvWireBytes)
+	s.mu.Unlock()
+	finishWithError(s.span, end.Error, cfg)
+}
@@ -6,22 +6,25 @@
 package grpc
 
 import (
-	"net"
-
 	context "golang.org/x/net/context"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/stats"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
 
-// NewClientStatsHandler returns a gRPC client stats.Handler to trace RPC calls.
+// NewClientStatsHandler returns a gRPC client stats.Handler to trace RPC calls. It can be used
+// as an alternative to the client interceptors: the span covers the whole RPC as seen by the
+// transport and is tagged with the message counts, sizes and time to first byte.
 func NewClientStatsHandler(opts ...Option) stats.Handler {
 	cfg := new(config)
 	defaults(cfg)
 	for _, fn := range opts {
 		fn(cfg)
 	}
+	log.Debug("contrib/google.golang.org/grpc: Configuring ClientStatsHandler: %#v", cfg)
 	return &clientStatsHandler{
 		cfg: cfg,
 	}
@@ -31,35 +34,40 @@ type clientStatsHandler struct{ cfg *config }
 
 // TagRPC starts a new span for the initiated RPC request.
 func (h *clientStatsHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) context.Context {
-	_, ctx = startSpanFromContext(
+	if _, ok := h.cfg.untracedMethods[rti.FullMethodName]; ok {
+		return ctx
+	}
+	span, ctx := startSpanFromContext(
 		ctx,
 		rti.FullMethodName,
 		"grpc.client",
 		h.cfg.clientServiceName(),
-		tracer.AnalyticsRate(h.cfg.analyticsRate),
+		h.cfg.startSpanOptions(
+			tracer.Tag(ext.Component, "google.golang.org/grpc"),
+			tracer.Tag(ext.SpanKind, ext.SpanKindClient))...,
 	)
+	ctx = contextWithRPCStats(ctx, span)
 	ctx = injectSpanIntoContext(ctx)
 	return ctx
 }
 
-// HandleRPC processes the RPC ending event by finishing the span from the context.
+// HandleRPC records the RPC events on the span from the context and finishes it
+// when the RPC ends.
 func (h *clientStatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
-	span, ok := tracer.SpanFromContext(ctx)
+	s, ok := rpcStatsFromContext(ctx)
 	if !ok {
 		return
 	}
 	switch rs := rs.(type) {
 	case *stats.OutHeader:
-		host, port, err := net.SplitHostPort(rs.RemoteAddr.String())
-		if err == nil {
-			if host != "" {
-				span.SetTag(ext.TargetHost, host)
-			}
-			span.SetTag(ext.TargetPort, port)
+		if rs.RemoteAddr != nil {
+			setSpanTargetFromPeer(s.span, peer.Peer{Addr: rs.RemoteAddr})
 		}
 	case *stats.End:
-		finishWithError(span, rs.Error, h.cfg)
+		s.finish(rs, h.cfg)
+		return
 	}
+	s.handle(rs, h.cfg)
 }
 
 // TagConn implements stats.Handler.
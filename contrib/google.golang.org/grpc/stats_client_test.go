@@ -10,10 +10,12 @@ import (
 	"net"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	context "golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/stats"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -56,6 +58,66 @@ func TestClientStatsHandler(t *testing.T) {
 	assert.Equal("/grpc.Fixture/Ping", tags[tagMethodName])
 	assert.Equal("127.0.0.1", tags[ext.TargetHost])
 	assert.Equal(server.port, tags[ext.TargetPort])
+	assert.Equal("google.golang.org/grpc", tags[ext.Component])
+	assert.Equal(ext.SpanKindClient, tags[ext.SpanKind])
+	assert.Equal(1, tags[tagMessagesSent])
+	assert.Equal(1, tags[tagMessagesReceived])
+	assert.Equal(proto.Size(&FixtureRequest{Name: "name"}), tags[tagBytesSent])
+	assert.Equal(proto.Size(&FixtureReply{Message: "passed"}), tags[tagBytesReceived])
+	assert.Greater(tags[tagWireBytesSent], tags[tagBytesSent])
+	assert.Greater(tags[tagWireBytesReceived], tags[tagBytesReceived])
+	assert.Greater(tags[tagTimeToFirstByte], int64(0))
+}
+
+func TestClientStatsHandlerPayloadEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	statsHandler := NewClientStatsHandler(WithPayloadEvents(true))
+	server, err := newClientStatsHandlerTestServer(statsHandler)
+	if err != nil {
+		t.Fatalf("failed to start test server: %s", err)
+	}
+	defer server.Close()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	_, err = server.client.Ping(context.Background(), &FixtureRequest{Name: "name"})
+	assert.NoError(err)
+
+	spans := mt.FinishedSpans()
+	assert.Len(spans, 3)
+
+	sent, received, span := spans[0], spans[1], spans[2]
+	assert.Equal("grpc.client", span.OperationName())
+	for _, s := range []mocktracer.Span{sent, received} {
+		assert.Equal("grpc.message", s.OperationName())
+		assert.Equal(span.SpanID(), s.ParentID())
+		assert.Equal(s.StartTime(), s.FinishTime())
+		assert.Equal(1, s.Tag(tagMessageSeq))
+	}
+	assert.Equal(messageSent, sent.Tag(tagMessageDirection))
+	assert.Equal(span.Tag(tagBytesSent), sent.Tag(tagMessageSize))
+	assert.Equal(span.Tag(tagWireBytesSent), sent.Tag(tagMessageWireSize))
+	assert.Equal(messageReceived, received.Tag(tagMessageDirection))
+	assert.Equal(span.Tag(tagBytesReceived), received.Tag(tagMessageSize))
+}
+
+func TestClientStatsHandlerUntracedMethods(t *testing.T) {
+	statsHandler := NewClientStatsHandler(WithUntracedMethods("/grpc.Fixture/Ping"))
+	server, err := newClientStatsHandlerTestServer(statsHandler)
+	if err != nil {
+		t.Fatalf("failed to start test server: %s", err)
+	}
+	defer server.Close()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	_, err = server.client.Ping(context.Background(), &FixtureRequest{Name: "name"})
+	assert.NoError(t, err)
+	assert.Empty(t, mt.FinishedSpans())
+	assert.Empty(t, server.fixtureServer.lastRequestMetadata.Load().(metadata.MD).Get("x-datadog-trace-id"))
 }
 
 func newClientStatsHandlerTestServer(statsHandler stats.Handler) (*rig, error) {
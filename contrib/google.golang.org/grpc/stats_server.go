@@ -6,19 +6,25 @@
 package grpc
 
 import (
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
 	context "golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/stats"
 )
 
-// NewServerStatsHandler returns a gRPC server stats.Handler to trace RPC calls.
+// NewServerStatsHandler returns a gRPC server stats.Handler to trace RPC calls. It can be used
+// as an alternative to the server interceptors: the span covers the whole RPC as seen by the
+// transport and is tagged with the message counts, sizes and time to first byte.
 func NewServerStatsHandler(opts ...Option) stats.Handler {
 	cfg := new(config)
 	defaults(cfg)
 	for _, fn := range opts {
 		fn(cfg)
 	}
+	log.Debug("contrib/google.golang.org/grpc: Configuring ServerStatsHandler: %#v", cfg)
 	return &serverStatsHandler{
 		cfg: cfg,
 	}
@@ -30,26 +36,43 @@ type serverStatsHandler struct {
 
 // TagRPC starts a new span for the initiated RPC request.
 func (h *serverStatsHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) context.Context {
-	_, ctx = startSpanFromContext(
+	_, im := h.cfg.ignoredMethods[rti.FullMethodName]
+	_, um := h.cfg.untracedMethods[rti.FullMethodName]
+	if im || um {
+		return ctx
+	}
+	span, ctx := startSpanFromContext(
 		ctx,
 		rti.FullMethodName,
 		"grpc.server",
 		h.cfg.serverServiceName(),
-		tracer.AnalyticsRate(h.cfg.analyticsRate),
-		tracer.Measured(),
+		h.cfg.startSpanOptions(tracer.Measured(),
+			tracer.Tag(ext.Component, "google.golang.org/grpc"),
+			tracer.Tag(ext.SpanKind, ext.SpanKindServer))...,
 	)
-	return ctx
+	if h.cfg.withMetadataTags {
+		md, _ := metadata.FromIncomingContext(ctx) // nil is ok
+		for k, v := range md {
+			if _, ok := h.cfg.ignoredMetadata[k]; !ok {
+				span.SetTag(tagMetadataPrefix+k, v)
+			}
+		}
+	}
+	return contextWithRPCStats(ctx, span)
 }
 
-// HandleRPC processes the RPC ending event by finishing the span from the context.
+// HandleRPC records the RPC events on the span from the context and finishes it
+// when the RPC ends.
 func (h *serverStatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
-	span, ok := tracer.SpanFromContext(ctx)
+	s, ok := rpcStatsFromContext(ctx)
 	if !ok {
 		return
 	}
 	if v, ok := rs.(*stats.End); ok {
-		finishWithError(span, v.Error, h.cfg)
+		s.finish(v, h.cfg)
+		return
 	}
+	s.handle(rs, h.cfg)
 }
 
 // TagConn implements stats.Handler.
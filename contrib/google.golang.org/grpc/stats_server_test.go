@@ -10,10 +10,12 @@ import (
 	"net"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/stats"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -51,6 +53,51 @@ func TestServerStatsHandler(t *testing.T) {
 	assert.Equal("/grpc.Fixture/Ping", tags["resource.name"])
 	assert.Equal("/grpc.Fixture/Ping", tags[tagMethodName])
 	assert.Equal(1, tags["_dd.measured"])
+	assert.Equal("google.golang.org/grpc", tags[ext.Component])
+	assert.Equal(ext.SpanKindServer, tags[ext.SpanKind])
+	assert.Equal(1, tags[tagMessagesSent])
+	assert.Equal(1, tags[tagMessagesReceived])
+	assert.Equal(proto.Size(&FixtureReply{Message: "passed"}), tags[tagBytesSent])
+	assert.Equal(proto.Size(&FixtureRequest{Name: "name"}), tags[tagBytesReceived])
+	assert.Greater(tags[tagWireBytesSent], tags[tagBytesSent])
+	assert.Greater(tags[tagWireBytesReceived], tags[tagBytesReceived])
+	assert.Greater(tags[tagTimeToFirstByte], int64(0))
+}
+
+func TestServerStatsHandlerOptions(t *testing.T) {
+	t.Run("untraced", func(t *testing.T) {
+		statsHandler := NewServerStatsHandler(WithUntracedMethods("/grpc.Fixture/Ping"))
+		server, err := newServerStatsHandlerTestServer(statsHandler)
+		if err != nil {
+			t.Fatalf("failed to start test server: %s", err)
+		}
+		defer server.Close()
+
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		_, err = server.client.Ping(context.Background(), &FixtureRequest{Name: "disabled"})
+		assert.NoError(t, err)
+		assert.Empty(t, mt.FinishedSpans())
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		statsHandler := NewServerStatsHandler(WithMetadataTags())
+		server, err := newServerStatsHandlerTestServer(statsHandler)
+		if err != nil {
+			t.Fatalf("failed to start test server: %s", err)
+		}
+		defer server.Close()
+
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "test-key", "test-value")
+		_, err = server.client.Ping(ctx, &FixtureRequest{Name: "name"})
+		assert.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, []string{"test-value"}, spans[0].Tag(tagMetadataPrefix+"test-key"))
+	})
 }
 
 func newServerStatsHandlerTestServer(statsHandler stats.Handler) (*rig, error) {
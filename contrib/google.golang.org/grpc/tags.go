@@ -12,6 +12,25 @@ const (
 	tagCode           = "grpc.code"
 	tagMetadataPrefix = "grpc.metadata."
 	tagRequest        = "grpc.request"
+
+	// Tags set by the stats handlers
+	tagMessagesSent      = "grpc.messages.sent"
+	tagMessagesReceived  = "grpc.messages.received"
+	tagBytesSent         = "grpc.bytes.sent"
+	tagBytesReceived     = "grpc.bytes.received"
+	tagWireBytesSent     = "grpc.wire_bytes.sent"
+	tagWireBytesReceived = "grpc.wire_bytes.received"
+	tagCompression       = "grpc.compression"
+	tagTimeToFirstByte   = "grpc.time_to_first_byte"
+	tagMessageDirection  = "grpc.message.direction"
+	tagMessageSeq        = "grpc.message.seq"
+	tagMessageSize       = "grpc.message.size"
+	tagMessageWireSize   = "grpc.message.wire_size"
+)
+
+const (
+	messageSent     = "sent"
+	messageReceived = "received"
 )
 
 const (
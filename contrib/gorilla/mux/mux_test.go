@@ -19,6 +19,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 
 	"github.com/stretchr/testify/assert"
@@ -319,6 +320,9 @@ func TestAppSec(t *testing.T) {
 	if !appsec.Enabled() {
 		t.Skip("appsec disabled")
 	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available: these tests rely on the waf rules, the denylist fallback is tested by internal/appsec")
+	}
 
 	// Start and trace an HTTP server with some testing routes
 	router := NewRouter()
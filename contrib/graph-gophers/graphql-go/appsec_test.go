@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package graphql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
+)
+
+const appsecRules = `{
+  "version": "2.2",
+  "metadata": {"rules_version": "1.4.2"},
+  "rules": [
+    {
+      "id": "graphql-test",
+      "name": "GraphQL test rule",
+      "tags": {"type": "security_scanner", "category": "attack_attempt"},
+      "conditions": [
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "graphql.server.all_resolvers"}], "regex": "graphql-attack"}
+        }
+      ],
+      "transformers": []
+    }
+  ]
+}`
+
+type appsecResolver struct{}
+
+func (*appsecResolver) Hello(args struct{ Name string }) string { return "Hello, " + args.Name }
+
+func TestAppSec(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(rules, []byte(appsecRules), 0644))
+	t.Setenv("DD_APPSEC_RULES", rules)
+	appsec.Start()
+	defer appsec.Stop()
+
+	if !appsec.Enabled() {
+		t.Skip("appsec disabled")
+	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available: these tests rely on the waf rules, the denylist fallback is tested by internal/appsec")
+	}
+
+	s := `
+		schema {
+			query: Query
+		}
+		type Query {
+			hello(name: String!): String!
+		}
+	`
+	schema := graphql.MustParseSchema(s, new(appsecResolver), graphql.Tracer(NewTracer()))
+	srv := httptest.NewServer(&relay.Handler{Schema: schema})
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name   string
+		query  string
+		attack bool
+	}{
+		{name: "no-attack", query: `{ hello(name: \"world\") }`},
+		{name: "attack", query: `{ hello(name: \"graphql-attack\") }`, attack: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			res, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"query": "`+tc.query+`"}`))
+			require.NoError(t, err)
+			res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			var request mocktracer.Span
+			for _, span := range mt.FinishedSpans() {
+				if span.OperationName() == "graphql.request" {
+					request = span
+				}
+			}
+			require.NotNil(t, request)
+			if !tc.attack {
+				require.Nil(t, request.Tag("_dd.appsec.json"))
+				return
+			}
+			require.Contains(t, request.Tag("_dd.appsec.json"), "graphql-test")
+		})
+	}
+}
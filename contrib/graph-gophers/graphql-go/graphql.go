@@ -19,6 +19,9 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
 	"github.com/graph-gophers/graphql-go/errors"
@@ -55,7 +58,24 @@ func (t *Tracer) TraceQuery(ctx context.Context, queryString string, operationNa
 	}
 	span, ctx := tracer.StartSpanFromContext(ctx, "graphql.request", opts...)
 
+	var op *graphqlsec.RequestOperation
+	if appsec.Enabled() {
+		instrumentation.SetAppSecEnabledTags(span)
+		ctx, op = graphqlsec.StartRequestOperation(ctx, graphqlsec.RequestOperationArgs{
+			RawQuery:      queryString,
+			OperationName: operationName,
+			Variables:     variables,
+		})
+	}
+
 	return ctx, func(errs []*errors.QueryError) {
+		if op != nil {
+			events := op.Finish(graphqlsec.RequestOperationRes{})
+			instrumentation.SetTags(span, op.Tags())
+			if len(events) > 0 {
+				graphqlsec.SetSecurityEventTags(span, events)
+			}
+		}
 		var err error
 		switch n := len(errs); n {
 		case 0:
@@ -71,8 +91,21 @@ func (t *Tracer) TraceQuery(ctx context.Context, queryString string, operationNa
 
 // TraceField traces a GraphQL field access.
 func (t *Tracer) TraceField(ctx context.Context, label string, typeName string, fieldName string, trivial bool, args map[string]interface{}) (context.Context, trace.TraceFieldFinishFunc) {
+	var op *graphqlsec.ResolveOperation
+	if appsec.Enabled() {
+		op = graphqlsec.StartResolveOperation(ctx, graphqlsec.ResolveOperationArgs{
+			TypeName:  typeName,
+			FieldName: fieldName,
+			Arguments: args,
+		})
+	}
+	finishOp := func() {
+		if op != nil {
+			op.Finish(graphqlsec.ResolveOperationRes{})
+		}
+	}
 	if t.cfg.omitTrivial && trivial {
-		return ctx, func(queryError *errors.QueryError) {}
+		return ctx, func(queryError *errors.QueryError) { finishOp() }
 	}
 	opts := []ddtrace.StartSpanOption{
 		tracer.ServiceName(t.cfg.serviceName),
@@ -87,6 +120,7 @@ func (t *Tracer) TraceField(ctx context.Context, label string, typeName string,
 	span, ctx := tracer.StartSpanFromContext(ctx, "graphql.field", opts...)
 
 	return ctx, func(err *errors.QueryError) {
+		finishOp()
 		// must explicitly check for nil, see issue golang/go#22729
 		if err != nil {
 			span.Finish(tracer.WithError(err))
@@ -15,7 +15,12 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func useAppSec(c echo.Context, span tracer.Span) func() {
+// useAppSec executes the AppSec logic related to the operation start and
+// returns the function to be executed upon finishing the operation, along
+// with the handler to call in place of next. When the request is blocked, the
+// blocked response is written instead of calling next, or after calling it if
+// the request got blocked while being handled and no response was written.
+func useAppSec(c echo.Context, span tracer.Span, next echo.HandlerFunc) (after func(), handler echo.HandlerFunc) {
 	req := c.Request()
 	instrumentation.SetAppSecEnabledTags(span)
 	params := make(map[string]string)
@@ -25,8 +30,8 @@ func useAppSec(c echo.Context, span tracer.Span) func() {
 	args := httpsec.MakeHandlerOperationArgs(req, params)
 	ctx, op := httpsec.StartOperation(req.Context(), args)
 	c.SetRequest(req.WithContext(ctx))
-	return func() {
-		events := op.Finish(httpsec.HandlerOperationRes{Status: c.Response().Status})
+	after = func() {
+		events := op.Finish(httpsec.HandlerOperationRes{Status: c.Response().Status, Headers: httpsec.MakeResponseHeaders(c.Response().Header())})
 		if len(events) > 0 {
 			remoteIP, _, err := net.SplitHostPort(req.RemoteAddr)
 			if err != nil {
@@ -36,4 +41,21 @@ func useAppSec(c echo.Context, span tracer.Span) func() {
 		}
 		instrumentation.SetTags(span, op.Tags())
 	}
+	if op.Blocked() {
+		httpsec.WriteBlockedResponse(c.Response(), c.Request())
+		return after, func(echo.Context) error { return nil }
+	}
+	httpsec.MonitorRequestBody(c.Request())
+	return after, func(c echo.Context) error {
+		err := next(c)
+		// The request can also get blocked while being handled, for instance
+		// by the user blocking SDK. Write the blocked response when the
+		// handler did not already write a response, ignoring the error
+		// returned by the handler so that the error handler doesn't get called.
+		if op.Blocked() && !c.Response().Committed {
+			httpsec.WriteBlockedResponse(c.Response(), c.Request())
+			return nil
+		}
+		return err
+	}
 }
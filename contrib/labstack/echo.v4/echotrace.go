@@ -66,11 +66,13 @@ func Middleware(opts ...Option) echo.MiddlewareFunc {
 			// pass the span through the request context
 			c.SetRequest(request.WithContext(ctx))
 			// serve the request to the next middleware
+			handler := next
 			if appsecEnabled {
-				afterMiddleware := useAppSec(c, span)
+				var afterMiddleware func()
+				afterMiddleware, handler = useAppSec(c, span, next)
 				defer afterMiddleware()
 			}
-			err := next(c)
+			err := handler(c)
 			if err != nil {
 				finishOpts = append(finishOpts, tracer.WithError(err))
 				// invokes the registered HTTP error handler
@@ -18,6 +18,10 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -320,6 +324,9 @@ func TestAppSec(t *testing.T) {
 	if !appsec.Enabled() {
 		t.Skip("appsec disabled")
 	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available: these tests rely on the waf rules, the denylist fallback is tested by internal/appsec")
+	}
 
 	// Start and trace an HTTP server
 	e := echo.New()
@@ -458,3 +465,72 @@ func TestAppSec(t *testing.T) {
 		require.True(t, strings.Contains(event.(string), "crs-933-130"))
 	})
 }
+
+// TestAppSecUserBlocking checks the blocked response is written when the
+// request gets blocked by the user blocking SDK while it is handled,
+// independently of the WAF.
+func TestAppSecUserBlocking(t *testing.T) {
+	unregister := dyngo.Register(httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, _ httpsec.HandlerOperationArgs) {
+		op.On(httpsec.OnUserIDOperationStart(func(userOp *httpsec.UserIDOperation, args httpsec.UserIDOperationArgs) {
+			if args.UserID == "blocked-user" {
+				userOp.Block()
+				op.Block()
+			}
+		}))
+	}))
+	defer unregister()
+
+	var errHandlerCalls int
+	e := echo.New()
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		errHandlerCalls++
+		e.DefaultHTTPErrorHandler(err, c)
+	}
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			span := tracer.StartSpan("http.request")
+			defer span.Finish()
+			after, handler := useAppSec(c, span, next)
+			defer after()
+			if err := handler(c); err != nil {
+				c.Error(err)
+			}
+			return nil
+		}
+	})
+	e.GET("/user/:id", func(c echo.Context) error {
+		if httpsec.MonitorUser(c.Request().Context(), c.Param("id")) {
+			return pappsec.ErrUserBlocked
+		}
+		return c.String(http.StatusOK, "Hello User!\n")
+	})
+
+	for _, tc := range []struct {
+		user    string
+		blocked bool
+	}{
+		{user: "user", blocked: false},
+		{user: "blocked-user", blocked: true},
+	} {
+		t.Run(tc.user, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest("GET", "/user/"+tc.user, nil))
+
+			require.Zero(t, errHandlerCalls)
+			finished := mt.FinishedSpans()
+			require.Len(t, finished, 1)
+			if !tc.blocked {
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Equal(t, "Hello User!\n", w.Body.String())
+				require.Nil(t, finished[0].Tag(instrumentation.BlockedRequestTag))
+				return
+			}
+			require.Equal(t, http.StatusForbidden, w.Code)
+			require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			require.Equal(t, true, finished[0].Tag(instrumentation.BlockedRequestTag))
+		})
+	}
+}
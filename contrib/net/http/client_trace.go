@@ -0,0 +1,180 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package http
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Tags set on the spans created when tracing the connection of a request.
+const (
+	tagConnReused         = "http.conn.reused"
+	tagConnWasIdle        = "http.conn.was_idle"
+	tagConnIdleTime       = "http.conn.idle_time"
+	tagTimeToFirstByte    = "http.time_to_first_byte"
+	tagDNSHost            = "dns.host"
+	tagDNSAddrs           = "dns.addrs"
+	tagDNSCoalesced       = "dns.coalesced"
+	tagNetwork            = "network.type"
+	tagNetworkAddr        = "network.destination.addr"
+	tagTLSVersion         = "tls.version"
+	tagTLSCipherSuite     = "tls.cipher_suite"
+	tagTLSResumed         = "tls.resumed"
+	tagTLSServerName      = "tls.server_name"
+	tagTLSNegotiatedProto = "tls.negotiated_protocol"
+)
+
+// clientTrace records the DNS lookup, TCP connect and TLS handshake of an
+// outgoing request as child spans of its span, and the connection reuse and
+// time to first byte as tags of its span. Hooks may be called concurrently,
+// for instance when dialing several addresses of the same host.
+type clientTrace struct {
+	span  ddtrace.Span
+	opts  []ddtrace.StartSpanOption
+	start time.Time
+
+	mu       sync.Mutex
+	dns      ddtrace.Span
+	connects map[string]ddtrace.Span
+	tls      ddtrace.Span
+}
+
+func newClientTrace(span ddtrace.Span, cfg *roundTripperConfig) *clientTrace {
+	opts := []ddtrace.StartSpanOption{
+		tracer.ChildOf(span.Context()),
+		tracer.SpanType(ext.SpanTypeHTTP),
+		tracer.Tag(ext.Component, "net/http"),
+	}
+	if cfg.serviceName != "" {
+		opts = append(opts, tracer.ServiceName(cfg.serviceName))
+	}
+	return &clientTrace{
+		span:     span,
+		opts:     opts,
+		start:    time.Now(),
+		connects: make(map[string]ddtrace.Span),
+	}
+}
+
+func (ct *clientTrace) startSpan(operation string, opts ...ddtrace.StartSpanOption) ddtrace.Span {
+	return tracer.StartSpan(operation, append(opts, ct.opts...)...)
+}
+
+// httptrace returns the httptrace.ClientTrace calling the hooks of ct.
+func (ct *clientTrace) httptrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn:              ct.gotConn,
+		GotFirstResponseByte: ct.gotFirstResponseByte,
+		DNSStart:             ct.dnsStart,
+		DNSDone:              ct.dnsDone,
+		ConnectStart:         ct.connectStart,
+		ConnectDone:          ct.connectDone,
+		TLSHandshakeStart:    ct.tlsHandshakeStart,
+		TLSHandshakeDone:     ct.tlsHandshakeDone,
+	}
+}
+
+func (ct *clientTrace) gotConn(info httptrace.GotConnInfo) {
+	ct.span.SetTag(tagConnReused, info.Reused)
+	ct.span.SetTag(tagConnWasIdle, info.WasIdle)
+	if info.WasIdle {
+		ct.span.SetTag(tagConnIdleTime, info.IdleTime.Nanoseconds())
+	}
+}
+
+func (ct *clientTrace) gotFirstResponseByte() {
+	ct.span.SetTag(tagTimeToFirstByte, time.Since(ct.start).Nanoseconds())
+}
+
+func (ct *clientTrace) dnsStart(info httptrace.DNSStartInfo) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.dns = ct.startSpan("http.dns", tracer.ResourceName(info.Host), tracer.Tag(tagDNSHost, info.Host))
+}
+
+func (ct *clientTrace) dnsDone(info httptrace.DNSDoneInfo) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if ct.dns == nil {
+		return
+	}
+	addrs := make([]string, len(info.Addrs))
+	for i, a := range info.Addrs {
+		addrs[i] = a.String()
+	}
+	ct.dns.SetTag(tagDNSAddrs, strings.Join(addrs, ","))
+	ct.dns.SetTag(tagDNSCoalesced, info.Coalesced)
+	ct.dns.Finish(tracer.WithError(info.Err))
+	ct.dns = nil
+}
+
+func (ct *clientTrace) connectStart(network, addr string) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.connects[network+addr] = ct.startSpan("http.connect",
+		tracer.ResourceName(addr),
+		tracer.Tag(tagNetwork, network),
+		tracer.Tag(tagNetworkAddr, addr),
+	)
+}
+
+func (ct *clientTrace) connectDone(network, addr string, err error) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	span, ok := ct.connects[network+addr]
+	if !ok {
+		return
+	}
+	delete(ct.connects, network+addr)
+	span.Finish(tracer.WithError(err))
+}
+
+func (ct *clientTrace) tlsHandshakeStart() {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.tls = ct.startSpan("http.tls_handshake")
+}
+
+func (ct *clientTrace) tlsHandshakeDone(state tls.ConnectionState, err error) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if ct.tls == nil {
+		return
+	}
+	if err == nil {
+		ct.tls.SetTag(tagTLSVersion, tlsVersion(state.Version))
+		ct.tls.SetTag(tagTLSCipherSuite, tls.CipherSuiteName(state.CipherSuite))
+		ct.tls.SetTag(tagTLSResumed, state.DidResume)
+		ct.tls.SetTag(tagTLSServerName, state.ServerName)
+		if state.NegotiatedProtocol != "" {
+			ct.tls.SetTag(tagTLSNegotiatedProto, state.NegotiatedProtocol)
+		}
+	}
+	ct.tls.Finish(tracer.WithError(err))
+	ct.tls = nil
+}
+
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+	return ""
+}
@@ -129,6 +129,7 @@ type roundTripperConfig struct {
 	resourceNamer func(req *http.Request) string
 	ignoreRequest func(*http.Request) bool
 	spanOpts      []ddtrace.StartSpanOption
+	clientTrace   bool
 }
 
 func newRoundTripperConfig() *roundTripperConfig {
@@ -216,3 +217,13 @@ func RTWithIgnoreRequest(f func(*http.Request) bool) RoundTripperOption {
 		cfg.ignoreRequest = f
 	}
 }
+
+// RTWithClientTrace enables or disables tracing the connection of outgoing requests. When
+// enabled, the DNS lookup, TCP connect and TLS handshake are recorded as child spans of the
+// request span, which is also tagged with the connection reuse and the time to first byte.
+// This helps telling apart slow connection setups from slow remote servers.
+func RTWithClientTrace(enabled bool) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.clientTrace = enabled
+	}
+}
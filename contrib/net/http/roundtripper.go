@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"net/http/httptrace"
 	"os"
 	"strconv"
 
@@ -54,6 +55,9 @@ func (rt *roundTripper) RoundTrip(req *http.Request) (res *http.Response, err er
 	if rt.cfg.before != nil {
 		rt.cfg.before(req, span)
 	}
+	if rt.cfg.clientTrace {
+		ctx = httptrace.WithClientTrace(ctx, newClientTrace(span, rt.cfg).httptrace())
+	}
 	r2 := req.Clone(ctx)
 	// inject the span context into the http request copy
 	err = tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(r2.Header))
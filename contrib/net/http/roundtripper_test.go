@@ -7,12 +7,15 @@ package http
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -395,3 +398,62 @@ func TestSpanOptions(t *testing.T) {
 	assert.Len(t, spans, 1)
 	assert.Equal(t, tagValue, spans[0].Tag(tagKey))
 }
+
+func TestRoundTripperClientTrace(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello World"))
+	}))
+	defer s.Close()
+	_, port, err := net.SplitHostPort(s.Listener.Addr().String())
+	require.NoError(t, err)
+	url := "https://localhost:" + port + "/hello/world"
+
+	transport := s.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.InsecureSkipVerify = true
+	client := &http.Client{Transport: WrapRoundTripper(transport, RTWithClientTrace(true))}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	spans := mt.FinishedSpans()
+	byName := make(map[string][]mocktracer.Span)
+	for _, s := range spans {
+		byName[s.OperationName()] = append(byName[s.OperationName()], s)
+	}
+	requests := byName["http.request"]
+	require.Len(t, requests, 2)
+
+	// the first request sets up the connection
+	first := requests[0]
+	assert.Equal(t, false, first.Tag(tagConnReused))
+	assert.Greater(t, first.Tag(tagTimeToFirstByte), int64(0))
+	require.Len(t, byName["http.dns"], 1)
+	dns := byName["http.dns"][0]
+	assert.Equal(t, first.SpanID(), dns.ParentID())
+	assert.Equal(t, "localhost", dns.Tag(tagDNSHost))
+	assert.Equal(t, "net/http", dns.Tag(ext.Component))
+	require.NotEmpty(t, byName["http.connect"])
+	for _, connect := range byName["http.connect"] {
+		assert.Equal(t, first.SpanID(), connect.ParentID())
+		assert.Equal(t, "tcp", connect.Tag(tagNetwork))
+	}
+	require.Len(t, byName["http.tls_handshake"], 1)
+	handshake := byName["http.tls_handshake"][0]
+	assert.Equal(t, first.SpanID(), handshake.ParentID())
+	assert.NotEmpty(t, handshake.Tag(tagTLSVersion))
+	assert.Nil(t, handshake.Tag(ext.Error))
+
+	// the second one reuses it
+	second := requests[1]
+	assert.Equal(t, true, second.Tag(tagConnReused))
+	assert.Equal(t, true, second.Tag(tagConnWasIdle))
+	assert.Greater(t, second.Tag(tagTimeToFirstByte), int64(0))
+	// two requests, one dns lookup, one or more connects and one handshake
+	assert.Len(t, spans, 4+len(byName["http.connect"]))
+}
@@ -8,12 +8,15 @@ package kafka
 import (
 	"context"
 	"math"
+	"strconv"
+	"strings"
 
 	"github.com/segmentio/kafka-go"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
 
@@ -58,6 +61,14 @@ func (r *Reader) startSpan(ctx context.Context, msg *kafka.Message) ddtrace.Span
 	if !math.IsNaN(r.cfg.analyticsRate) {
 		opts = append(opts, tracer.Tag(ext.EventSampleRate, r.cfg.analyticsRate))
 	}
+	if msg.HighWaterMark > 0 {
+		// the number of messages of the partition remaining to be consumed
+		lag := msg.HighWaterMark - msg.Offset - 1
+		if lag < 0 {
+			lag = 0
+		}
+		opts = append(opts, tracer.Tag("consumer_lag", lag))
+	}
 	// kafka supports headers, so try to extract a span context
 	carrier := messageCarrier{msg}
 	if spanctx, err := tracer.Extract(carrier); err == nil {
@@ -68,9 +79,44 @@ func (r *Reader) startSpan(ctx context.Context, msg *kafka.Message) ddtrace.Span
 	if err := tracer.Inject(span.Context(), carrier); err != nil {
 		log.Debug("contrib/segmentio/kafka.go.v0: Failed to inject span context into carrier, %v", err)
 	}
+	r.setConsumeCheckpoint(msg)
 	return span
 }
 
+// setConsumeCheckpoint sets a data streams checkpoint on the pathway of the
+// consumed message, and re-injects it so that the messages produced while
+// handling it can continue the pathway, see ContextWithPathway.
+func (r *Reader) setConsumeCheckpoint(msg *kafka.Message) {
+	if !datastreams.Enabled() {
+		return
+	}
+	edges := []string{"direction:in"}
+	if groupID := r.Reader.Config().GroupID; groupID != "" {
+		edges = append(edges, "group:"+groupID)
+	}
+	edges = append(edges, "partition:"+strconv.Itoa(msg.Partition), "topic:"+msg.Topic, "type:kafka")
+	carrier := messageCarrier{msg}
+	p, ok := datastreams.Extract(carrier)
+	if ok {
+		p = p.SetCheckpoint(edges...)
+	} else {
+		p = datastreams.NewPathway(edges...)
+	}
+	datastreams.Inject(p, carrier)
+}
+
+// ContextWithPathway returns a copy of ctx carrying the data streams pathway of
+// the consumed message msg. Passing the returned context to
+// Writer.WriteMessages makes the messages produced while handling msg continue
+// its pathway, so that the latency of pipelines spanning several topics can be
+// measured end to end.
+func ContextWithPathway(ctx context.Context, msg kafka.Message) context.Context {
+	if p, ok := datastreams.Extract(messageCarrier{&msg}); ok {
+		return datastreams.ContextWithPathway(ctx, p)
+	}
+	return ctx
+}
+
 // Close calls the underlying Reader.Close and if polling is enabled, finishes
 // any remaining span.
 func (r *Reader) Close() error {
@@ -110,6 +156,30 @@ func (r *Reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
 	return msg, nil
 }
 
+// CommitMessages calls the underlying Reader.CommitMessages and traces the
+// commit of the offsets of the messages.
+func (r *Reader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	opts := []tracer.StartSpanOption{
+		tracer.ServiceName(r.cfg.consumerServiceName),
+		tracer.ResourceName("Commit Offsets"),
+		tracer.SpanType(ext.SpanTypeMessageConsumer),
+		tracer.Tag(ext.Component, "segmentio/kafka.go.v0"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
+	}
+	if len(msgs) > 0 {
+		// the committed offsets are the ones of the next messages to consume
+		offsets := make([]string, len(msgs))
+		for i, msg := range msgs {
+			offsets[i] = msg.Topic + "[" + strconv.Itoa(msg.Partition) + "]@" + strconv.FormatInt(msg.Offset+1, 10)
+		}
+		opts = append(opts, tracer.Tag("offsets", strings.Join(offsets, ",")))
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, "kafka.commit", opts...)
+	err := r.Reader.CommitMessages(ctx, msgs...)
+	span.Finish(tracer.WithError(err))
+	return err
+}
+
 // WrapWriter wraps a kafka.Writer so requests are traced.
 func WrapWriter(w *kafka.Writer, opts ...Option) *Writer {
 	writer := &Writer{
@@ -133,11 +203,7 @@ func (w *Writer) startSpan(ctx context.Context, msg *kafka.Message) ddtrace.Span
 		tracer.Tag(ext.Component, "segmentio/kafka.go.v0"),
 		tracer.Tag(ext.SpanKind, ext.SpanKindProducer),
 	}
-	if w.Writer.Topic != "" {
-		opts = append(opts, tracer.ResourceName("Produce Topic "+w.Writer.Topic))
-	} else {
-		opts = append(opts, tracer.ResourceName("Produce Topic "+msg.Topic))
-	}
+	opts = append(opts, tracer.ResourceName("Produce Topic "+w.topic(msg)))
 	if !math.IsNaN(w.cfg.analyticsRate) {
 		opts = append(opts, tracer.Tag(ext.EventSampleRate, w.cfg.analyticsRate))
 	}
@@ -145,9 +211,39 @@ func (w *Writer) startSpan(ctx context.Context, msg *kafka.Message) ddtrace.Span
 	span, _ := tracer.StartSpanFromContext(ctx, "kafka.produce", opts...)
 	err := tracer.Inject(span.Context(), carrier)
 	log.Debug("contrib/segmentio/kafka.go.v0: Failed to inject span context into carrier, %v", err)
+	w.setProduceCheckpoint(ctx, msg)
 	return span
 }
 
+// topic returns the topic the message is written to.
+func (w *Writer) topic(msg *kafka.Message) string {
+	if w.Writer.Topic != "" {
+		return w.Writer.Topic
+	}
+	return msg.Topic
+}
+
+// setProduceCheckpoint sets a data streams checkpoint on the pathway carried by
+// ctx, see ContextWithPathway, or by the message, or on a new pathway when
+// there is none, and propagates it in the message headers.
+func (w *Writer) setProduceCheckpoint(ctx context.Context, msg *kafka.Message) {
+	if !datastreams.Enabled() {
+		return
+	}
+	edges := []string{"direction:out", "topic:" + w.topic(msg), "type:kafka"}
+	carrier := messageCarrier{msg}
+	p, ok := datastreams.PathwayFromContext(ctx)
+	if !ok {
+		p, ok = datastreams.Extract(carrier)
+	}
+	if ok {
+		p = p.SetCheckpoint(edges...)
+	} else {
+		p = datastreams.NewPathway(edges...)
+	}
+	datastreams.Inject(p, carrier)
+}
+
 func finishSpan(span ddtrace.Span, partition int, offset int64, err error) {
 	span.SetTag("partition", partition)
 	span.SetTag("offset", offset)
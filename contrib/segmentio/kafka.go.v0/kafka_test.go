@@ -7,15 +7,20 @@ package kafka
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
 
 	kafka "github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -137,9 +142,9 @@ func TestFetchMessageFunctional(t *testing.T) {
 
 	// now verify the spans
 	spans := mt.FinishedSpans()
-	assert.Len(t, spans, 2)
+	assert.Len(t, spans, 3)
 	// they should be linked via headers
-	assert.Equal(t, spans[0].TraceID(), spans[1].TraceID(), "Trace IDs should match")
+	assert.Equal(t, spans[0].TraceID(), spans[2].TraceID(), "Trace IDs should match")
 
 	s0 := spans[0] // produce
 	assert.Equal(t, "kafka.produce", s0.OperationName())
@@ -151,13 +156,111 @@ func TestFetchMessageFunctional(t *testing.T) {
 	assert.Equal(t, "segmentio/kafka.go.v0", s0.Tag(ext.Component))
 	assert.Equal(t, ext.SpanKindProducer, s0.Tag(ext.SpanKind))
 
-	s1 := spans[1] // consume
+	sc := spans[1] // commit
+	assert.Equal(t, "kafka.commit", sc.OperationName())
+	assert.Equal(t, "Commit Offsets", sc.Tag(ext.ResourceName))
+	assert.Equal(t, fmt.Sprintf("%s[%d]@%d", testTopic, msg2.Partition, msg2.Offset+1), sc.Tag("offsets"))
+	assert.Equal(t, "segmentio/kafka.go.v0", sc.Tag(ext.Component))
+
+	s1 := spans[2] // consume
 	assert.Equal(t, "kafka.consume", s1.OperationName())
 	assert.Equal(t, "kafka", s1.Tag(ext.ServiceName))
 	assert.Equal(t, "Consume Topic "+testTopic, s1.Tag(ext.ResourceName))
 	assert.Equal(t, nil, s1.Tag(ext.EventSampleRate))
 	assert.Equal(t, "queue", s1.Tag(ext.SpanType))
 	assert.Equal(t, 0, s1.Tag("partition"))
+	assert.Equal(t, int64(0), s1.Tag("consumer_lag"))
 	assert.Equal(t, "segmentio/kafka.go.v0", s1.Tag(ext.Component))
 	assert.Equal(t, ext.SpanKindConsumer, s1.Tag(ext.SpanKind))
 }
+
+func TestReaderCommitAndLag(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	// committing is unavailable without consumer group
+	r := NewReader(kafka.ReaderConfig{Brokers: []string{"localhost:9092"}, Topic: testTopic})
+	defer r.Close()
+	msgs := []kafka.Message{
+		{Topic: testTopic, Partition: 0, Offset: 4, HighWaterMark: 10},
+		{Topic: testTopic, Partition: 1, Offset: 7, HighWaterMark: 8},
+	}
+	err := r.CommitMessages(context.Background(), msgs...)
+	assert.Error(t, err)
+
+	for i := range msgs {
+		r.startSpan(context.Background(), &msgs[i]).Finish()
+	}
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 3)
+	s := spans[0]
+	assert.Equal(t, "kafka.commit", s.OperationName())
+	assert.Equal(t, "kafka", s.Tag(ext.ServiceName))
+	assert.Equal(t, "Commit Offsets", s.Tag(ext.ResourceName))
+	assert.Equal(t, "gosegtest[0]@5,gosegtest[1]@8", s.Tag("offsets"))
+	assert.Equal(t, "segmentio/kafka.go.v0", s.Tag(ext.Component))
+	assert.Equal(t, ext.SpanKindClient, s.Tag(ext.SpanKind))
+	assert.Equal(t, err, s.Tag(ext.Error))
+
+	assert.Equal(t, int64(5), spans[1].Tag("consumer_lag"))
+	assert.Equal(t, int64(0), spans[2].Tag("consumer_lag"))
+}
+
+func TestDataStreamsCheckpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	p := datastreams.NewProcessor("service", "env", srv.URL, nil)
+	p.Start()
+	defer p.Stop()
+
+	w := WrapWriter(&kafka.Writer{Topic: testTopic})
+	parent, ctx := datastreams.SetCheckpoint(context.Background(), "direction:in", "type:kafka")
+	msg := kafka.Message{Partition: 1}
+	w.setProduceCheckpoint(ctx, &msg)
+	produced, ok := datastreams.Extract(messageCarrier{&msg})
+	require.True(t, ok)
+	assert.NotEqual(t, parent.Hash(), produced.Hash())
+	assert.Equal(t, parent.PathwayStart().Unix(), produced.PathwayStart().Unix())
+
+	r := NewReader(kafka.ReaderConfig{Brokers: []string{"localhost:9092"}, Topic: testTopic})
+	defer r.Close()
+	msg.Topic = testTopic
+	r.setConsumeCheckpoint(&msg)
+	require.Len(t, msg.Headers, 1)
+	consumed, ok := datastreams.Extract(messageCarrier{&msg})
+	require.True(t, ok)
+	assert.NotEqual(t, produced.Hash(), consumed.Hash())
+	assert.Equal(t, produced.PathwayStart(), consumed.PathwayStart())
+}
+
+func TestDataStreamsPathwayChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	p := datastreams.NewProcessor("service", "env", srv.URL, nil)
+	p.Start()
+	defer p.Stop()
+
+	// hop produces msg with the pathway carried by ctx, and consumes it.
+	hop := func(ctx context.Context, topic string) kafka.Message {
+		msg := kafka.Message{Topic: topic, Partition: 1}
+		WrapWriter(&kafka.Writer{}).setProduceCheckpoint(ctx, &msg)
+		r := NewReader(kafka.ReaderConfig{Brokers: []string{"localhost:9092"}, Topic: topic})
+		defer r.Close()
+		r.setConsumeCheckpoint(&msg)
+		return msg
+	}
+
+	first := hop(context.Background(), "topic_a")
+	start, ok := datastreams.Extract(messageCarrier{&first})
+	require.True(t, ok)
+	second := hop(ContextWithPathway(context.Background(), first), "topic_b")
+	end, ok := datastreams.Extract(messageCarrier{&second})
+	require.True(t, ok)
+
+	want := start.
+		SetCheckpoint("direction:out", "topic:topic_b", "type:kafka").
+		SetCheckpoint("direction:in", "partition:1", "topic:topic_b", "type:kafka")
+	assert.Equal(t, want.Hash(), end.Hash())
+	assert.Equal(t, start.PathwayStart(), end.PathwayStart())
+}
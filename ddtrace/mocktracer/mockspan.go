@@ -233,12 +233,9 @@ baggage: %#v
 // Context returns the SpanContext of this Span.
 func (s *mockspan) Context() ddtrace.SpanContext { return s.context }
 
-// SetUser associates user information to the current trace which the
-// provided span belongs to. The options can be used to tune which user
-// bit of information gets monitored. This mockup only sets the user
-// information as span tags of the root span of the current trace.
-func (s *mockspan) SetUser(id string, opts ...tracer.UserMonitoringOption) {
-	// Walk the span up to the root parent span
+// Root walks the span up to its root parent span among the open spans of the
+// mock tracer.
+func (s *mockspan) Root() ddtrace.Span {
 	openSpans := s.tracer.openSpans
 	var current Span = s
 	for {
@@ -252,8 +249,18 @@ func (s *mockspan) SetUser(id string, opts ...tracer.UserMonitoringOption) {
 		}
 		current = parent
 	}
+	if root, ok := current.(*mockspan); ok {
+		return root
+	}
+	return s
+}
 
-	root, ok := current.(*mockspan)
+// SetUser associates user information to the current trace which the
+// provided span belongs to. The options can be used to tune which user
+// bit of information gets monitored. This mockup only sets the user
+// information as span tags of the root span of the current trace.
+func (s *mockspan) SetUser(id string, opts ...tracer.UserMonitoringOption) {
+	root, ok := s.Root().(*mockspan)
 	if !ok {
 		return
 	}
@@ -141,6 +141,9 @@ type config struct {
 
 	// enabled reports whether tracing is enabled.
 	enabled bool
+
+	// dataStreamsMonitoring specifies whether Data Streams Monitoring is enabled.
+	dataStreamsMonitoring bool
 }
 
 // HasFeature reports whether feature f is enabled.
@@ -237,6 +240,7 @@ func newConfig(opts ...StartOption) *config {
 	}
 	c.logStartup = internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true)
 	c.runtimeMetrics = internal.BoolEnv("DD_RUNTIME_METRICS_ENABLED", false)
+	c.dataStreamsMonitoring = internal.BoolEnv("DD_DATA_STREAMS_ENABLED", false)
 	c.debug = internal.BoolEnv("DD_TRACE_DEBUG", false)
 	c.enabled = internal.BoolEnv("DD_TRACE_ENABLED", true)
 	c.profilerEndpoints = internal.BoolEnv(traceprof.EndpointEnvVar, true)
@@ -663,6 +667,16 @@ func WithRuntimeMetrics() StartOption {
 	}
 }
 
+// WithDataStreamsMonitoring enables Data Streams Monitoring, measuring the
+// end-to-end latency of the pipelines messages go through in the supported
+// messaging integrations. It can also be enabled with the environment
+// variable DD_DATA_STREAMS_ENABLED.
+func WithDataStreamsMonitoring() StartOption {
+	return func(cfg *config) {
+		cfg.dataStreamsMonitoring = true
+	}
+}
+
 // WithDogstatsdAddress specifies the address to connect to for sending metrics to the Datadog
 // Agent. It should be a "host:port" string, or the path to a unix domain socket.If not set, it
 // attempts to determine the address of the statsd service according to the following rules:
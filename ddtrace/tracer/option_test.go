@@ -441,6 +441,24 @@ func TestTracerOptionsDefaults(t *testing.T) {
 		})
 	})
 
+	t.Run("data-streams", func(t *testing.T) {
+		t.Run("default", func(t *testing.T) {
+			c := newConfig()
+			assert.False(t, c.dataStreamsMonitoring)
+		})
+
+		t.Run("env", func(t *testing.T) {
+			t.Setenv("DD_DATA_STREAMS_ENABLED", "true")
+			c := newConfig()
+			assert.True(t, c.dataStreamsMonitoring)
+		})
+
+		t.Run("option", func(t *testing.T) {
+			c := newConfig(WithDataStreamsMonitoring())
+			assert.True(t, c.dataStreamsMonitoring)
+		})
+	})
+
 	t.Run("env-mapping", func(t *testing.T) {
 		os.Setenv("DD_SERVICE_MAPPING", "tracer.test:test2, svc:Newsvc,http.router:myRouter, noval:")
 		defer os.Unsetenv("DD_SERVICE_MAPPING")
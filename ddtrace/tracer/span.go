@@ -170,6 +170,15 @@ func (s *span) setSamplingPriority(priority int, sampler samplernames.SamplerNam
 	s.setSamplingPriorityLocked(priority, sampler)
 }
 
+// Root returns the root span of the trace the span belongs to, which is the
+// span itself when the root is not known.
+func (s *span) Root() ddtrace.Span {
+	if root := s.context.trace.root; root != nil {
+		return root
+	}
+	return s
+}
+
 // SetUser associates user information to the current trace which the
 // provided span belongs to. The options can be used to tune which user
 // bit of information gets monitored. In case of distributed traces,
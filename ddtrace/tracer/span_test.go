@@ -60,6 +60,17 @@ func TestSpanContext(t *testing.T) {
 	assert.NotNil(span.Context())
 }
 
+func TestSpanRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	tracer := newTracer(withTransport(newDefaultTransport()))
+	defer tracer.Stop()
+	root := tracer.newRootSpan("web.request", "web", "/")
+	child := tracer.newChildSpan("db.query", root)
+	assert.Equal(root, child.Root())
+	assert.Equal(root, root.Root())
+}
+
 func TestSpanOperationName(t *testing.T) {
 	assert := assert.New(t)
 
@@ -19,6 +19,8 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
@@ -43,6 +45,10 @@ type tracer struct {
 	// stats are enabled.
 	stats *concentrator
 
+	// dataStreams specifies the processor of the Data Streams Monitoring
+	// checkpoints, when enabled.
+	dataStreams *datastreams.Processor
+
 	// traceWriter is responsible for sending finished traces to their
 	// destination, such as the Trace Agent or Datadog Forwarder.
 	traceWriter traceWriter
@@ -124,6 +130,7 @@ func Start(opts ...StartOption) {
 		return
 	}
 	internal.SetGlobalTracer(t)
+	globalconfig.SetStatsd(t.config.statsd)
 	if t.config.logStartup {
 		logStartup(t)
 	}
@@ -134,7 +141,13 @@ func Start(opts ...StartOption) {
 	cfg.Env = t.config.env
 	cfg.HTTP = t.config.httpClient
 	cfg.ServiceName = t.config.serviceName
-	appsec.Start(appsec.WithRCConfig(cfg))
+	appsec.Start(appsec.WithRCConfig(cfg), appsec.WithTracerConfig(appsec.TracerConfig{
+		ServiceName: t.config.serviceName,
+		Env:         t.config.env,
+		Version:     t.config.version,
+		AgentURL:    t.config.agentURL,
+		HTTP:        t.config.httpClient,
+	}))
 }
 
 // Stop stops the started tracer. Subsequent calls are valid but become no-op.
@@ -260,6 +273,11 @@ func newTracer(opts ...StartOption) *tracer {
 		t.reportHealthMetrics(statsInterval)
 	}()
 	t.stats.Start()
+	if c.dataStreamsMonitoring && !c.logToStdout {
+		log.Debug("Data Streams Monitoring enabled.")
+		t.dataStreams = datastreams.NewProcessor(c.serviceName, c.env, c.agentURL, c.httpClient)
+		t.dataStreams.Start()
+	}
 	return t
 }
 
@@ -550,8 +568,14 @@ func (t *tracer) Stop() {
 		t.config.statsd.Incr("datadog.tracer.stopped", nil, 1)
 	})
 	t.stats.Stop()
+	if t.dataStreams != nil {
+		t.dataStreams.Stop()
+	}
 	t.wg.Wait()
 	t.traceWriter.stop()
+	if globalconfig.Statsd() == t.config.statsd {
+		globalconfig.SetStatsd(nil)
+	}
 	t.config.statsd.Close()
 	appsec.Stop()
 }
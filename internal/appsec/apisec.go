@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"math/rand"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/apisec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// API security schema span tags
+const (
+	apiSecRequestHeadersTag    = "_dd.appsec.s.req.headers"
+	apiSecRequestCookiesTag    = "_dd.appsec.s.req.cookies"
+	apiSecRequestQueryTag      = "_dd.appsec.s.req.query"
+	apiSecRequestPathParamsTag = "_dd.appsec.s.req.params"
+	apiSecRequestBodyTag       = "_dd.appsec.s.req.body"
+	apiSecResponseHeadersTag   = "_dd.appsec.s.res.headers"
+	apiSecResponseBodyTag      = "_dd.appsec.s.res.body"
+)
+
+// Register the API security schema extraction event listener.
+func (a *appsec) registerAPISec() dyngo.UnregisterFunc {
+	if !a.cfg.apiSec.Enabled {
+		return nil
+	}
+	log.Debug("appsec: registering the api security schema extraction with a sample rate of %v", a.cfg.apiSec.SampleRate)
+	return dyngo.Register(newAPISecEventListener(a.cfg.apiSec.SampleRate))
+}
+
+// newAPISecEventListener returns the event listener extracting the schemas of
+// the sampled HTTP requests and responses, which are added as span tags of the
+// HTTP handler operation.
+func newAPISecEventListener(sampleRate float64) dyngo.EventListener {
+	return httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, args httpsec.HandlerOperationArgs) {
+		if sampleRate < 1 && rand.Float64() >= sampleRate {
+			return
+		}
+
+		var body, responseBody interface{}
+		op.On(httpsec.OnSDKBodyOperationStart(func(_ *httpsec.SDKBodyOperation, args httpsec.SDKBodyOperationArgs) {
+			body = args.Body
+		}))
+		op.On(httpsec.OnSDKResponseBodyOperationStart(func(_ *httpsec.SDKResponseBodyOperation, args httpsec.SDKResponseBodyOperationArgs) {
+			responseBody = args.Body
+		}))
+
+		op.On(httpsec.OnHandlerOperationFinish(func(op *httpsec.Operation, res httpsec.HandlerOperationRes) {
+			for tag, value := range map[string]interface{}{
+				apiSecRequestHeadersTag:    args.Headers,
+				apiSecRequestCookiesTag:    args.Cookies,
+				apiSecRequestQueryTag:      args.Query,
+				apiSecRequestPathParamsTag: args.PathParams,
+				apiSecRequestBodyTag:       body,
+				apiSecResponseHeadersTag:   res.Headers,
+				apiSecResponseBodyTag:      responseBody,
+			} {
+				addSchemaTag(op, tag, value)
+			}
+		}))
+	})
+}
+
+// addSchemaTag adds the encoded schema of the given value as the given tag,
+// unless the value is empty.
+func addSchemaTag(th tagsHolder, tag string, value interface{}) {
+	schema := apisec.Schema(value)
+	if schema == nil || isEmptySchema(value) {
+		return
+	}
+	encoded, err := apisec.Encode(schema)
+	if err != nil {
+		log.Debug("appsec: could not encode the api security schema %s: %v", tag, err)
+		return
+	}
+	th.AddTag(tag, encoded)
+}
+
+// isEmptySchema returns true for the empty values whose schema is not worth
+// reporting.
+func isEmptySchema(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string][]string:
+		return len(v) == 0
+	case map[string]string:
+		return len(v) == 0
+	}
+	return false
+}
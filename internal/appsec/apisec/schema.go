@@ -0,0 +1,155 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+// Package apisec implements the API security schema extraction, deriving the
+// type schema of the request and response payloads observed by AppSec in
+// order to inventory the APIs of a service.
+package apisec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Schema types of scalar values.
+const (
+	TypeNull    = 1
+	TypeBool    = 2
+	TypeInteger = 4
+	TypeString  = 8
+	TypeFloat   = 16
+)
+
+const (
+	// maxDepth is the maximum depth of the extracted schemas. Values nested
+	// deeper are ignored.
+	maxDepth = 18
+	// maxArrayItems is the maximum number of array items whose schema is
+	// extracted. The array length is still reported.
+	maxArrayItems = 10
+	// maxMapKeys is the maximum number of map keys or struct fields whose
+	// schema is extracted.
+	maxMapKeys = 255
+)
+
+// Schema returns the type schema of the given value, which only describes its
+// shape and never includes its values:
+//   - scalars are described by their type as [type],
+//   - maps and structs are described by the schema of their keys as
+//     [{"key": schema}],
+//   - arrays and slices are described by the distinct schemas of their items
+//     along with their length as [[schemas...], {"len": n}].
+//
+// Nil is returned for nil values and values that cannot be described, such as
+// functions or channels.
+func Schema(v interface{}) interface{} {
+	return schema(reflect.ValueOf(v), 0)
+}
+
+func schema(v reflect.Value, depth int) interface{} {
+	if depth >= maxDepth {
+		return nil
+	}
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return []interface{}{TypeNull}
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return []interface{}{TypeBool}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return []interface{}{TypeInteger}
+	case reflect.Float32, reflect.Float64:
+		return []interface{}{TypeFloat}
+	case reflect.String:
+		return []interface{}{TypeString}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		fields := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() && len(fields) < maxMapKeys {
+			if s := schema(iter.Value(), depth+1); s != nil {
+				fields[iter.Key().String()] = s
+			}
+		}
+		return []interface{}{fields}
+	case reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField() && len(fields) < maxMapKeys; i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				// Ignore unexported fields
+				continue
+			}
+			name := f.Name
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				tagName := strings.Split(tag, ",")[0]
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			}
+			if s := schema(v.Field(i), depth+1); s != nil {
+				fields[name] = s
+			}
+		}
+		return []interface{}{fields}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []interface{}{TypeNull}
+		}
+		items := []interface{}{}
+		seen := make(map[string]struct{})
+		for i := 0; i < v.Len() && i < maxArrayItems; i++ {
+			s := schema(v.Index(i), depth+1)
+			if s == nil {
+				continue
+			}
+			// Only keep the distinct item schemas
+			key, err := json.Marshal(s)
+			if err != nil {
+				continue
+			}
+			if _, ok := seen[string(key)]; ok {
+				continue
+			}
+			seen[string(key)] = struct{}{}
+			items = append(items, s)
+		}
+		return []interface{}{items, map[string]int{"len": v.Len()}}
+	default:
+		return nil
+	}
+}
+
+// Encode returns the span tag value of the given schema, which is its JSON
+// representation compressed with gzip and encoded in base64.
+func Encode(schema interface{}) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(schema); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
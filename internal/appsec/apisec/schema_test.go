@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package apisec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	type (
+		address struct {
+			City string `json:"city"`
+			Zip  int    `json:"zip,omitempty"`
+		}
+		user struct {
+			Name     string
+			Age      uint8    `json:"age"`
+			Address  *address `json:"address"`
+			Ignored  string   `json:"-"`
+			internal string
+		}
+	)
+
+	for _, tc := range []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{name: "nil", value: nil, expected: `null`},
+		{name: "null-pointer", value: (*address)(nil), expected: `[1]`},
+		{name: "bool", value: true, expected: `[2]`},
+		{name: "integer", value: 42, expected: `[4]`},
+		{name: "float", value: 4.2, expected: `[16]`},
+		{name: "string", value: "secret", expected: `[8]`},
+		{name: "func", value: func() {}, expected: `null`},
+		{
+			name:     "headers",
+			value:    map[string][]string{"content-type": {"application/json"}},
+			expected: `[{"content-type":[[[8]],{"len":1}]}]`,
+		},
+		{
+			name:     "json",
+			value:    map[string]interface{}{"ids": []interface{}{1.0, 2.0, "3", nil}, "nested": map[string]interface{}{"ok": true}},
+			expected: `[{"ids":[[[16],[8],[1]],{"len":4}],"nested":[{"ok":[2]}]}]`,
+		},
+		{
+			name:     "struct",
+			value:    user{Name: "n", Age: 1, Address: &address{City: "c"}, Ignored: "i", internal: "i"},
+			expected: `[{"Name":[8],"address":[{"city":[8],"zip":[4]}],"age":[4]}]`,
+		},
+		{name: "empty-array", value: []int{}, expected: `[[],{"len":0}]`},
+		{name: "non-string-keys", value: map[int]string{1: "a"}, expected: `null`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := json.Marshal(Schema(tc.value))
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(s))
+		})
+	}
+
+	t.Run("max-array-items", func(t *testing.T) {
+		items := make([]interface{}, 100)
+		for i := range items {
+			if i < maxArrayItems {
+				items[i] = 1
+			} else {
+				items[i] = "not extracted"
+			}
+		}
+		s, err := json.Marshal(Schema(items))
+		require.NoError(t, err)
+		require.JSONEq(t, `[[[4]],{"len":100}]`, string(s))
+	})
+
+	t.Run("max-depth", func(t *testing.T) {
+		var v interface{} = "leaf"
+		for i := 0; i < maxDepth+5; i++ {
+			v = map[string]interface{}{"k": v}
+		}
+		s, err := json.Marshal(Schema(v))
+		require.NoError(t, err)
+		require.NotContains(t, string(s), "[8]")
+	})
+}
+
+func TestEncode(t *testing.T) {
+	schema := Schema(map[string]interface{}{"key": "value"})
+	encoded, err := Encode(schema)
+	require.NoError(t, err)
+
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"key":[8]}]`, string(decoded))
+}
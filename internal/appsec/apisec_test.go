@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/apisec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPISecEventListener(t *testing.T) {
+	run := func(sampleRate float64) map[string]interface{} {
+		unregister := dyngo.Register(newAPISecEventListener(sampleRate))
+		defer unregister()
+
+		ctx, op := httpsec.StartOperation(context.Background(), httpsec.HandlerOperationArgs{
+			Headers: map[string][]string{"content-type": {"application/json"}},
+			Query:   map[string][]string{},
+		})
+		httpsec.MonitorParsedBody(ctx, map[string]interface{}{"name": "secret"})
+		httpsec.MonitorResponseBody(ctx, []byte(`{"id": 1}`))
+		op.Finish(httpsec.HandlerOperationRes{Status: 200})
+		return op.Tags()
+	}
+
+	t.Run("sampled", func(t *testing.T) {
+		tags := run(1)
+		for tag, value := range map[string]interface{}{
+			apiSecRequestHeadersTag: map[string][]string{"content-type": {"application/json"}},
+			apiSecRequestBodyTag:    map[string]interface{}{"name": "secret"},
+			apiSecResponseBodyTag:   map[string]interface{}{"id": 1.0},
+		} {
+			expected, err := apisec.Encode(apisec.Schema(value))
+			require.NoError(t, err)
+			require.Equal(t, expected, tags[tag], tag)
+		}
+		require.NotContains(t, tags, apiSecRequestQueryTag)
+		require.NotContains(t, tags, apiSecRequestCookiesTag)
+		require.NotContains(t, tags, apiSecResponseHeadersTag)
+	})
+
+	t.Run("not-sampled", func(t *testing.T) {
+		require.Empty(t, run(0))
+	})
+}
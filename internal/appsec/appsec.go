@@ -14,6 +14,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 )
 
 // Enabled returns true when AppSec is up and running. Meaning that the appsec build tag is enabled, the env var
@@ -94,9 +95,14 @@ func setActiveAppSec(a *appsec) {
 type appsec struct {
 	cfg           *Config
 	unregisterWAF dyngo.UnregisterFunc
-	limiter       *TokenTicker
-	rc            *remoteconfig.Client
-	started       bool
+	// unregisterAPISec is nil when the API security schema extraction is
+	// disabled.
+	unregisterAPISec dyngo.UnregisterFunc
+	limiter          *TokenTicker
+	rc               *remoteconfig.Client
+	telemetry        *telemetry.Client
+	metrics          *wafMetrics
+	started          bool
 }
 
 func newAppSec(cfg *Config) *appsec {
@@ -108,9 +114,12 @@ func newAppSec(cfg *Config) *appsec {
 	if err != nil {
 		log.Error("appsec: Remote config: disabled due to a client creation error: %v", err)
 	}
+	telemetryClient := newTelemetryClient(cfg)
 	return &appsec{
-		cfg: cfg,
-		rc:  client,
+		cfg:       cfg,
+		rc:        client,
+		telemetry: telemetryClient,
+		metrics:   &wafMetrics{client: telemetryClient},
 	}
 }
 
@@ -118,12 +127,21 @@ func newAppSec(cfg *Config) *appsec {
 func (a *appsec) start() error {
 	a.limiter = NewTokenTicker(int64(a.cfg.traceRateLimit), int64(a.cfg.traceRateLimit))
 	a.limiter.Start()
+	a.telemetry.Start(nil, []telemetry.Configuration{
+		{Name: "appsec_waf_timeout", Value: a.cfg.wafTimeout.String()},
+		{Name: "appsec_trace_rate_limit", Value: a.cfg.traceRateLimit},
+		{Name: "appsec_rules_file", Value: a.cfg.rulesFile},
+		{Name: "appsec_custom_rules_file", Value: a.cfg.customRulesFile},
+	})
 	// Register the WAF operation event listener
 	unregisterWAF, err := a.registerWAF()
 	if err != nil {
+		a.telemetry.Stop()
+		a.limiter.Stop()
 		return err
 	}
 	a.unregisterWAF = unregisterWAF
+	a.unregisterAPISec = a.registerAPISec()
 	a.started = true
 	return nil
 }
@@ -133,6 +151,10 @@ func (a *appsec) stop() {
 	if a.started {
 		a.started = false
 		a.unregisterWAF()
+		if a.unregisterAPISec != nil {
+			a.unregisterAPISec()
+		}
 		a.limiter.Stop()
+		a.telemetry.Stop()
 	}
 }
@@ -6,7 +6,9 @@
 package appsec
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"regexp"
 	"strconv"
@@ -19,17 +21,21 @@ import (
 )
 
 const (
-	enabledEnvVar         = "DD_APPSEC_ENABLED"
-	rulesEnvVar           = "DD_APPSEC_RULES"
-	wafTimeoutEnvVar      = "DD_APPSEC_WAF_TIMEOUT"
-	traceRateLimitEnvVar  = "DD_APPSEC_TRACE_RATE_LIMIT"
-	obfuscatorKeyEnvVar   = "DD_APPSEC_OBFUSCATION_PARAMETER_KEY_REGEXP"
-	obfuscatorValueEnvVar = "DD_APPSEC_OBFUSCATION_PARAMETER_VALUE_REGEXP"
+	enabledEnvVar          = "DD_APPSEC_ENABLED"
+	rulesEnvVar            = "DD_APPSEC_RULES"
+	customRulesEnvVar      = "DD_APPSEC_CUSTOM_RULES"
+	wafTimeoutEnvVar       = "DD_APPSEC_WAF_TIMEOUT"
+	traceRateLimitEnvVar   = "DD_APPSEC_TRACE_RATE_LIMIT"
+	obfuscatorKeyEnvVar    = "DD_APPSEC_OBFUSCATION_PARAMETER_KEY_REGEXP"
+	obfuscatorValueEnvVar  = "DD_APPSEC_OBFUSCATION_PARAMETER_VALUE_REGEXP"
+	apiSecEnabledEnvVar    = "DD_API_SECURITY_ENABLED"
+	apiSecSampleRateEnvVar = "DD_API_SECURITY_REQUEST_SAMPLE_RATE"
 )
 
 const (
 	defaultWAFTimeout           = 4 * time.Millisecond
 	defaultTraceRate            = 100 // up to 100 appsec traces/s
+	defaultAPISecSampleRate     = 0.1 // 10% of the requests
 	defaultObfuscatorKeyRegex   = `(?i)(?:p(?:ass)?w(?:or)?d|pass(?:_?phrase)?|secret|(?:api_?|private_?|public_?)key)|token|consumer_?(?:id|key|secret)|sign(?:ed|ature)|bearer|authorization`
 	defaultObfuscatorValueRegex = `(?i)(?:p(?:ass)?w(?:or)?d|pass(?:_?phrase)?|secret|(?:api_?|private_?|public_?|access_?|secret_?)key(?:_?id)?|token|consumer_?(?:id|key|secret)|sign(?:ed|ature)?|auth(?:entication|orization)?)(?:\s*=[^;]|"\s*:\s*"[^"]+")|bearer\s+[a-z0-9\._\-]+|token:[a-z0-9]{13}|gh[opsu]_[0-9a-zA-Z]{36}|ey[I-L][\w=-]+\.ey[I-L][\w=-]+(?:\.[\w.+\/=-]+)?|[\-]{5}BEGIN[a-z\s]+PRIVATE\sKEY[\-]{5}[^\-]+[\-]{5}END[a-z\s]+PRIVATE\sKEY|ssh-rsa\s*[a-z0-9\/\.+]{100,}`
 )
@@ -40,7 +46,11 @@ type StartOption func(c *Config)
 // Config is the AppSec configuration.
 type Config struct {
 	// rules loaded via the env var DD_APPSEC_RULES. When not set, the builtin rules will be used.
+	// The custom rules loaded via the env var DD_APPSEC_CUSTOM_RULES are merged into them.
 	rules []byte
+	// rulesFile and customRulesFile are the paths of the rules files, if any, which are watched
+	// in order to reload the rules when they change.
+	rulesFile, customRulesFile string
 	// Maximum WAF execution time
 	wafTimeout time.Duration
 	// AppSec trace rate limit (traces per second).
@@ -49,6 +59,10 @@ type Config struct {
 	obfuscator ObfuscatorConfig
 	// rc is the remote configuration client used to receive product configuration updates. Nil if rc is disabled (default)
 	rc *remoteconfig.ClientConfig
+	// tracer is the configuration of the tracer AppSec reports its telemetry with.
+	tracer TracerConfig
+	// API security schema extraction configuration
+	apiSec APISecConfig
 }
 
 // WithRCConfig sets the AppSec remote config client configuration to the specified cfg
@@ -58,12 +72,38 @@ func WithRCConfig(cfg remoteconfig.ClientConfig) StartOption {
 	}
 }
 
+// TracerConfig is the subset of the tracer configuration used by AppSec to
+// report its telemetry through the agent.
+type TracerConfig struct {
+	ServiceName string
+	Env         string
+	Version     string
+	AgentURL    string
+	HTTP        *http.Client
+}
+
+// WithTracerConfig sets the configuration of the tracer AppSec is started by.
+func WithTracerConfig(cfg TracerConfig) StartOption {
+	return func(c *Config) {
+		c.tracer = cfg
+	}
+}
+
 // ObfuscatorConfig wraps the key and value regexp to be passed to the WAF to perform obfuscation.
 type ObfuscatorConfig struct {
 	KeyRegex   string
 	ValueRegex string
 }
 
+// APISecConfig is the API security schema extraction configuration.
+type APISecConfig struct {
+	// Enabled is true when the schema extraction is enabled. Disabled by
+	// default.
+	Enabled bool
+	// SampleRate is the rate of requests whose schemas get extracted.
+	SampleRate float64
+}
+
 // isEnabled returns true when appsec is enabled when the environment variable
 // It also returns whether the env var is actually set in the env or not
 // DD_APPSEC_ENABLED is set to true.
@@ -79,15 +119,19 @@ func isEnabled() (enabled bool, set bool, err error) {
 }
 
 func newConfig() (*Config, error) {
-	rules, err := readRulesConfig()
+	rulesFile, customRulesFile := os.Getenv(rulesEnvVar), os.Getenv(customRulesEnvVar)
+	rules, err := loadRules(rulesFile, customRulesFile)
 	if err != nil {
 		return nil, err
 	}
 	return &Config{
-		rules:          rules,
-		wafTimeout:     readWAFTimeoutConfig(),
-		traceRateLimit: readRateLimitConfig(),
-		obfuscator:     readObfuscatorConfig(),
+		rules:           rules,
+		rulesFile:       rulesFile,
+		customRulesFile: customRulesFile,
+		wafTimeout:      readWAFTimeoutConfig(),
+		traceRateLimit:  readRateLimitConfig(),
+		obfuscator:      readObfuscatorConfig(),
+		apiSec:          readAPISecConfig(),
 	}, nil
 }
 
@@ -135,6 +179,33 @@ func readRateLimitConfig() (rate uint) {
 	return uint(parsed)
 }
 
+func readAPISecConfig() (cfg APISecConfig) {
+	cfg.SampleRate = defaultAPISecSampleRate
+	if value := os.Getenv(apiSecEnabledEnvVar); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			logEnvVarParsingError(apiSecEnabledEnvVar, value, err, cfg.Enabled)
+		} else {
+			cfg.Enabled = enabled
+		}
+	}
+	value := os.Getenv(apiSecSampleRateEnvVar)
+	if value == "" {
+		return cfg
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logEnvVarParsingError(apiSecSampleRateEnvVar, value, err, cfg.SampleRate)
+		return cfg
+	}
+	if rate < 0 || rate > 1 {
+		logUnexpectedEnvVarValue(apiSecSampleRateEnvVar, rate, "expecting a value between 0 and 1", cfg.SampleRate)
+		return cfg
+	}
+	cfg.SampleRate = rate
+	return cfg
+}
+
 func readObfuscatorConfig() ObfuscatorConfig {
 	keyRE := readObfuscatorConfigRegexp(obfuscatorKeyEnvVar, defaultObfuscatorKeyRegex)
 	valueRE := readObfuscatorConfigRegexp(obfuscatorValueEnvVar, defaultObfuscatorValueRegex)
@@ -155,9 +226,34 @@ func readObfuscatorConfigRegexp(name, defaultValue string) string {
 	return val
 }
 
-func readRulesConfig() (rules []byte, err error) {
+// loadRules returns the security rules read from the given rules file, or the
+// builtin recommended rules when empty, along with the custom rules read from
+// the given custom rules file merged into them, if any.
+func loadRules(rulesFile, customRulesFile string) ([]byte, error) {
+	rules, err := readRulesFile(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	if customRulesFile == "" {
+		return rules, nil
+	}
+	custom, err := os.ReadFile(customRulesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Error("appsec: could not find the custom rules file in path %s: %v.", customRulesFile, err)
+		}
+		return nil, err
+	}
+	rules, err = mergeRules(rules, custom)
+	if err != nil {
+		return nil, fmt.Errorf("could not merge the custom rules file %s: %v", customRulesFile, err)
+	}
+	log.Info("appsec: merged the custom security rules from file %s", customRulesFile)
+	return rules, nil
+}
+
+func readRulesFile(filepath string) (rules []byte, err error) {
 	rules = []byte(staticRecommendedRules)
-	filepath := os.Getenv(rulesEnvVar)
 	if filepath == "" {
 		log.Info("appsec: starting with the default recommended security rules")
 		return rules, nil
@@ -173,6 +269,86 @@ func readRulesConfig() (rules []byte, err error) {
 	return buf, nil
 }
 
+// Ruleset keys whose entries get merged by id by mergeRules(). The custom rules
+// can be listed under both the rules and custom_rules keys of the custom
+// ruleset.
+const (
+	rulesetRulesKey       = "rules"
+	rulesetCustomRulesKey = "custom_rules"
+	rulesetExclusionsKey  = "exclusions"
+)
+
+// mergeRules merges the rules and exclusion filters of the given custom
+// ruleset into the given base ruleset. Custom entries having the same id as a
+// base entry replace it, while the others are appended. The other keys of the
+// base ruleset, such as its version, are kept as is.
+func mergeRules(base, custom []byte) ([]byte, error) {
+	var baseRuleset, customRuleset map[string]json.RawMessage
+	if err := json.Unmarshal(base, &baseRuleset); err != nil {
+		return nil, fmt.Errorf("could not parse the base ruleset: %v", err)
+	}
+	if err := json.Unmarshal(custom, &customRuleset); err != nil {
+		return nil, fmt.Errorf("could not parse the custom ruleset: %v", err)
+	}
+	for _, keys := range []struct{ base, custom string }{
+		{base: rulesetRulesKey, custom: rulesetRulesKey},
+		{base: rulesetRulesKey, custom: rulesetCustomRulesKey},
+		{base: rulesetExclusionsKey, custom: rulesetExclusionsKey},
+	} {
+		entries, ok := customRuleset[keys.custom]
+		if !ok {
+			continue
+		}
+		merged, err := mergeRulesetEntries(baseRuleset[keys.base], entries)
+		if err != nil {
+			return nil, fmt.Errorf("could not merge the %s: %v", keys.custom, err)
+		}
+		baseRuleset[keys.base] = merged
+	}
+	return json.Marshal(baseRuleset)
+}
+
+// mergeRulesetEntries merges the custom array of ruleset entries into the
+// base one according to their ids.
+func mergeRulesetEntries(base, custom json.RawMessage) (json.RawMessage, error) {
+	type entry struct {
+		ID string `json:"id"`
+	}
+	var baseEntries, customEntries []json.RawMessage
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &baseEntries); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(custom, &customEntries); err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(baseEntries))
+	for i, raw := range baseEntries {
+		var e entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, err
+		}
+		index[e.ID] = i
+	}
+	for _, raw := range customEntries {
+		var e entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, err
+		}
+		if e.ID == "" {
+			return nil, fmt.Errorf("missing id in entry %s", raw)
+		}
+		if i, ok := index[e.ID]; ok {
+			baseEntries[i] = raw
+			continue
+		}
+		index[e.ID] = len(baseEntries)
+		baseEntries = append(baseEntries, raw)
+	}
+	return json.Marshal(baseEntries)
+}
+
 func logEnvVarParsingError(name, value string, err error, defaultValue interface{}) {
 	log.Error("appsec: could not parse the env var %s=%s as a duration: %v. Using default value %v.", name, value, err, defaultValue)
 }
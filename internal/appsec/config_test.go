@@ -25,6 +25,7 @@ func TestConfig(t *testing.T) {
 			KeyRegex:   defaultObfuscatorKeyRegex,
 			ValueRegex: defaultObfuscatorValueRegex,
 		},
+		apiSec: APISecConfig{SampleRate: defaultAPISecSampleRate},
 	}
 
 	t.Run("default", func(t *testing.T) {
@@ -126,6 +127,7 @@ func TestConfig(t *testing.T) {
 			expectedRules := `custom rule file content`
 			expCfg := *expectedDefaultConfig
 			expCfg.rules = []byte(expectedRules)
+			expCfg.rulesFile = file.Name()
 			_, err = file.WriteString(expectedRules)
 			require.NoError(t, err)
 			os.Setenv(rulesEnvVar, file.Name())
@@ -133,6 +135,33 @@ func TestConfig(t *testing.T) {
 			require.NoError(t, err)
 			require.Equal(t, &expCfg, cfg)
 		})
+
+		t.Run("custom-rules-file", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			file, err := os.CreateTemp("", "example-*")
+			require.NoError(t, err)
+			defer func() {
+				file.Close()
+				os.Remove(file.Name())
+			}()
+			_, err = file.WriteString(`{"custom_rules": [{"id": "custom-001", "name": "custom rule"}]}`)
+			require.NoError(t, err)
+			os.Setenv(customRulesEnvVar, file.Name())
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, file.Name(), cfg.customRulesFile)
+			require.Contains(t, string(cfg.rules), `"id":"custom-001"`)
+		})
+
+		t.Run("custom-rules-file-not-found", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			os.Setenv(customRulesEnvVar, "i do not exist")
+			cfg, err := newConfig()
+			require.Error(t, err)
+			require.Nil(t, cfg)
+		})
 	})
 
 	t.Run("trace-rate-limit", func(t *testing.T) {
@@ -247,15 +276,107 @@ func TestConfig(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("api-security", func(t *testing.T) {
+		t.Run("enabled", func(t *testing.T) {
+			expCfg := *expectedDefaultConfig
+			expCfg.apiSec = APISecConfig{Enabled: true, SampleRate: 0.5}
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			require.NoError(t, os.Setenv(apiSecEnabledEnvVar, "true"))
+			require.NoError(t, os.Setenv(apiSecSampleRateEnvVar, "0.5"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, &expCfg, cfg)
+		})
+
+		t.Run("not-parsable", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			require.NoError(t, os.Setenv(apiSecEnabledEnvVar, "not a bool"))
+			require.NoError(t, os.Setenv(apiSecSampleRateEnvVar, "not a float"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, expectedDefaultConfig, cfg)
+		})
+
+		t.Run("out-of-range", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			require.NoError(t, os.Setenv(apiSecSampleRateEnvVar, "1.5"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, expectedDefaultConfig, cfg)
+		})
+	})
+}
+
+func TestMergeRules(t *testing.T) {
+	base := `{"version": "2.2", "rules": [{"id": "rule-001", "name": "base"}, {"id": "rule-002", "name": "base"}], "exclusions": [{"id": "excl-001"}]}`
+
+	for _, tc := range []struct {
+		name     string
+		custom   string
+		expected string
+	}{
+		{
+			name:     "override",
+			custom:   `{"rules": [{"id": "rule-002", "name": "custom"}]}`,
+			expected: `{"version": "2.2", "rules": [{"id": "rule-001", "name": "base"}, {"id": "rule-002", "name": "custom"}], "exclusions": [{"id": "excl-001"}]}`,
+		},
+		{
+			name:     "append",
+			custom:   `{"rules": [{"id": "rule-003", "name": "custom"}]}`,
+			expected: `{"version": "2.2", "rules": [{"id": "rule-001", "name": "base"}, {"id": "rule-002", "name": "base"}, {"id": "rule-003", "name": "custom"}], "exclusions": [{"id": "excl-001"}]}`,
+		},
+		{
+			name:     "custom-rules",
+			custom:   `{"custom_rules": [{"id": "custom-001", "name": "custom"}]}`,
+			expected: `{"version": "2.2", "rules": [{"id": "rule-001", "name": "base"}, {"id": "rule-002", "name": "base"}, {"id": "custom-001", "name": "custom"}], "exclusions": [{"id": "excl-001"}]}`,
+		},
+		{
+			name:     "exclusions",
+			custom:   `{"exclusions": [{"id": "excl-001", "rules_target": []}, {"id": "excl-002"}]}`,
+			expected: `{"version": "2.2", "rules": [{"id": "rule-001", "name": "base"}, {"id": "rule-002", "name": "base"}], "exclusions": [{"id": "excl-001", "rules_target": []}, {"id": "excl-002"}]}`,
+		},
+		{
+			name:     "version-kept",
+			custom:   `{"version": "1.0"}`,
+			expected: base,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, err := mergeRules([]byte(base), []byte(tc.custom))
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(merged))
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for name, custom := range map[string]string{
+			"invalid-json":  `{"rules": `,
+			"not-an-array":  `{"rules": {"id": "rule-001"}}`,
+			"missing-id":    `{"rules": [{"name": "custom"}]}`,
+			"not-an-object": `[]`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := mergeRules([]byte(base), []byte(custom))
+				require.Error(t, err)
+			})
+		}
+	})
 }
 
 func cleanEnv() func() {
 	env := map[string]string{
-		wafTimeoutEnvVar:      os.Getenv(wafTimeoutEnvVar),
-		rulesEnvVar:           os.Getenv(rulesEnvVar),
-		traceRateLimitEnvVar:  os.Getenv(traceRateLimitEnvVar),
-		obfuscatorKeyEnvVar:   os.Getenv(obfuscatorKeyEnvVar),
-		obfuscatorValueEnvVar: os.Getenv(obfuscatorValueEnvVar),
+		wafTimeoutEnvVar:       os.Getenv(wafTimeoutEnvVar),
+		rulesEnvVar:            os.Getenv(rulesEnvVar),
+		customRulesEnvVar:      os.Getenv(customRulesEnvVar),
+		traceRateLimitEnvVar:   os.Getenv(traceRateLimitEnvVar),
+		obfuscatorKeyEnvVar:    os.Getenv(obfuscatorKeyEnvVar),
+		obfuscatorValueEnvVar:  os.Getenv(obfuscatorValueEnvVar),
+		apiSecEnabledEnvVar:    os.Getenv(apiSecEnabledEnvVar),
+		apiSecSampleRateEnvVar: os.Getenv(apiSecSampleRateEnvVar),
 	}
 	for k, _ := range env {
 		if err := os.Unsetenv(k); err != nil {
@@ -0,0 +1,249 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+// Rules data ids of the ASM_DATA denylists, as referenced by the blocking
+// rules of the security rules.
+const (
+	blockedIPsDataID   = "blocked_ips"
+	blockedUsersDataID = "blocked_users"
+)
+
+// Blocking rules of the security rules the denylists stand for, which are
+// reported in the security events of the requests they block.
+var (
+	blockIPRule = denylistRule{
+		ID:   "blk-001-001",
+		Name: "Block IP Addresses",
+		Tags: map[string]string{"type": "block_ip", "category": "security_response"},
+	}
+	blockUserRule = denylistRule{
+		ID:   "blk-001-002",
+		Name: "Block User Addresses",
+		Tags: map[string]string{"type": "block_user", "category": "security_response"},
+	}
+)
+
+// denylist is a pure-Go evaluator of the IP and user denylists received
+// through the ASM_DATA remote config product. It is used instead of the WAF
+// when the WAF is not available, such as when cgo is disabled or when the
+// target is not supported, so that IP and user blocking keeps working for
+// HTTP requests. gRPC and GraphQL requests are not protected by the denylist
+// since their operations carry neither the client IP nor the user id.
+type denylist struct {
+	mu    sync.RWMutex
+	ips   []deniedIPNet
+	users map[string]int64 // user id -> expiration
+	// now returns the current time and allows to mock it in tests.
+	now func() time.Time
+}
+
+// deniedIPNet is an IP network of the IP denylist along with its expiration
+// timestamp, in seconds since epoch. A zero expiration never expires.
+type deniedIPNet struct {
+	ipnet      *net.IPNet
+	expiration int64
+}
+
+func newDenylist() *denylist {
+	return &denylist{now: time.Now}
+}
+
+// UpdateRulesData replaces the denylists with the given rules data. Rules data
+// other than the IP and user denylists are ignored.
+func (d *denylist) UpdateRulesData(rulesData []rc.ASMDataRuleData) error {
+	var (
+		ips   []deniedIPNet
+		users = make(map[string]int64)
+	)
+	for _, data := range rulesData {
+		switch data.ID {
+		case blockedIPsDataID:
+			for _, entry := range data.Data {
+				ipnet, err := parseIPNet(entry.Value)
+				if err != nil {
+					log.Debug("appsec: ignoring the invalid denylist ip %q: %v", entry.Value, err)
+					continue
+				}
+				ips = append(ips, deniedIPNet{ipnet: ipnet, expiration: entry.Expiration})
+			}
+		case blockedUsersDataID:
+			for _, entry := range data.Data {
+				users[entry.Value] = entry.Expiration
+			}
+		default:
+			log.Debug("appsec: ignoring the rules data %s which is not supported without the waf", data.ID)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ips = ips
+	d.users = users
+	return nil
+}
+
+// parseIPNet parses the given IP address or CIDR network. An IP address is
+// returned as the network containing only itself.
+func parseIPNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, ipnet, err := net.ParseCIDR(s)
+		return ipnet, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address")
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// expired returns true when the given expiration timestamp is in the past.
+func (d *denylist) expired(expiration int64) bool {
+	return expiration != 0 && d.now().Unix() >= expiration
+}
+
+// blockedIP returns true when the given IP address belongs to a non-expired
+// network of the IP denylist.
+func (d *denylist) blockedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, denied := range d.ips {
+		if denied.ipnet.Contains(ip) && !d.expired(denied.expiration) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedUser returns true when the given user id is a non-expired entry of
+// the user denylist.
+func (d *denylist) blockedUser(id string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	expiration, ok := d.users[id]
+	return ok && !d.expired(expiration)
+}
+
+// newHTTPDenylistEventListener returns the event listener blocking the HTTP
+// requests whose client IP address or user id are denylisted.
+func newHTTPDenylistEventListener(d *denylist, limiter Limiter) dyngo.EventListener {
+	return httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, args httpsec.HandlerOperationArgs) {
+		var (
+			events []json.RawMessage
+			mu     sync.Mutex // events mutex
+		)
+		if args.ClientIP.IsValid() {
+			if ip := args.ClientIP.String(); d.blockedIP(net.ParseIP(ip)) {
+				log.Debug("appsec: blocking the request from the denylisted ip %s", ip)
+				op.Block()
+				events = append(events, makeDenylistEvent(blockIPRule, httpClientIPAddr, ip))
+			}
+		}
+
+		op.On(httpsec.OnUserIDOperationStart(func(userOp *httpsec.UserIDOperation, args httpsec.UserIDOperationArgs) {
+			if !d.blockedUser(args.UserID) {
+				return
+			}
+			log.Debug("appsec: blocking the denylisted user")
+			userOp.Block()
+			op.Block()
+			mu.Lock()
+			events = append(events, makeDenylistEvent(blockUserRule, userIDAddr, args.UserID))
+			mu.Unlock()
+		}))
+
+		op.On(httpsec.OnHandlerOperationFinish(func(op *httpsec.Operation, _ httpsec.HandlerOperationRes) {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(events) == 0 {
+				return
+			}
+			op.AddTag(ext.ManualKeep, samplernames.AppSec)
+			if limiter.Allow() {
+				op.AddSecurityEvents(events...)
+			}
+		}))
+	})
+}
+
+// denylistRule is the rule reported in the security events of the denylists.
+type denylistRule struct {
+	ID   string            `json:"id"`
+	Name string            `json:"name"`
+	Tags map[string]string `json:"tags"`
+}
+
+// makeDenylistEvent returns the security event of a request blocked by a
+// denylist, in the same format as the WAF events.
+func makeDenylistEvent(rule denylistRule, address, value string) json.RawMessage {
+	type parameter struct {
+		Address   string   `json:"address"`
+		KeyPath   []string `json:"key_path"`
+		Value     string   `json:"value"`
+		Highlight []string `json:"highlight"`
+	}
+	type ruleMatch struct {
+		Operator      string      `json:"operator"`
+		OperatorValue string      `json:"operator_value"`
+		Parameters    []parameter `json:"parameters"`
+	}
+	type event struct {
+		Rule        denylistRule `json:"rule"`
+		RuleMatches []ruleMatch  `json:"rule_matches"`
+	}
+	operator := "exact_match"
+	if address == httpClientIPAddr {
+		operator = "ip_match"
+	}
+	buf, _ := json.Marshal([]event{{
+		Rule: rule,
+		RuleMatches: []ruleMatch{{
+			Operator: operator,
+			Parameters: []parameter{{
+				Address:   address,
+				KeyPath:   []string{},
+				Value:     value,
+				Highlight: []string{value},
+			}},
+		}},
+	}})
+	return buf
+}
+
+// registerDenylist registers the denylist event listeners, enforcing the IP
+// and user denylists received through remote config without the WAF. Only
+// HTTP listeners are registered, see denylist.
+func (a *appsec) registerDenylist(d *denylist) dyngo.UnregisterFunc {
+	if err := a.enableRCBlocking(wafHandleWrapper{d}); err != nil {
+		log.Error("appsec: Remote config: cannot enable blocking, rules data won't be updated: %v", err)
+	}
+	return dyngo.Register(newHTTPDenylistEventListener(d, a.limiter))
+}
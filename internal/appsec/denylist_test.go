@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/grpcsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylist(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := newDenylist()
+	d.now = func() time.Time { return now }
+
+	err := d.UpdateRulesData([]rc.ASMDataRuleData{
+		{
+			ID:   blockedIPsDataID,
+			Type: "ip_with_expiration",
+			Data: []rc.ASMDataRuleDataEntry{
+				{Value: "1.2.3.4"},
+				{Value: "10.0.0.0/8", Expiration: 2000},
+				{Value: "2001:db8::/32"},
+				{Value: "5.6.7.8", Expiration: 500},
+				{Value: "not an ip"},
+			},
+		},
+		{
+			ID:   blockedUsersDataID,
+			Type: "data_with_expiration",
+			Data: []rc.ASMDataRuleDataEntry{
+				{Value: "blocked-user"},
+				{Value: "expired-user", Expiration: 1000},
+			},
+		},
+		{
+			ID:   "unknown",
+			Type: "data_with_expiration",
+			Data: []rc.ASMDataRuleDataEntry{{Value: "unknown"}},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("ips", func(t *testing.T) {
+		for ip, blocked := range map[string]bool{
+			"1.2.3.4":     true,
+			"1.2.3.5":     false,
+			"10.1.2.3":    true,
+			"11.1.2.3":    false,
+			"2001:db8::1": true,
+			"2001:db9::1": false,
+			"5.6.7.8":     false, // expired
+		} {
+			require.Equal(t, blocked, d.blockedIP(net.ParseIP(ip)), ip)
+		}
+		require.False(t, d.blockedIP(nil))
+	})
+
+	t.Run("users", func(t *testing.T) {
+		require.True(t, d.blockedUser("blocked-user"))
+		require.False(t, d.blockedUser("expired-user"))
+		require.False(t, d.blockedUser("unknown"))
+		require.False(t, d.blockedUser("user"))
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		defer func(prev time.Time) { now = prev }(now)
+		now = time.Unix(3000, 0)
+		require.False(t, d.blockedIP(net.ParseIP("10.1.2.3")))
+		require.True(t, d.blockedIP(net.ParseIP("1.2.3.4")))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		// Updates replace the previous denylists
+		require.NoError(t, d.UpdateRulesData([]rc.ASMDataRuleData{
+			{ID: blockedIPsDataID, Data: []rc.ASMDataRuleDataEntry{{Value: "1.2.3.5"}}},
+		}))
+		require.False(t, d.blockedIP(net.ParseIP("1.2.3.4")))
+		require.True(t, d.blockedIP(net.ParseIP("1.2.3.5")))
+		require.False(t, d.blockedUser("blocked-user"))
+	})
+}
+
+func TestHTTPDenylistEventListener(t *testing.T) {
+	d := newDenylist()
+	require.NoError(t, d.UpdateRulesData([]rc.ASMDataRuleData{
+		{ID: blockedIPsDataID, Data: []rc.ASMDataRuleDataEntry{{Value: "1.2.3.0/24"}}},
+		{ID: blockedUsersDataID, Data: []rc.ASMDataRuleDataEntry{{Value: "blocked-user"}}},
+	}))
+	unregister := dyngo.Register(newHTTPDenylistEventListener(d, NewTokenTicker(10, 10)))
+	defer unregister()
+
+	for _, tc := range []struct {
+		name    string
+		ip      string
+		user    string
+		blocked bool
+		ruleID  string
+	}{
+		{name: "allowed", ip: "8.8.8.8", user: "user"},
+		{name: "blocked-ip", ip: "1.2.3.4", user: "user", blocked: true, ruleID: blockIPRule.ID},
+		{name: "blocked-user", ip: "8.8.8.8", user: "blocked-user", blocked: true, ruleID: blockUserRule.ID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Forwarded-For", tc.ip)
+			ctx, op := httpsec.StartOperation(context.Background(), httpsec.MakeHandlerOperationArgs(req, nil))
+			userBlocked := httpsec.MonitorUser(ctx, tc.user)
+			events := op.Finish(httpsec.HandlerOperationRes{Status: 200})
+
+			require.Equal(t, tc.blocked, op.Blocked())
+			if !tc.blocked {
+				require.False(t, userBlocked)
+				require.Empty(t, events)
+				return
+			}
+			require.Equal(t, tc.ruleID == blockUserRule.ID, userBlocked)
+			require.Len(t, events, 1)
+			var triggers []struct {
+				Rule struct {
+					ID string `json:"id"`
+				} `json:"rule"`
+			}
+			require.NoError(t, json.Unmarshal(events[0], &triggers))
+			require.Len(t, triggers, 1)
+			require.Equal(t, tc.ruleID, triggers[0].Rule.ID)
+		})
+	}
+}
+
+// TestDenylistFallback checks the denylists received through remote config
+// are enforced on HTTP requests once registered as the WAF fallback, while
+// gRPC requests are left unprotected.
+func TestDenylistFallback(t *testing.T) {
+	a := newAppSec(&Config{rc: &remoteconfig.ClientConfig{}, traceRateLimit: 10})
+	a.limiter = NewTokenTicker(10, 10)
+	a.limiter.Start()
+	defer a.limiter.Stop()
+	d := newDenylist()
+	unregister := a.registerDenylist(d)
+	defer unregister()
+	require.Contains(t, a.rc.Products, rc.ProductASMData)
+
+	handle := wafHandleWrapper{d}
+	statuses := handle.asmDataCallback(remoteconfig.ProductUpdate{
+		"datadog/2/ASM_DATA/blocked_ips/config": []byte(`{"rules_data":[{"id":"blocked_ips","type":"ip_with_expiration","data":[{"value":"1.2.3.4"}]}]}`),
+	})
+	require.Equal(t, rc.ApplyStateAcknowledged, statuses["datadog/2/ASM_DATA/blocked_ips/config"].State)
+
+	t.Run("http", func(t *testing.T) {
+		for ip, blocked := range map[string]bool{"8.8.8.8": false, "1.2.3.4": true} {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Forwarded-For", ip)
+			_, op := httpsec.StartOperation(context.Background(), httpsec.MakeHandlerOperationArgs(req, nil))
+			events := op.Finish(httpsec.HandlerOperationRes{Status: 200})
+			require.Equal(t, blocked, op.Blocked(), ip)
+			require.Equal(t, blocked, len(events) == 1, ip)
+		}
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		op := grpcsec.StartHandlerOperation(grpcsec.HandlerOperationArgs{
+			Metadata: map[string][]string{"x-forwarded-for": {"1.2.3.4"}},
+		}, nil)
+		events := op.Finish(grpcsec.HandlerOperationRes{})
+		require.False(t, op.Blocked())
+		require.Empty(t, events)
+	})
+}
@@ -15,6 +15,9 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 )
 
+// BlockedRequestTag is the span tag set to true when AppSec blocked the request.
+const BlockedRequestTag = "appsec.blocked"
+
 // TagSetter is the interface needed to set a span tag.
 type TagSetter interface {
 	SetTag(string, interface{})
@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+// Package graphqlsec is the GraphQL instrumentation API and contract for
+// AppSec defining an abstract run-time representation of GraphQL requests.
+// GraphQL integrations must use this package to enable AppSec features for
+// GraphQL, which listens to this package's operation events.
+package graphqlsec
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+)
+
+// Abstract GraphQL operation definitions. It is based on two operations
+// allowing to describe a GraphQL request: the RequestOperation type which
+// represents the execution of a GraphQL query, and the ResolveOperation type
+// which represents the resolution of one of the query fields, along with its
+// arguments. This means that the ResolveOperation(s) will happen within the
+// RequestOperation.
+type (
+	// RequestOperation represents a GraphQL request operation.
+	// It must be created with StartRequestOperation() and finished with its
+	// Finish() method.
+	// Security events observed during the operation lifetime should be added
+	// to the operation using its AddSecurityEvent() method.
+	RequestOperation struct {
+		dyngo.Operation
+		instrumentation.TagsHolder
+		instrumentation.SecurityEventsHolder
+	}
+	// RequestOperationArgs is the GraphQL request arguments.
+	RequestOperationArgs struct {
+		// RawQuery is the raw GraphQL query.
+		RawQuery string
+		// OperationName is the name of the executed GraphQL operation, if
+		// any.
+		OperationName string
+		// Variables are the variables of the GraphQL query.
+		Variables map[string]interface{}
+	}
+	// RequestOperationRes is the GraphQL request results. Empty as of today.
+	RequestOperationRes struct{}
+
+	// ResolveOperation represents the resolution of a GraphQL field. It must
+	// be created with StartResolveOperation() and finished with its Finish().
+	ResolveOperation struct {
+		dyngo.Operation
+	}
+	// ResolveOperationArgs is the GraphQL field resolution arguments.
+	ResolveOperationArgs struct {
+		// TypeName is the name of the type the field belongs to.
+		TypeName string
+		// FieldName is the name of the resolved field.
+		FieldName string
+		// Arguments are the resolved arguments of the field.
+		// Corresponds to the address `graphql.server.all_resolvers`.
+		Arguments map[string]interface{}
+	}
+	// ResolveOperationRes is the GraphQL field resolution results. Empty as
+	// of today.
+	ResolveOperationRes struct{}
+
+	contextKey struct{}
+)
+
+// StartRequestOperation starts a GraphQL request operation, along with the
+// given context and arguments, and emits a start event up in the operation
+// stack. The operation is linked to the global root operation. The returned
+// context must be used to start the resolve operations of the request.
+func StartRequestOperation(ctx context.Context, args RequestOperationArgs) (context.Context, *RequestOperation) {
+	op := &RequestOperation{
+		Operation:  dyngo.NewOperation(nil),
+		TagsHolder: instrumentation.NewTagsHolder(),
+	}
+	newCtx := context.WithValue(ctx, contextKey{}, op)
+	dyngo.StartOperation(op, args)
+	return newCtx, op
+}
+
+// Finish the GraphQL request operation, along with the given results, and
+// emit a finish event up in the operation stack.
+func (op *RequestOperation) Finish(res RequestOperationRes) []json.RawMessage {
+	dyngo.FinishOperation(op, res)
+	return op.Events()
+}
+
+// StartResolveOperation starts a GraphQL field resolution operation, along
+// with the given arguments, and emits a start event up in the operation
+// stack. The parent request operation is looked up in the given context, and
+// nil is returned when it is not found.
+func StartResolveOperation(ctx context.Context, args ResolveOperationArgs) *ResolveOperation {
+	parent, _ := ctx.Value(contextKey{}).(*RequestOperation)
+	if parent == nil {
+		return nil
+	}
+	op := &ResolveOperation{Operation: dyngo.NewOperation(parent)}
+	dyngo.StartOperation(op, args)
+	return op
+}
+
+// Finish the GraphQL field resolution operation, along with the given
+// results, and emit a finish event up in the operation stack.
+func (op *ResolveOperation) Finish(res ResolveOperationRes) {
+	dyngo.FinishOperation(op, res)
+}
+
+// GraphQL operations' start and finish event callback function types.
+type (
+	// OnRequestOperationStart function type, called when a GraphQL request
+	// operation starts.
+	OnRequestOperationStart func(*RequestOperation, RequestOperationArgs)
+	// OnRequestOperationFinish function type, called when a GraphQL request
+	// operation finishes.
+	OnRequestOperationFinish func(*RequestOperation, RequestOperationRes)
+	// OnResolveOperationStart function type, called when a GraphQL field
+	// resolution operation starts.
+	OnResolveOperationStart func(*ResolveOperation, ResolveOperationArgs)
+	// OnResolveOperationFinish function type, called when a GraphQL field
+	// resolution operation finishes.
+	OnResolveOperationFinish func(*ResolveOperation, ResolveOperationRes)
+)
+
+var (
+	requestOperationArgsType = reflect.TypeOf((*RequestOperationArgs)(nil)).Elem()
+	requestOperationResType  = reflect.TypeOf((*RequestOperationRes)(nil)).Elem()
+	resolveOperationArgsType = reflect.TypeOf((*ResolveOperationArgs)(nil)).Elem()
+	resolveOperationResType  = reflect.TypeOf((*ResolveOperationRes)(nil)).Elem()
+)
+
+// ListenedType returns the type a OnRequestOperationStart event listener
+// listens to, which is the RequestOperationArgs type.
+func (OnRequestOperationStart) ListenedType() reflect.Type { return requestOperationArgsType }
+
+// Call the underlying event listener function by performing the type-assertion
+// on v whose type is the one returned by ListenedType().
+func (f OnRequestOperationStart) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*RequestOperation), v.(RequestOperationArgs))
+}
+
+// ListenedType returns the type a OnRequestOperationFinish event listener
+// listens to, which is the RequestOperationRes type.
+func (OnRequestOperationFinish) ListenedType() reflect.Type { return requestOperationResType }
+
+// Call the underlying event listener function by performing the type-assertion
+// on v whose type is the one returned by ListenedType().
+func (f OnRequestOperationFinish) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*RequestOperation), v.(RequestOperationRes))
+}
+
+// ListenedType returns the type a OnResolveOperationStart event listener
+// listens to, which is the ResolveOperationArgs type.
+func (OnResolveOperationStart) ListenedType() reflect.Type { return resolveOperationArgsType }
+
+// Call the underlying event listener function by performing the type-assertion
+// on v whose type is the one returned by ListenedType().
+func (f OnResolveOperationStart) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*ResolveOperation), v.(ResolveOperationArgs))
+}
+
+// ListenedType returns the type a OnResolveOperationFinish event listener
+// listens to, which is the ResolveOperationRes type.
+func (OnResolveOperationFinish) ListenedType() reflect.Type { return resolveOperationResType }
+
+// Call the underlying event listener function by performing the type-assertion
+// on v whose type is the one returned by ListenedType().
+func (f OnResolveOperationFinish) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*ResolveOperation), v.(ResolveOperationRes))
+}
@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package graphqlsec_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
+)
+
+func TestUsage(t *testing.T) {
+	var requestStarted, requestFinished, resolveStarted, resolveFinished int
+	var resolved []string
+	unregister := dyngo.Register(graphqlsec.OnRequestOperationStart(func(op *graphqlsec.RequestOperation, args graphqlsec.RequestOperationArgs) {
+		requestStarted++
+		require.Equal(t, "query { user(id: 1) { name } }", args.RawQuery)
+
+		op.On(graphqlsec.OnResolveOperationStart(func(resolveOp *graphqlsec.ResolveOperation, args graphqlsec.ResolveOperationArgs) {
+			resolveStarted++
+			resolved = append(resolved, args.FieldName)
+			if args.Arguments["id"] == 1 {
+				op.AddSecurityEvents(json.RawMessage(args.FieldName))
+			}
+			resolveOp.On(graphqlsec.OnResolveOperationFinish(func(*graphqlsec.ResolveOperation, graphqlsec.ResolveOperationRes) {
+				resolveFinished++
+			}))
+		}))
+
+		op.On(graphqlsec.OnRequestOperationFinish(func(*graphqlsec.RequestOperation, graphqlsec.RequestOperationRes) {
+			requestFinished++
+		}))
+	}))
+	defer unregister()
+
+	ctx, op := graphqlsec.StartRequestOperation(context.Background(), graphqlsec.RequestOperationArgs{RawQuery: "query { user(id: 1) { name } }"})
+	graphqlsec.StartResolveOperation(ctx, graphqlsec.ResolveOperationArgs{FieldName: "user", Arguments: map[string]interface{}{"id": 1}}).Finish(graphqlsec.ResolveOperationRes{})
+	graphqlsec.StartResolveOperation(ctx, graphqlsec.ResolveOperationArgs{FieldName: "name"}).Finish(graphqlsec.ResolveOperationRes{})
+	events := op.Finish(graphqlsec.RequestOperationRes{})
+
+	require.Equal(t, 1, requestStarted)
+	require.Equal(t, 1, requestFinished)
+	require.Equal(t, 2, resolveStarted)
+	require.Equal(t, 2, resolveFinished)
+	require.Equal(t, []string{"user", "name"}, resolved)
+	require.Equal(t, []json.RawMessage{json.RawMessage("user")}, events)
+
+	t.Run("no-request-operation", func(t *testing.T) {
+		require.Nil(t, graphqlsec.StartResolveOperation(context.Background(), graphqlsec.ResolveOperationArgs{FieldName: "user"}))
+	})
+}
@@ -0,0 +1,22 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package graphqlsec
+
+import (
+	"encoding/json"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// SetSecurityEventTags sets the AppSec-specific span tags when a security event
+// occurred into the GraphQL request span.
+func SetSecurityEventTags(span ddtrace.Span, events []json.RawMessage) {
+	if err := instrumentation.SetEventSpanTags(span, events); err != nil {
+		log.Error("appsec: %v", err)
+	}
+}
@@ -12,6 +12,7 @@ package grpcsec
 import (
 	"encoding/json"
 	"reflect"
+	"sync/atomic"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
@@ -39,6 +40,7 @@ type (
 		dyngo.Operation
 		instrumentation.TagsHolder
 		instrumentation.SecurityEventsHolder
+		blocked uint32
 	}
 	// HandlerOperationArgs is the grpc handler arguments.
 	HandlerOperationArgs struct {
@@ -81,6 +83,19 @@ func StartHandlerOperation(args HandlerOperationArgs, parent dyngo.Operation) *H
 	return op
 }
 
+// Block marks the operation as blocked. It is called by the event listeners
+// of the handler start or receive finish events when the RPC must be aborted.
+func (op *HandlerOperation) Block() {
+	atomic.StoreUint32(&op.blocked, 1)
+	op.AddTag(instrumentation.BlockedRequestTag, true)
+}
+
+// Blocked returns true when the RPC must be aborted, either before calling its
+// handler or when the handler receives its next message.
+func (op *HandlerOperation) Blocked() bool {
+	return atomic.LoadUint32(&op.blocked) == 1
+}
+
 // Finish the gRPC handler operation, along with the given results, and emit a
 // finish event up in the operation stack.
 func (op *HandlerOperation) Finish(res HandlerOperationRes) []json.RawMessage {
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package httpsec
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+const (
+	// envBlockedTemplateJSON is the name of the env var used to specify the
+	// path of the file containing the JSON response body of blocked requests.
+	envBlockedTemplateJSON = "DD_APPSEC_HTTP_BLOCKED_TEMPLATE_JSON"
+	// envBlockedTemplateHTML is the name of the env var used to specify the
+	// path of the file containing the HTML response body of blocked requests.
+	envBlockedTemplateHTML = "DD_APPSEC_HTTP_BLOCKED_TEMPLATE_HTML"
+)
+
+// Default response bodies of blocked requests.
+const (
+	defaultBlockedTemplateJSON = `{"errors": [{"title": "You've been blocked", "detail": "Sorry, you cannot access this page. Please contact the customer service team. Security provided by Datadog."}]}`
+	defaultBlockedTemplateHTML = `<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8"><meta name="viewport" content="width=device-width,initial-scale=1"><title>You've been blocked</title></head><body><main><h1>Sorry, you cannot access this page. Please contact the customer service team.</h1><p>Security provided by Datadog</p></main></body></html>`
+)
+
+var (
+	blockedTemplateJSON = []byte(defaultBlockedTemplateJSON)
+	blockedTemplateHTML = []byte(defaultBlockedTemplateHTML)
+)
+
+func init() {
+	for env, template := range map[string]*[]byte{
+		envBlockedTemplateJSON: &blockedTemplateJSON,
+		envBlockedTemplateHTML: &blockedTemplateHTML,
+	} {
+		path := os.Getenv(env)
+		if path == "" {
+			continue
+		}
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			log.Error("appsec: could not read the blocked response template %s=%s: %v. Using the default template instead.", env, path, err)
+			continue
+		}
+		*template = buf
+	}
+}
+
+// WriteBlockedResponse writes the response of a request blocked by AppSec to
+// w, which is a 403 status code along with the HTML template when the client
+// prefers HTML according to the Accept header of r, or the JSON template
+// otherwise. The templates can be configured with the
+// DD_APPSEC_HTTP_BLOCKED_TEMPLATE_JSON and DD_APPSEC_HTTP_BLOCKED_TEMPLATE_HTML
+// env vars.
+func WriteBlockedResponse(w http.ResponseWriter, r *http.Request) {
+	contentType, body := "application/json", blockedTemplateJSON
+	if prefersHTML(r.Header.Get("Accept")) {
+		contentType, body = "text/html", blockedTemplateHTML
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(body)
+}
+
+// prefersHTML returns true when the given Accept header value lists the HTML
+// media type before the JSON one.
+func prefersHTML(accept string) bool {
+	html := strings.Index(accept, "text/html")
+	if html < 0 {
+		return false
+	}
+	json := strings.Index(accept, "application/json")
+	return json < 0 || html < json
+}
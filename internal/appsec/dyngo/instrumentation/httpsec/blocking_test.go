@@ -0,0 +1,181 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package httpsec_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapHandlerBlocking(t *testing.T) {
+	// Block the requests sent to /blocked
+	unregister := dyngo.Register(httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, args httpsec.HandlerOperationArgs) {
+		if args.RequestURI == "/blocked" {
+			op.Block()
+		}
+	}))
+	defer unregister()
+
+	for _, tc := range []struct {
+		name        string
+		uri         string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:   "not-blocked",
+			uri:    "/",
+			status: http.StatusOK,
+			body:   "Hello World!",
+		},
+		{
+			name:        "json",
+			uri:         "/blocked",
+			accept:      "application/json, text/html",
+			status:      http.StatusForbidden,
+			contentType: "application/json",
+			body:        "You've been blocked",
+		},
+		{
+			name:        "html",
+			uri:         "/blocked",
+			accept:      "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			status:      http.StatusForbidden,
+			contentType: "text/html",
+			body:        "<!DOCTYPE html>",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			span := tracer.StartSpan("http.request")
+			var called bool
+			h := httpsec.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.Write([]byte("Hello World!\n"))
+			}), span, nil)
+
+			req := httptest.NewRequest("GET", tc.uri, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			span.Finish()
+
+			require.Equal(t, tc.status, rec.Code)
+			require.Contains(t, rec.Body.String(), tc.body)
+			require.Equal(t, tc.status == http.StatusOK, called)
+			finished := mt.FinishedSpans()
+			require.Len(t, finished, 1)
+			if tc.status == http.StatusOK {
+				require.Nil(t, finished[0].Tag(instrumentation.BlockedRequestTag))
+				return
+			}
+			require.Equal(t, tc.contentType, rec.Header().Get("Content-Type"))
+			require.Equal(t, true, finished[0].Tag(instrumentation.BlockedRequestTag))
+		})
+	}
+}
+
+func TestClientIPArg(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 1.2.3.4")
+	args := httpsec.MakeHandlerOperationArgs(req, nil)
+	require.Equal(t, "1.2.3.4", args.ClientIP.String())
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	args = httpsec.MakeHandlerOperationArgs(req, nil)
+	require.False(t, args.ClientIP.IsValid())
+}
+
+func TestMonitorUserBlocking(t *testing.T) {
+	// Block the user "blocked-user"
+	unregister := dyngo.Register(httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, _ httpsec.HandlerOperationArgs) {
+		op.On(httpsec.OnUserIDOperationStart(func(userOp *httpsec.UserIDOperation, args httpsec.UserIDOperationArgs) {
+			if args.UserID == "blocked-user" {
+				userOp.Block()
+				op.Block()
+			}
+		}))
+	}))
+	defer unregister()
+
+	for _, tc := range []struct {
+		user    string
+		blocked bool
+	}{
+		{user: "user", blocked: false},
+		{user: "blocked-user", blocked: true},
+	} {
+		t.Run(tc.user, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			span := tracer.StartSpan("http.request")
+			var blocked bool
+			h := httpsec.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if blocked = httpsec.MonitorUser(r.Context(), tc.user); blocked {
+					return
+				}
+				w.Write([]byte("Hello World!\n"))
+			}), span, nil)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(&statusRecorder{ResponseRecorder: rec}, req)
+			span.Finish()
+
+			require.Equal(t, tc.blocked, blocked)
+			finished := mt.FinishedSpans()
+			require.Len(t, finished, 1)
+			if !tc.blocked {
+				require.Equal(t, http.StatusOK, rec.Code)
+				require.Nil(t, finished[0].Tag(instrumentation.BlockedRequestTag))
+				return
+			}
+			require.Equal(t, http.StatusForbidden, rec.Code)
+			require.Equal(t, true, finished[0].Tag(instrumentation.BlockedRequestTag))
+		})
+	}
+}
+
+// statusRecorder records the status code written like the response writers
+// of the HTTP middleware functions do.
+type statusRecorder struct {
+	*httptest.ResponseRecorder
+	status int
+}
+
+func (r *statusRecorder) Status() int { return r.status }
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.ResponseRecorder.Write(b)
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status != 0 {
+		return
+	}
+	r.status = status
+	r.ResponseRecorder.WriteHeader(status)
+}
@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package httpsec
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+const (
+	// envBodyParsingEnabled is the name of the env var used to enable the
+	// automatic parsing of the HTTP request bodies.
+	envBodyParsingEnabled = "DD_APPSEC_HTTP_BODY_PARSING_ENABLED"
+	// envBodyParsingMaxSize is the name of the env var used to specify the
+	// maximum size of the HTTP request bodies that get automatically parsed.
+	envBodyParsingMaxSize = "DD_APPSEC_HTTP_BODY_PARSING_MAX_SIZE"
+)
+
+// defaultBodyParsingMaxSize is the default maximum size of the HTTP request
+// bodies that get automatically parsed.
+const defaultBodyParsingMaxSize = 64 * 1024
+
+var (
+	bodyParsingEnabled bool
+	bodyParsingMaxSize = defaultBodyParsingMaxSize
+)
+
+func init() {
+	if v := os.Getenv(envBodyParsingEnabled); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Error("appsec: could not parse %s=%s: %v", envBodyParsingEnabled, v, err)
+		}
+		bodyParsingEnabled = enabled
+	}
+	if v := os.Getenv(envBodyParsingMaxSize); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			log.Error("appsec: could not parse %s=%s as a strictly positive integer: using the default value %d instead", envBodyParsingMaxSize, v, defaultBodyParsingMaxSize)
+		} else {
+			bodyParsingMaxSize = size
+		}
+	}
+}
+
+// MonitorRequestBody parses the body of the given request and monitors the
+// parsed value as the SDK body operation does, when the automatic body
+// parsing is enabled with the DD_APPSEC_HTTP_BODY_PARSING_ENABLED env var. The
+// request context must be the one returned by StartOperation. JSON,
+// form-urlencoded and multipart bodies are supported, while binary bodies and
+// bodies larger than DD_APPSEC_HTTP_BODY_PARSING_MAX_SIZE are ignored. The
+// request body is restored so that the handler can still read it entirely.
+func MonitorRequestBody(r *http.Request) {
+	if !bodyParsingEnabled {
+		return
+	}
+	if body := parseRequestBody(r, bodyParsingMaxSize); body != nil {
+		MonitorParsedBody(r.Context(), body)
+	}
+}
+
+// parseRequestBody reads up to maxSize bytes of the request body, restores it
+// and returns its parsed value according to its content type. It returns nil
+// when the body is not supported or cannot be parsed.
+func parseRequestBody(r *http.Request, maxSize int) interface{} {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength > int64(maxSize) {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	var parse func([]byte) (interface{}, error)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		parse = parseJSON
+	case mediaType == "application/x-www-form-urlencoded":
+		parse = parseForm
+	case mediaType == "multipart/form-data" && params["boundary"] != "":
+		parse = func(buf []byte) (interface{}, error) {
+			return parseMultipart(buf, params["boundary"])
+		}
+	default:
+		return nil
+	}
+
+	// Read one byte more than the max size in order to detect larger bodies
+	buf, err := io.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+	if err != nil || len(buf) > maxSize {
+		return nil
+	}
+	body, err := parse(buf)
+	if err != nil {
+		log.Debug("appsec: could not parse the %s request body: %v", mediaType, err)
+		return nil
+	}
+	return body
+}
+
+// readCloser allows to restore a request body while keeping the original
+// closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func parseJSON(buf []byte) (interface{}, error) {
+	var body interface{}
+	if err := json.Unmarshal(buf, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func parseForm(buf []byte) (interface{}, error) {
+	values, err := url.ParseQuery(string(buf))
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]string(values), nil
+}
+
+// parseMultipart returns the field values of the multipart body, ignoring its
+// files.
+func parseMultipart(buf []byte, boundary string) (interface{}, error) {
+	values := make(map[string][]string)
+	mr := multipart.NewReader(bytes.NewReader(buf), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() != "" || part.FormName() == "" {
+			continue
+		}
+		v, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		values[part.FormName()] = append(values[part.FormName()], string(v))
+	}
+}
@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package httpsec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestBody(t *testing.T) {
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	mw.WriteField("name", "value")
+	fw, _ := mw.CreateFormFile("file", "file.bin")
+	fw.Write([]byte{0, 1, 2})
+	mw.Close()
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		expected    interface{}
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"a": [1, "b"]}`,
+			expected:    map[string]interface{}{"a": []interface{}{1.0, "b"}},
+		},
+		{
+			name:        "json-suffix",
+			contentType: "application/vnd.api+json",
+			body:        `"value"`,
+			expected:    "value",
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "a=1&a=2&b=3",
+			expected:    map[string][]string{"a": {"1", "2"}, "b": {"3"}},
+		},
+		{
+			name:        "multipart",
+			contentType: mw.FormDataContentType(),
+			body:        multipartBody.String(),
+			expected:    map[string][]string{"name": {"value"}},
+		},
+		{
+			name:        "binary",
+			contentType: "application/octet-stream",
+			body:        "\x00\x01\x02",
+		},
+		{
+			name:        "invalid-json",
+			contentType: "application/json",
+			body:        `{`,
+		},
+		{
+			name:        "too-large",
+			contentType: "application/json",
+			body:        `"` + strings.Repeat("a", 1024) + `"`,
+		},
+		{
+			name: "no-content-type",
+			body: `{}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			req.ContentLength = -1 // force reading the body to detect its size
+			req.Header.Set("Content-Type", tc.contentType)
+			require.Equal(t, tc.expected, parseRequestBody(req, 1024))
+
+			// The body must be restored
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.body, string(body))
+		})
+	}
+}
+
+func TestMonitorRequestBody(t *testing.T) {
+	var body interface{}
+	unregister := dyngo.Register(OnHandlerOperationStart(func(op *Operation, _ HandlerOperationArgs) {
+		op.On(OnSDKBodyOperationStart(func(_ *SDKBodyOperation, args SDKBodyOperationArgs) {
+			body = args.Body
+		}))
+	}))
+	defer unregister()
+
+	monitor := func() {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"a":"b"}`))
+		req.Header.Set("Content-Type", "application/json")
+		ctx, op := StartOperation(context.Background(), HandlerOperationArgs{})
+		defer op.Finish(HandlerOperationRes{})
+		MonitorRequestBody(req.WithContext(ctx))
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		body = nil
+		monitor()
+		require.Nil(t, body)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		bodyParsingEnabled = true
+		defer func() { bodyParsingEnabled = false }()
+		body = nil
+		monitor()
+		require.Equal(t, map[string]interface{}{"a": "b"}, body)
+	})
+}
@@ -17,6 +17,7 @@ import (
 	"net/http"
 	"reflect"
 	"strings"
+	"sync/atomic"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
@@ -38,12 +39,16 @@ type (
 		Query map[string][]string
 		// PathParams corresponds to the address `server.request.path_params`
 		PathParams map[string]string
+		// ClientIP corresponds to the address `http.client_ip`
+		ClientIP netaddrIP
 	}
 
 	// HandlerOperationRes is the HTTP handler operation results.
 	HandlerOperationRes struct {
 		// Status corresponds to the address `server.response.status`.
 		Status int
+		// Headers corresponds to the address `server.response.headers.no_cookies`
+		Headers map[string][]string
 	}
 
 	// SDKBodyOperationArgs is the SDK body operation arguments.
@@ -54,6 +59,25 @@ type (
 
 	// SDKBodyOperationRes is the SDK body operation results.
 	SDKBodyOperationRes struct{}
+
+	// SDKResponseBodyOperationArgs is the SDK response body operation
+	// arguments.
+	SDKResponseBodyOperationArgs struct {
+		// Body corresponds to the address `server.response.body`.
+		Body interface{}
+	}
+
+	// SDKResponseBodyOperationRes is the SDK response body operation results.
+	SDKResponseBodyOperationRes struct{}
+
+	// UserIDOperationArgs is the user ID operation arguments.
+	UserIDOperationArgs struct {
+		// UserID corresponds to the address `usr.id`.
+		UserID string
+	}
+
+	// UserIDOperationRes is the user ID operation results.
+	UserIDOperationRes struct{}
 )
 
 // MonitorParsedBody starts and finishes the SDK body operation.
@@ -68,6 +92,49 @@ func MonitorParsedBody(ctx context.Context, body interface{}) {
 	}
 }
 
+// MaxResponseBodySize is the maximum size of the JSON response bodies
+// MonitorResponseBody parses. Larger bodies are ignored.
+const MaxResponseBodySize = 64 * 1024
+
+// MonitorResponseBody parses the given JSON response body and starts and
+// finishes the SDK response body operation with the parsed value. Bodies
+// larger than MaxResponseBodySize or that are not valid JSON are ignored.
+// This function should not be called when AppSec is disabled in order to get
+// preciser error logs.
+func MonitorResponseBody(ctx context.Context, body []byte) {
+	parent := fromContext(ctx)
+	if parent == nil {
+		log.Error("appsec: http response body monitoring ignored: could not find the http handler instrumentation metadata in the request context: the request handler is not being monitored by a middleware function or the provided context is not the expected request context")
+		return
+	}
+	if len(body) > MaxResponseBodySize {
+		log.Debug("appsec: http response body monitoring ignored: the body size %d is larger than the maximum size %d", len(body), MaxResponseBodySize)
+		return
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		log.Debug("appsec: http response body monitoring ignored: could not parse the json body: %v", err)
+		return
+	}
+	op := StartSDKResponseBodyOperation(parent, SDKResponseBodyOperationArgs{Body: parsed})
+	op.Finish()
+}
+
+// MonitorUser starts and finishes the user ID operation and returns true when
+// the user must be blocked, in which case the parent HTTP handler operation is
+// also blocked. This function should not be called when AppSec is disabled in
+// order to get preciser error logs.
+func MonitorUser(ctx context.Context, id string) (blocked bool) {
+	parent := fromContext(ctx)
+	if parent == nil {
+		log.Error("appsec: user id monitoring ignored: could not find the http handler instrumentation metadata in the request context: the request handler is not being monitored by a middleware function or the provided context is not the expected request context")
+		return false
+	}
+	op := StartUserIDOperation(parent, UserIDOperationArgs{UserID: id})
+	op.Finish()
+	return op.Blocked()
+}
+
 // WrapHandler wraps the given HTTP handler with the abstract HTTP operation defined by HandlerOperationArgs and
 // HandlerOperationRes.
 func WrapHandler(handler http.Handler, span ddtrace.Span, pathParams map[string]string) http.Handler {
@@ -83,9 +150,11 @@ func WrapHandler(handler http.Handler, span ddtrace.Span, pathParams map[string]
 			var status int
 			if mw, ok := w.(interface{ Status() int }); ok {
 				status = mw.Status()
+			} else if op.Blocked() {
+				status = http.StatusForbidden
 			}
 
-			events := op.Finish(HandlerOperationRes{Status: status})
+			events := op.Finish(HandlerOperationRes{Status: status, Headers: MakeResponseHeaders(w.Header())})
 			instrumentation.SetTags(span, op.Tags())
 			if len(events) == 0 {
 				return
@@ -98,7 +167,20 @@ func WrapHandler(handler http.Handler, span ddtrace.Span, pathParams map[string]
 			SetSecurityEventTags(span, events, remoteIP, args.Headers, w.Header())
 		}()
 
+		if op.Blocked() {
+			WriteBlockedResponse(w, r)
+			return
+		}
+		MonitorRequestBody(r)
 		handler.ServeHTTP(w, r)
+		// The request can also get blocked while being handled, for instance
+		// by the user blocking SDK. Write the blocked response when the
+		// handler did not already write a response.
+		if op.Blocked() {
+			if mw, ok := w.(interface{ Status() int }); ok && mw.Status() == 0 {
+				WriteBlockedResponse(w, r)
+			}
+		}
 	})
 }
 
@@ -117,15 +199,35 @@ func MakeHandlerOperationArgs(r *http.Request, pathParams map[string]string) Han
 	}
 	cookies := makeCookies(r) // TODO(Julio-Guerra): avoid actively parsing the cookies thanks to dynamic instrumentation
 	headers["host"] = []string{r.Host}
+	ip, _, _ := clientIP(r)
 	return HandlerOperationArgs{
 		RequestURI: r.RequestURI,
 		Headers:    headers,
 		Cookies:    cookies,
 		Query:      r.URL.Query(), // TODO(Julio-Guerra): avoid actively parsing the query values thanks to dynamic instrumentation
 		PathParams: pathParams,
+		ClientIP:   ip,
 	}
 }
 
+// MakeResponseHeaders returns the response headers following the
+// specification of the rule address `server.response.headers.no_cookies`, ie.
+// with lower-cased header names and without the set-cookie headers.
+func MakeResponseHeaders(h http.Header) map[string][]string {
+	if len(h) == 0 {
+		return nil
+	}
+	headers := make(map[string][]string, len(h))
+	for k, v := range h {
+		k := strings.ToLower(k)
+		if k == "set-cookie" {
+			continue
+		}
+		headers[k] = v
+	}
+	return headers
+}
+
 // Return the map of parsed cookies if any and following the specification of
 // the rule address `server.request.cookies`.
 func makeCookies(r *http.Request) map[string][]string {
@@ -149,6 +251,7 @@ type (
 		dyngo.Operation
 		instrumentation.TagsHolder
 		instrumentation.SecurityEventsHolder
+		blocked uint32
 	}
 
 	// SDKBodyOperation type representing an SDK body. It must be created with
@@ -157,6 +260,21 @@ type (
 		dyngo.Operation
 	}
 
+	// SDKResponseBodyOperation type representing an SDK response body. It
+	// must be created with StartSDKResponseBodyOperation() and finished with
+	// its Finish() method.
+	SDKResponseBodyOperation struct {
+		dyngo.Operation
+	}
+
+	// UserIDOperation type representing a call to the user ID monitoring
+	// SDK. It must be created with StartUserIDOperation() and finished with
+	// its Finish() method.
+	UserIDOperation struct {
+		dyngo.Operation
+		blocked uint32
+	}
+
 	contextKey struct{}
 )
 
@@ -180,6 +298,20 @@ func fromContext(ctx context.Context) *Operation {
 	return op
 }
 
+// Block marks the operation as blocked. It is called by the event listeners
+// of the operation start event when the request must not be handled.
+func (op *Operation) Block() {
+	atomic.StoreUint32(&op.blocked, 1)
+	op.AddTag(instrumentation.BlockedRequestTag, true)
+}
+
+// Blocked returns true when the request must be blocked instead of being
+// handled, in which case the response must be written using
+// WriteBlockedResponse.
+func (op *Operation) Blocked() bool {
+	return atomic.LoadUint32(&op.blocked) == 1
+}
+
 // Finish the HTTP handler operation, along with the given results and emits a
 // finish event up in the operation stack.
 func (op *Operation) Finish(res HandlerOperationRes) []json.RawMessage {
@@ -199,6 +331,42 @@ func (op *SDKBodyOperation) Finish() {
 	dyngo.FinishOperation(op, SDKBodyOperationRes{})
 }
 
+// StartSDKResponseBodyOperation starts the SDK response body operation and
+// emits a start event
+func StartSDKResponseBodyOperation(parent *Operation, args SDKResponseBodyOperationArgs) *SDKResponseBodyOperation {
+	op := &SDKResponseBodyOperation{Operation: dyngo.NewOperation(parent)}
+	dyngo.StartOperation(op, args)
+	return op
+}
+
+// Finish finishes the SDK response body operation and emits a finish event
+func (op *SDKResponseBodyOperation) Finish() {
+	dyngo.FinishOperation(op, SDKResponseBodyOperationRes{})
+}
+
+// StartUserIDOperation starts the user ID operation and emits a start event
+func StartUserIDOperation(parent *Operation, args UserIDOperationArgs) *UserIDOperation {
+	op := &UserIDOperation{Operation: dyngo.NewOperation(parent)}
+	dyngo.StartOperation(op, args)
+	return op
+}
+
+// Finish finishes the user ID operation and emits a finish event
+func (op *UserIDOperation) Finish() {
+	dyngo.FinishOperation(op, UserIDOperationRes{})
+}
+
+// Block marks the user as blocked. It is called by the event listeners of
+// the operation start event when the user must be blocked.
+func (op *UserIDOperation) Block() {
+	atomic.StoreUint32(&op.blocked, 1)
+}
+
+// Blocked returns true when the user must be blocked.
+func (op *UserIDOperation) Blocked() bool {
+	return atomic.LoadUint32(&op.blocked) == 1
+}
+
 // HTTP handler operation's start and finish event callback function types.
 type (
 	// OnHandlerOperationStart function type, called when an HTTP handler
@@ -213,13 +381,29 @@ type (
 	// OnSDKBodyOperationFinish function type, called when an SDK body
 	// operation finishes.
 	OnSDKBodyOperationFinish func(*SDKBodyOperation, SDKBodyOperationRes)
+	// OnSDKResponseBodyOperationStart function type, called when an SDK
+	// response body operation starts.
+	OnSDKResponseBodyOperationStart func(*SDKResponseBodyOperation, SDKResponseBodyOperationArgs)
+	// OnSDKResponseBodyOperationFinish function type, called when an SDK
+	// response body operation finishes.
+	OnSDKResponseBodyOperationFinish func(*SDKResponseBodyOperation, SDKResponseBodyOperationRes)
+	// OnUserIDOperationStart function type, called when a user ID
+	// operation starts.
+	OnUserIDOperationStart func(*UserIDOperation, UserIDOperationArgs)
+	// OnUserIDOperationFinish function type, called when a user ID
+	// operation finishes.
+	OnUserIDOperationFinish func(*UserIDOperation, UserIDOperationRes)
 )
 
 var (
-	handlerOperationArgsType = reflect.TypeOf((*HandlerOperationArgs)(nil)).Elem()
-	handlerOperationResType  = reflect.TypeOf((*HandlerOperationRes)(nil)).Elem()
-	sdkBodyOperationArgsType = reflect.TypeOf((*SDKBodyOperationArgs)(nil)).Elem()
-	sdkBodyOperationResType  = reflect.TypeOf((*SDKBodyOperationRes)(nil)).Elem()
+	handlerOperationArgsType         = reflect.TypeOf((*HandlerOperationArgs)(nil)).Elem()
+	handlerOperationResType          = reflect.TypeOf((*HandlerOperationRes)(nil)).Elem()
+	sdkBodyOperationArgsType         = reflect.TypeOf((*SDKBodyOperationArgs)(nil)).Elem()
+	sdkBodyOperationResType          = reflect.TypeOf((*SDKBodyOperationRes)(nil)).Elem()
+	sdkResponseBodyOperationArgsType = reflect.TypeOf((*SDKResponseBodyOperationArgs)(nil)).Elem()
+	sdkResponseBodyOperationResType  = reflect.TypeOf((*SDKResponseBodyOperationRes)(nil)).Elem()
+	userIDOperationArgsType          = reflect.TypeOf((*UserIDOperationArgs)(nil)).Elem()
+	userIDOperationResType           = reflect.TypeOf((*UserIDOperationRes)(nil)).Elem()
 )
 
 // ListenedType returns the type a OnHandlerOperationStart event listener
@@ -261,3 +445,47 @@ func (OnSDKBodyOperationFinish) ListenedType() reflect.Type { return sdkBodyOper
 func (f OnSDKBodyOperationFinish) Call(op dyngo.Operation, v interface{}) {
 	f(op.(*SDKBodyOperation), v.(SDKBodyOperationRes))
 }
+
+// ListenedType returns the type a OnSDKResponseBodyOperationStart event
+// listener listens to, which is the SDKResponseBodyOperationArgs type.
+func (OnSDKResponseBodyOperationStart) ListenedType() reflect.Type {
+	return sdkResponseBodyOperationArgsType
+}
+
+// Call calls the underlying event listener function by performing the
+// type-assertion on v whose type is the one returned by ListenedType().
+func (f OnSDKResponseBodyOperationStart) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*SDKResponseBodyOperation), v.(SDKResponseBodyOperationArgs))
+}
+
+// ListenedType returns the type a OnSDKResponseBodyOperationFinish event
+// listener listens to, which is the SDKResponseBodyOperationRes type.
+func (OnSDKResponseBodyOperationFinish) ListenedType() reflect.Type {
+	return sdkResponseBodyOperationResType
+}
+
+// Call calls the underlying event listener function by performing the
+// type-assertion on v whose type is the one returned by ListenedType().
+func (f OnSDKResponseBodyOperationFinish) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*SDKResponseBodyOperation), v.(SDKResponseBodyOperationRes))
+}
+
+// ListenedType returns the type a OnUserIDOperationStart event listener
+// listens to, which is the UserIDOperationArgs type.
+func (OnUserIDOperationStart) ListenedType() reflect.Type { return userIDOperationArgsType }
+
+// Call calls the underlying event listener function by performing the
+// type-assertion on v whose type is the one returned by ListenedType().
+func (f OnUserIDOperationStart) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*UserIDOperation), v.(UserIDOperationArgs))
+}
+
+// ListenedType returns the type a OnUserIDOperationFinish event listener
+// listens to, which is the UserIDOperationRes type.
+func (OnUserIDOperationFinish) ListenedType() reflect.Type { return userIDOperationResType }
+
+// Call calls the underlying event listener function by performing the
+// type-assertion on v whose type is the one returned by ListenedType().
+func (f OnUserIDOperationFinish) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*UserIDOperation), v.(UserIDOperationRes))
+}
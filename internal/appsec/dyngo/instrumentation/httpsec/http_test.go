@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package httpsec
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeResponseHeaders(t *testing.T) {
+	require.Nil(t, MakeResponseHeaders(nil))
+
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	h.Add("Set-Cookie", "a=b")
+	h.Add("X-Custom", "1")
+	h.Add("X-Custom", "2")
+	require.Equal(t, map[string][]string{
+		"content-type": {"application/json"},
+		"x-custom":     {"1", "2"},
+	}, MakeResponseHeaders(h))
+}
+
+func TestMonitorResponseBody(t *testing.T) {
+	var (
+		body  interface{}
+		calls int
+	)
+	unregister := dyngo.Register(OnHandlerOperationStart(func(op *Operation, _ HandlerOperationArgs) {
+		op.On(OnSDKResponseBodyOperationStart(func(_ *SDKResponseBodyOperation, args SDKResponseBodyOperationArgs) {
+			body = args.Body
+			calls++
+		}))
+	}))
+	defer unregister()
+
+	ctx, op := StartOperation(context.Background(), HandlerOperationArgs{})
+	defer op.Finish(HandlerOperationRes{})
+
+	MonitorResponseBody(ctx, []byte(`{"card": "4111111111111111"}`))
+	require.Equal(t, 1, calls)
+	require.Equal(t, map[string]interface{}{"card": "4111111111111111"}, body)
+
+	t.Run("invalid-json", func(t *testing.T) {
+		MonitorResponseBody(ctx, []byte(`{`))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		MonitorResponseBody(ctx, []byte(`"`+strings.Repeat("a", MaxResponseBodySize)+`"`))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("no-operation", func(t *testing.T) {
+		MonitorResponseBody(context.Background(), []byte(`{}`))
+		require.Equal(t, 1, calls)
+	})
+}
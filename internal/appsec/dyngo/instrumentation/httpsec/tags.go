@@ -106,15 +106,28 @@ func ippref(s string) *netaddrIPPrefix {
 // SetIPTags sets the IP related span tags for a given request
 // See https://docs.datadoghq.com/tracing/configure_data_security#configuring-a-client-ip-header for more information.
 func SetIPTags(span instrumentation.TagSetter, r *http.Request) {
+	ip, headers, ips := clientIP(r)
+	if ip.IsValid() {
+		span.SetTag(ext.HTTPClientIP, ip.String())
+	} else if len(ips) > 1 {
+		for i := range ips {
+			span.SetTag(ext.HTTPRequestHeaders+"."+headers[i], ips[i])
+		}
+		span.SetTag(multipleIPHeaders, strings.Join(headers, ","))
+	}
+}
+
+// clientIP returns the global IP address of the client of the request r,
+// looked up in the IP headers, or in the remote address of the request when
+// none is set. The returned IP is invalid when no global IP was found. When
+// several IP headers are set, the client IP cannot be chosen and their names
+// and values are returned instead.
+func clientIP(r *http.Request) (ip netaddrIP, headers []string, ips []string) {
 	ipHeaders := defaultIPHeaders
 	if len(clientIPHeader) > 0 {
 		ipHeaders = []string{clientIPHeader}
 	}
 
-	var (
-		headers []string
-		ips     []string
-	)
 	for _, hdr := range ipHeaders {
 		if v := r.Header.Get(hdr); v != "" {
 			headers = append(headers, hdr)
@@ -124,22 +137,17 @@ func SetIPTags(span instrumentation.TagSetter, r *http.Request) {
 
 	if l := len(ips); l == 0 {
 		if remoteIP := parseIP(r.RemoteAddr); remoteIP.IsValid() && isGlobal(remoteIP) {
-			span.SetTag(ext.HTTPClientIP, remoteIP.String())
+			return remoteIP, nil, nil
 		}
 	} else if l == 1 {
 		for _, ipstr := range strings.Split(ips[0], ",") {
 			ip := parseIP(strings.TrimSpace(ipstr))
 			if ip.IsValid() && isGlobal(ip) {
-				span.SetTag(ext.HTTPClientIP, ip.String())
-				break
+				return ip, headers, ips
 			}
 		}
-	} else {
-		for i := range ips {
-			span.SetTag(ext.HTTPRequestHeaders+"."+headers[i], ips[i])
-		}
-		span.SetTag(multipleIPHeaders, strings.Join(headers, ","))
 	}
+	return netaddrIP{}, headers, ips
 }
 
 func parseIP(s string) netaddrIP {
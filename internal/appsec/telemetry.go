@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"net/url"
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+)
+
+// Names of the WAF metrics reported through telemetry.
+const (
+	wafInitMetric          = "waf.init"
+	wafUpdatesMetric       = "waf.updates"
+	wafRequestsMetric      = "waf.requests"
+	wafDurationMetric      = "waf.duration"
+	wafDurationExtMetric   = "waf.duration_ext"
+	eventRulesLoadedMetric = "event_rules.loaded"
+	eventRulesErrorsMetric = "event_rules.error_count"
+)
+
+// defaultAgentURL is the agent URL used when AppSec is not started by the
+// tracer.
+const defaultAgentURL = "http://localhost:8126"
+
+// newTelemetryClient returns the telemetry client of AppSec, sending its
+// metrics through the agent of the tracer configuration. The client is
+// disabled unless DD_INSTRUMENTATION_TELEMETRY_ENABLED is true.
+func newTelemetryClient(cfg *Config) *telemetry.Client {
+	client := &telemetry.Client{
+		Namespace: telemetry.NamespaceASM,
+		Service:   cfg.tracer.ServiceName,
+		Env:       cfg.tracer.Env,
+		Version:   cfg.tracer.Version,
+		Client:    cfg.tracer.HTTP,
+	}
+	// For the initial release, prefer off-by-default rather than
+	// on-by-default
+	if !internal.BoolEnv("DD_INSTRUMENTATION_TELEMETRY_ENABLED", false) {
+		client.Disabled = true
+	}
+	agentURL := cfg.tracer.AgentURL
+	if agentURL == "" {
+		agentURL = defaultAgentURL
+	}
+	u, err := url.Parse(agentURL)
+	if err != nil {
+		log.Warn("appsec: agent URL %s is invalid, not starting telemetry", agentURL)
+		client.Disabled = true
+		return client
+	}
+	u.Path = "/telemetry/proxy/api/v2/apmtelemetry"
+	client.URL = u.String()
+	return client
+}
+
+// telemetryClient is the subset of the telemetry client API used to report
+// the WAF metrics.
+type telemetryClient interface {
+	Count(name string, value float64, tags []string, common bool)
+	Distribution(name string, value float64, tags []string, common bool)
+}
+
+// wafMetrics reports the WAF metrics through telemetry. A nil *wafMetrics
+// doesn't report anything.
+type wafMetrics struct {
+	client telemetryClient
+}
+
+// recordRuleset reports the loading of a ruleset by the WAF, either at its
+// initialization (waf.init) or when it gets updated (waf.updates), along with
+// the number of rules successfully loaded and of rules that failed to load.
+func (m *wafMetrics) recordRuleset(metric string, rInfo waf.RulesetInfo, success bool) {
+	if m == nil {
+		return
+	}
+	tags := []string{
+		"waf_version:" + waf.Version(),
+		"event_rules_version:" + rInfo.Version,
+	}
+	m.client.Count(metric, 1, append(tags, "success:"+strconv.FormatBool(success)), true)
+	m.client.Count(eventRulesLoadedMetric, float64(rInfo.Loaded), tags, true)
+	m.client.Count(eventRulesErrorsMetric, float64(rInfo.Failed), tags, true)
+}
+
+// recordRequest reports the WAF execution metrics of a monitored request,
+// along with whether a rule got triggered, the request got blocked, or the
+// WAF timed out.
+func (m *wafMetrics) recordRequest(rulesVersion string, overallRuntimeNs, internalRuntimeNs, timeouts uint64, triggered, blocked bool) {
+	if m == nil {
+		return
+	}
+	tags := []string{
+		"waf_version:" + waf.Version(),
+		"event_rules_version:" + rulesVersion,
+	}
+	m.client.Count(wafRequestsMetric, 1, append(tags,
+		"rule_triggered:"+strconv.FormatBool(triggered),
+		"request_blocked:"+strconv.FormatBool(blocked),
+		"waf_timeout:"+strconv.FormatBool(timeouts > 0),
+	), true)
+	m.client.Distribution(wafDurationMetric, float64(internalRuntimeNs)/1e3, tags, true)   // ns to us
+	m.client.Distribution(wafDurationExtMetric, float64(overallRuntimeNs)/1e3, tags, true) // ns to us
+}
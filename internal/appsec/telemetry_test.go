@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordedMetric struct {
+	kind  string
+	name  string
+	value float64
+	tags  []string
+}
+
+type telemetryRecorder struct {
+	metrics []recordedMetric
+}
+
+func (r *telemetryRecorder) Count(name string, value float64, tags []string, common bool) {
+	r.metrics = append(r.metrics, recordedMetric{kind: "count", name: name, value: value, tags: tags})
+}
+
+func (r *telemetryRecorder) Distribution(name string, value float64, tags []string, common bool) {
+	r.metrics = append(r.metrics, recordedMetric{kind: "distribution", name: name, value: value, tags: tags})
+}
+
+func TestWAFMetrics(t *testing.T) {
+	wafVersionTag := "waf_version:" + waf.Version()
+
+	t.Run("ruleset", func(t *testing.T) {
+		var r telemetryRecorder
+		m := &wafMetrics{client: &r}
+		m.recordRuleset(wafInitMetric, waf.RulesetInfo{Loaded: 10, Failed: 2, Version: "1.2.3"}, true)
+		tags := []string{wafVersionTag, "event_rules_version:1.2.3"}
+		require.Equal(t, []recordedMetric{
+			{kind: "count", name: wafInitMetric, value: 1, tags: append(tags, "success:true")},
+			{kind: "count", name: eventRulesLoadedMetric, value: 10, tags: tags},
+			{kind: "count", name: eventRulesErrorsMetric, value: 2, tags: tags},
+		}, r.metrics)
+	})
+
+	t.Run("request", func(t *testing.T) {
+		var r telemetryRecorder
+		m := &wafMetrics{client: &r}
+		m.recordRequest("1.2.3", 3000, 2000, 1, true, false)
+		tags := []string{wafVersionTag, "event_rules_version:1.2.3"}
+		require.Equal(t, []recordedMetric{
+			{kind: "count", name: wafRequestsMetric, value: 1, tags: append(tags, "rule_triggered:true", "request_blocked:false", "waf_timeout:true")},
+			{kind: "distribution", name: wafDurationMetric, value: 2, tags: tags},
+			{kind: "distribution", name: wafDurationExtMetric, value: 3, tags: tags},
+		}, r.metrics)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var m *wafMetrics
+		require.NotPanics(t, func() {
+			m.recordRuleset(wafUpdatesMetric, waf.RulesetInfo{}, false)
+			m.recordRequest("", 0, 0, 0, false, false)
+		})
+	})
+}
+
+func TestNewTelemetryClient(t *testing.T) {
+	t.Run("disabled-by-default", func(t *testing.T) {
+		client := newTelemetryClient(&Config{tracer: TracerConfig{AgentURL: "http://localhost:8126"}})
+		require.True(t, client.Disabled)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("DD_INSTRUMENTATION_TELEMETRY_ENABLED", "true")
+		client := newTelemetryClient(&Config{tracer: TracerConfig{
+			AgentURL:    "http://agent:8126",
+			ServiceName: "my-service",
+			Env:         "my-env",
+		}})
+		require.False(t, client.Disabled)
+		require.Equal(t, "http://agent:8126/telemetry/proxy/api/v2/apmtelemetry", client.URL)
+		require.Equal(t, "my-service", client.Service)
+		require.Equal(t, "my-env", client.Env)
+	})
+
+	t.Run("remote-config-disabled", func(t *testing.T) {
+		t.Setenv("DD_INSTRUMENTATION_TELEMETRY_ENABLED", "true")
+		client := newTelemetryClient(&Config{tracer: TracerConfig{ServiceName: "my-service"}})
+		require.False(t, client.Disabled)
+		require.Equal(t, "http://localhost:8126/telemetry/proxy/api/v2/apmtelemetry", client.URL)
+		require.Equal(t, "my-service", client.Service)
+	})
+}
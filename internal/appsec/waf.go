@@ -19,6 +19,7 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/grpcsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
@@ -39,120 +40,223 @@ const (
 
 // Register the WAF event listener.
 func (a *appsec) registerWAF() (unreg dyngo.UnregisterFunc, err error) {
-	// Check the WAF is healthy
+	// Check the WAF is healthy, and fall back to the pure-Go evaluation of
+	// the remote config denylists otherwise. The fallback only protects HTTP
+	// requests as gRPC and GraphQL operations carry neither the client IP nor
+	// the user id.
 	if err := waf.Health(); err != nil {
-		return nil, err
+		log.Warn("appsec: the waf is not available (%v): only the ip and user denylists received through remote configuration will be enforced, and only on http requests: grpc and graphql requests are not protected", err)
+		return a.registerDenylist(newDenylist()), nil
 	}
 
 	// Instantiate the WAF
-	waf, err := waf.NewHandle(a.cfg.rules, a.cfg.obfuscator.KeyRegex, a.cfg.obfuscator.ValueRegex)
+	state, err := newWAFState(a.cfg.rules, a.cfg, a.limiter, a.metrics)
+	if err != nil {
+		a.metrics.recordRuleset(wafInitMetric, waf.RulesetInfo{}, false)
+		return nil, err
+	}
+	a.metrics.recordRuleset(wafInitMetric, state.handle.RulesetInfo(), true)
+
+	// Register the WAF event listeners. They are registered once and dispatch
+	// the events to the listeners of the current WAF state, which allows to
+	// atomically swap it when the security rules get reloaded.
+	wafs := &wafSwapper{}
+	wafs.swap(state)
+	unregister := dyngo.Register(wafs.listeners()...)
+
+	if err := a.enableRCBlocking(wafHandleWrapper{wafs}); err != nil {
+		log.Error("appsec: Remote config: cannot enable blocking, rules data won't be updated: %v", err)
+	}
+
+	stopWatching := watchFiles([]string{a.cfg.rulesFile, a.cfg.customRulesFile}, rulesWatchInterval, func() {
+		a.reloadRules(wafs)
+	})
+
+	// Return an unregistration function that will also release the WAF instance.
+	return func() {
+		stopWatching()
+		unregister()
+		wafs.close()
+	}, nil
+}
+
+// wafState is the WAF handle along with the WAF event listeners using it.
+// The listeners are nil when the rules have no addresses of their kind.
+type wafState struct {
+	handle              *waf.Handle
+	http, grpc, graphql dyngo.EventListener
+}
+
+// newWAFState instantiates the WAF with the given rules and creates its event
+// listeners.
+func newWAFState(rules []byte, cfg *Config, limiter Limiter, metrics *wafMetrics) (state *wafState, err error) {
+	handle, err := waf.NewHandle(rules, cfg.obfuscator.KeyRegex, cfg.obfuscator.ValueRegex)
 	if err != nil {
 		return nil, err
 	}
 	// Close the WAF in case of an error in what's following
 	defer func() {
 		if err != nil {
-			waf.Close()
+			handle.Close()
 		}
 	}()
 
 	// Check if there are addresses in the rule
-	ruleAddresses := waf.Addresses()
+	ruleAddresses := handle.Addresses()
 	if len(ruleAddresses) == 0 {
 		return nil, errors.New("no addresses found in the rule")
 	}
 	// Check there are supported addresses in the rule
-	httpAddresses, grpcAddresses, notSupported := supportedAddresses(ruleAddresses)
-	if len(httpAddresses) == 0 && len(grpcAddresses) == 0 {
+	httpAddresses, grpcAddresses, graphqlAddresses, notSupported := supportedAddresses(ruleAddresses)
+	if len(httpAddresses) == 0 && len(grpcAddresses) == 0 && len(graphqlAddresses) == 0 {
 		return nil, fmt.Errorf("the addresses present in the rule are not supported: %v", notSupported)
 	} else if len(notSupported) > 0 {
 		log.Debug("appsec: the addresses present in the rule are partially supported: not supported=%v", notSupported)
 	}
 
-	// Register the WAF event listener
-	var unregisterHTTP, unregisterGRPC dyngo.UnregisterFunc
+	state = &wafState{handle: handle}
 	if len(httpAddresses) > 0 {
 		log.Debug("appsec: registering http waf listening to addresses %v", httpAddresses)
-		unregisterHTTP = dyngo.Register(newHTTPWAFEventListener(waf, httpAddresses, a.cfg.wafTimeout, a.limiter))
+		state.http = newHTTPWAFEventListener(handle, httpAddresses, cfg.wafTimeout, limiter, metrics)
 	}
 	if len(grpcAddresses) > 0 {
 		log.Debug("appsec: registering grpc waf listening to addresses %v", grpcAddresses)
-		unregisterGRPC = dyngo.Register(newGRPCWAFEventListener(waf, grpcAddresses, a.cfg.wafTimeout, a.limiter))
+		state.grpc = newGRPCWAFEventListener(handle, grpcAddresses, cfg.wafTimeout, limiter, metrics)
 	}
-
-	if err := a.enableRCBlocking(wafHandleWrapper{waf}); err != nil {
-		log.Error("appsec: Remote config: cannot enable blocking, rules data won't be updated: %v", err)
+	if len(graphqlAddresses) > 0 {
+		log.Debug("appsec: registering graphql waf listening to addresses %v", graphqlAddresses)
+		state.graphql = newGraphQLWAFEventListener(handle, cfg.wafTimeout, limiter, metrics)
 	}
-
-	// Return an unregistration function that will also release the WAF instance.
-	return func() {
-		defer waf.Close()
-		if unregisterHTTP != nil {
-			unregisterHTTP()
-		}
-		if unregisterGRPC != nil {
-			unregisterGRPC()
-		}
-	}, nil
+	return state, nil
 }
 
 // newWAFEventListener returns the WAF event listener to register in order to enable it.
-func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout time.Duration, limiter Limiter) dyngo.EventListener {
+func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout time.Duration, limiter Limiter, metrics *wafMetrics) dyngo.EventListener {
 	var monitorRulesOnce sync.Once // per instantiation
 
 	return httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, args httpsec.HandlerOperationArgs) {
-		var body interface{}
+		wafCtx := waf.NewContext(handle)
+		if wafCtx == nil {
+			// The WAF event listener got concurrently released
+			return
+		}
+
+		// The request addresses are known at the start of the operation, so
+		// we run the WAF on them right away in order to be able to block the
+		// request before it gets handled. The WAF context is kept until the
+		// end of the operation to run the WAF on the remaining addresses.
+		values := make(map[string]interface{}, len(addresses))
+		for _, addr := range addresses {
+			switch addr {
+			case httpClientIPAddr:
+				if args.ClientIP.IsValid() {
+					values[httpClientIPAddr] = args.ClientIP.String()
+				}
+			case serverRequestRawURIAddr:
+				values[serverRequestRawURIAddr] = args.RequestURI
+			case serverRequestHeadersNoCookiesAddr:
+				if headers := args.Headers; headers != nil {
+					values[serverRequestHeadersNoCookiesAddr] = headers
+				}
+			case serverRequestCookiesAddr:
+				if cookies := args.Cookies; cookies != nil {
+					values[serverRequestCookiesAddr] = cookies
+				}
+			case serverRequestQueryAddr:
+				if query := args.Query; query != nil {
+					values[serverRequestQueryAddr] = query
+				}
+			case serverRequestPathParams:
+				if pathParams := args.PathParams; pathParams != nil {
+					values[serverRequestPathParams] = pathParams
+				}
+			}
+		}
+		var (
+			events []json.RawMessage
+			closed bool       // closed is true once the WAF context is released
+			mu     sync.Mutex // guards events, closed and wafCtx, as the user id may be monitored from other goroutines
+		)
+		matches, actions := runWAF(wafCtx, values, timeout)
+		if len(matches) > 0 {
+			events = append(events, matches)
+		}
+		if isBlockingAction(actions) {
+			log.Debug("appsec: blocking the request as requested by the waf")
+			op.Block()
+		}
+
+		for _, addr := range addresses {
+			if addr != userIDAddr {
+				continue
+			}
+			op.On(httpsec.OnUserIDOperationStart(func(userOp *httpsec.UserIDOperation, args httpsec.UserIDOperationArgs) {
+				mu.Lock()
+				defer mu.Unlock()
+				if closed {
+					// The user id was monitored after the handler returned
+					return
+				}
+				matches, actions := runWAF(wafCtx, map[string]interface{}{userIDAddr: args.UserID}, timeout)
+				if len(matches) > 0 {
+					events = append(events, matches)
+				}
+				if isBlockingAction(actions) {
+					log.Debug("appsec: blocking the user as requested by the waf")
+					userOp.Block()
+					op.Block()
+				}
+			}))
+		}
 
+		var body, responseBody interface{}
 		op.On(httpsec.OnSDKBodyOperationStart(func(op *httpsec.SDKBodyOperation, args httpsec.SDKBodyOperationArgs) {
 			body = args.Body
 		}))
+		op.On(httpsec.OnSDKResponseBodyOperationStart(func(op *httpsec.SDKResponseBodyOperation, args httpsec.SDKResponseBodyOperationArgs) {
+			responseBody = args.Body
+		}))
 
-		// At the moment, AppSec doesn't block the requests, and so we can use the fact we are in monitoring-only mode
-		// to call the WAF only once at the end of the handler operation.
 		op.On(httpsec.OnHandlerOperationFinish(func(op *httpsec.Operation, res httpsec.HandlerOperationRes) {
-			wafCtx := waf.NewContext(handle)
-			if wafCtx == nil {
-				// The WAF event listener got concurrently released
-				return
-			}
-			defer wafCtx.Close()
-
-			// Run the WAF on the rule addresses available in the request args
-			values := make(map[string]interface{}, len(addresses))
+			mu.Lock()
+			defer mu.Unlock()
+			// Release the WAF context while holding mu, so that the user id
+			// can't be monitored with it afterwards.
+			defer func() {
+				closed = true
+				wafCtx.Close()
+			}()
+
+			// Run the WAF on the rule addresses available in the response and
+			// the request body.
+			values := make(map[string]interface{}, 4)
 			for _, addr := range addresses {
 				switch addr {
-				case serverRequestRawURIAddr:
-					values[serverRequestRawURIAddr] = args.RequestURI
-				case serverRequestHeadersNoCookiesAddr:
-					if headers := args.Headers; headers != nil {
-						values[serverRequestHeadersNoCookiesAddr] = headers
-					}
-				case serverRequestCookiesAddr:
-					if cookies := args.Cookies; cookies != nil {
-						values[serverRequestCookiesAddr] = cookies
-					}
-				case serverRequestQueryAddr:
-					if query := args.Query; query != nil {
-						values[serverRequestQueryAddr] = query
-					}
-				case serverRequestPathParams:
-					if pathParams := args.PathParams; pathParams != nil {
-						values[serverRequestPathParams] = pathParams
-					}
 				case serverRequestBody:
 					if body != nil {
 						values[serverRequestBody] = body
 					}
 				case serverResponseStatusAddr:
 					values[serverResponseStatusAddr] = res.Status
+				case serverResponseHeadersNoCookiesAddr:
+					if headers := res.Headers; headers != nil {
+						values[serverResponseHeadersNoCookiesAddr] = headers
+					}
+				case serverResponseBody:
+					if responseBody != nil {
+						values[serverResponseBody] = responseBody
+					}
 				}
 			}
-			matches := runWAF(wafCtx, values, timeout)
+			if matches, _ := runWAF(wafCtx, values, timeout); len(matches) > 0 {
+				events = append(events, matches)
+			}
 
 			// Add WAF metrics.
 			rInfo := handle.RulesetInfo()
 			overallRuntimeNs, internalRuntimeNs := wafCtx.TotalRuntime()
 			addWAFMonitoringTags(op, rInfo.Version, overallRuntimeNs, internalRuntimeNs, wafCtx.TotalTimeouts())
+			metrics.recordRequest(rInfo.Version, overallRuntimeNs, internalRuntimeNs, wafCtx.TotalTimeouts(), len(events) > 0, op.Blocked())
 
 			// Add the following metrics once per instantiation of a WAF handle
 			monitorRulesOnce.Do(func() {
@@ -161,12 +265,12 @@ func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 			})
 
 			// Log the attacks if any
-			if len(matches) == 0 {
+			if len(events) == 0 {
 				return
 			}
 			log.Debug("appsec: attack detected by the waf")
 			if limiter.Allow() {
-				op.AddSecurityEvents(matches)
+				op.AddSecurityEvents(events...)
 			}
 		}))
 	})
@@ -174,7 +278,7 @@ func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 
 // newGRPCWAFEventListener returns the WAF event listener to register in order
 // to enable it.
-func newGRPCWAFEventListener(handle *waf.Handle, _ []string, timeout time.Duration, limiter Limiter) dyngo.EventListener {
+func newGRPCWAFEventListener(handle *waf.Handle, addresses []string, timeout time.Duration, limiter Limiter, metrics *wafMetrics) dyngo.EventListener {
 	var monitorRulesOnce sync.Once // per instantiation
 
 	return grpcsec.OnHandlerOperationStart(func(op *grpcsec.HandlerOperation, handlerArgs grpcsec.HandlerOperationArgs) {
@@ -192,13 +296,11 @@ func newGRPCWAFEventListener(handle *waf.Handle, _ []string, timeout time.Durati
 			mu     sync.Mutex // events mutex
 		)
 
-		op.On(grpcsec.OnReceiveOperationFinish(func(_ grpcsec.ReceiveOperation, res grpcsec.ReceiveOperationRes) {
-			if atomic.LoadUint32(&nbEvents) == maxWAFEventsPerRequest {
-				logOnce.Do(func() {
-					log.Debug("appsec: ignoring the rpc message due to the maximum number of security events per grpc call reached")
-				})
-				return
-			}
+		// run runs the WAF on the given values with a new WAF context, records
+		// the security event if any, and blocks the handler operation when
+		// required by the WAF actions. The matches only involving the ignored
+		// address, if any, are not recorded.
+		run := func(values map[string]interface{}, ignored string) {
 			// The current workaround of the WAF context limitations is to
 			// simply instantiate and release the WAF context for the operation
 			// lifetime so that:
@@ -213,15 +315,7 @@ func newGRPCWAFEventListener(handle *waf.Handle, _ []string, timeout time.Durati
 				return
 			}
 			defer wafCtx.Close()
-			// Run the WAF on the rule addresses available in the args
-			// Note that we don't check if the address is present in the rules
-			// as we only support one at the moment, so this callback cannot be
-			// set when the address is not present.
-			values := map[string]interface{}{grpcServerRequestMessage: res.Message}
-			if md := handlerArgs.Metadata; len(md) > 0 {
-				values[grpcServerRequestMetadata] = md
-			}
-			event := runWAF(wafCtx, values, timeout)
+			event, actions := runWAF(wafCtx, values, timeout)
 
 			// WAF run durations are WAF context bound. As of now we need to keep track of those externally since
 			// we use a new WAF context for each callback. When we are able to re-use the same WAF context across
@@ -231,6 +325,13 @@ func newGRPCWAFEventListener(handle *waf.Handle, _ []string, timeout time.Durati
 			internalRuntimeNs.Add(internal)
 			nbTimeouts.Add(wafCtx.TotalTimeouts())
 
+			if isBlockingAction(actions) {
+				log.Debug("appsec: blocking the rpc as requested by the grpc waf")
+				op.Block()
+			}
+			if ignored != "" {
+				event = withoutAddressMatches(event, ignored)
+			}
 			if len(event) == 0 {
 				return
 			}
@@ -239,11 +340,42 @@ func newGRPCWAFEventListener(handle *waf.Handle, _ []string, timeout time.Durati
 			mu.Lock()
 			events = append(events, event)
 			mu.Unlock()
+		}
+
+		// The metadata is known at the start of the operation, so we run the
+		// WAF on it right away in order to be able to block the RPC before its
+		// handler gets called.
+		for _, addr := range addresses {
+			if md := handlerArgs.Metadata; addr == grpcServerRequestMetadata && len(md) > 0 {
+				run(map[string]interface{}{grpcServerRequestMetadata: md}, "")
+			}
+		}
+
+		op.On(grpcsec.OnReceiveOperationFinish(func(_ grpcsec.ReceiveOperation, res grpcsec.ReceiveOperationRes) {
+			if atomic.LoadUint32(&nbEvents) == maxWAFEventsPerRequest {
+				logOnce.Do(func() {
+					log.Debug("appsec: ignoring the rpc message due to the maximum number of security events per grpc call reached")
+				})
+				return
+			}
+			// Run the WAF on the rule addresses available in the args
+			// Note that we don't check if the address is present in the rules
+			// as we only support one at the moment, so this callback cannot be
+			// set when the address is not present. The metadata is passed along
+			// so that rules combining both addresses can match, while the
+			// matches of the metadata alone were already recorded at the start
+			// of the operation.
+			values := map[string]interface{}{grpcServerRequestMessage: res.Message}
+			if md := handlerArgs.Metadata; len(md) > 0 {
+				values[grpcServerRequestMetadata] = md
+			}
+			run(values, grpcServerRequestMetadata)
 		}))
 
 		op.On(grpcsec.OnHandlerOperationFinish(func(op *grpcsec.HandlerOperation, _ grpcsec.HandlerOperationRes) {
 			rInfo := handle.RulesetInfo()
 			addWAFMonitoringTags(op, rInfo.Version, overallRuntimeNs.Load(), internalRuntimeNs.Load(), nbTimeouts.Load())
+			metrics.recordRequest(rInfo.Version, overallRuntimeNs.Load(), internalRuntimeNs.Load(), nbTimeouts.Load(), atomic.LoadUint32(&nbEvents) > 0, op.Blocked())
 
 			// Log the following metrics once per instantiation of a WAF handle
 			monitorRulesOnce.Do(func() {
@@ -259,32 +391,165 @@ func newGRPCWAFEventListener(handle *waf.Handle, _ []string, timeout time.Durati
 	})
 }
 
-func runWAF(wafCtx *waf.Context, values map[string]interface{}, timeout time.Duration) []byte {
-	matches, _, err := wafCtx.Run(values, timeout)
+// newGraphQLWAFEventListener returns the WAF event listener to register in
+// order to enable it.
+func newGraphQLWAFEventListener(handle *waf.Handle, timeout time.Duration, limiter Limiter, metrics *wafMetrics) dyngo.EventListener {
+	var monitorRulesOnce sync.Once // per instantiation
+
+	return graphqlsec.OnRequestOperationStart(func(op *graphqlsec.RequestOperation, _ graphqlsec.RequestOperationArgs) {
+		wafCtx := waf.NewContext(handle)
+		if wafCtx == nil {
+			// The WAF event listener got concurrently released
+			return
+		}
+
+		var (
+			events []json.RawMessage
+			mu     sync.Mutex // events mutex, as fields can be resolved concurrently
+		)
+		op.On(graphqlsec.OnResolveOperationStart(func(_ *graphqlsec.ResolveOperation, args graphqlsec.ResolveOperationArgs) {
+			if len(args.Arguments) == 0 {
+				return
+			}
+			// Note that we don't check if the address is present in the rules
+			// as we only support one at the moment, so this callback cannot be
+			// set when the address is not present.
+			values := map[string]interface{}{
+				graphqlServerAllResolversAddr: map[string][]map[string]interface{}{
+					args.FieldName: {args.Arguments},
+				},
+			}
+			matches, _ := runWAF(wafCtx, values, timeout)
+			if len(matches) == 0 {
+				return
+			}
+			log.Debug("appsec: attack detected by the graphql waf")
+			mu.Lock()
+			events = append(events, matches)
+			mu.Unlock()
+		}))
+
+		op.On(graphqlsec.OnRequestOperationFinish(func(op *graphqlsec.RequestOperation, _ graphqlsec.RequestOperationRes) {
+			defer wafCtx.Close()
+
+			rInfo := handle.RulesetInfo()
+			overallRuntimeNs, internalRuntimeNs := wafCtx.TotalRuntime()
+			addWAFMonitoringTags(op, rInfo.Version, overallRuntimeNs, internalRuntimeNs, wafCtx.TotalTimeouts())
+			mu.Lock()
+			triggered := len(events) > 0
+			mu.Unlock()
+			metrics.recordRequest(rInfo.Version, overallRuntimeNs, internalRuntimeNs, wafCtx.TotalTimeouts(), triggered, false)
+
+			// Log the following metrics once per instantiation of a WAF handle
+			monitorRulesOnce.Do(func() {
+				addRulesMonitoringTags(op, rInfo)
+				op.AddTag(ext.ManualKeep, samplernames.AppSec)
+			})
+
+			// Log the events if any
+			mu.Lock()
+			defer mu.Unlock()
+			if len(events) > 0 && limiter.Allow() {
+				op.AddSecurityEvents(events...)
+			}
+		}))
+	})
+}
+
+func runWAF(wafCtx *waf.Context, values map[string]interface{}, timeout time.Duration) (matches []byte, actions []string) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	matches, actions, err := wafCtx.Run(values, timeout)
 	if err != nil {
 		if err == waf.ErrTimeout {
 			log.Debug("appsec: waf timeout value of %s reached", timeout)
 		} else {
 			log.Error("appsec: unexpected waf error: %v", err)
-			return nil
+			return nil, nil
 		}
 	}
-	return matches
+	return matches, actions
+}
+
+// withoutAddressMatches returns the WAF matches without the rules which only
+// matched values of the given address. It returns nil if no match is left.
+func withoutAddressMatches(matches []byte, addr string) []byte {
+	var events []json.RawMessage
+	if err := json.Unmarshal(matches, &events); err != nil {
+		log.Error("appsec: unexpected waf matches format: %v", err)
+		return matches
+	}
+	kept := events[:0]
+	for _, event := range events {
+		var match struct {
+			RuleMatches []struct {
+				Parameters []struct {
+					Address string `json:"address"`
+				} `json:"parameters"`
+			} `json:"rule_matches"`
+		}
+		if err := json.Unmarshal(event, &match); err != nil {
+			log.Error("appsec: unexpected waf match format: %v", err)
+			return matches
+		}
+		only := true
+		for _, rm := range match.RuleMatches {
+			for _, param := range rm.Parameters {
+				only = only && param.Address == addr
+			}
+		}
+		if !only {
+			kept = append(kept, event)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	if len(kept) == len(events) {
+		return matches
+	}
+	filtered, err := json.Marshal(kept)
+	if err != nil {
+		log.Error("appsec: unexpected error while encoding the waf matches: %v", err)
+		return matches
+	}
+	return filtered
+}
+
+// blockAction is the WAF action returned when a rule with the `block`
+// on_match behaviour matched.
+const blockAction = "block"
+
+// isBlockingAction returns true when the given WAF actions require blocking
+// the request.
+func isBlockingAction(actions []string) bool {
+	for _, action := range actions {
+		if action == blockAction {
+			return true
+		}
+	}
+	return false
 }
 
 // HTTP rule addresses currently supported by the WAF
 const (
-	serverRequestRawURIAddr           = "server.request.uri.raw"
-	serverRequestHeadersNoCookiesAddr = "server.request.headers.no_cookies"
-	serverRequestCookiesAddr          = "server.request.cookies"
-	serverRequestQueryAddr            = "server.request.query"
-	serverRequestPathParams           = "server.request.path_params"
-	serverRequestBody                 = "server.request.body"
-	serverResponseStatusAddr          = "server.response.status"
+	httpClientIPAddr                   = "http.client_ip"
+	serverRequestRawURIAddr            = "server.request.uri.raw"
+	serverRequestHeadersNoCookiesAddr  = "server.request.headers.no_cookies"
+	serverRequestCookiesAddr           = "server.request.cookies"
+	serverRequestQueryAddr             = "server.request.query"
+	serverRequestPathParams            = "server.request.path_params"
+	serverRequestBody                  = "server.request.body"
+	serverResponseStatusAddr           = "server.response.status"
+	serverResponseHeadersNoCookiesAddr = "server.response.headers.no_cookies"
+	serverResponseBody                 = "server.response.body"
+	userIDAddr                         = "usr.id"
 )
 
 // List of HTTP rule addresses currently supported by the WAF
 var httpAddresses = []string{
+	httpClientIPAddr,
 	serverRequestRawURIAddr,
 	serverRequestHeadersNoCookiesAddr,
 	serverRequestCookiesAddr,
@@ -292,6 +557,9 @@ var httpAddresses = []string{
 	serverRequestPathParams,
 	serverRequestBody,
 	serverResponseStatusAddr,
+	serverResponseHeadersNoCookiesAddr,
+	serverResponseBody,
+	userIDAddr,
 }
 
 // gRPC rule addresses currently supported by the WAF
@@ -306,21 +574,34 @@ var grpcAddresses = []string{
 	grpcServerRequestMetadata,
 }
 
+// GraphQL rule addresses currently supported by the WAF
+const (
+	graphqlServerAllResolversAddr = "graphql.server.all_resolvers"
+)
+
+// List of GraphQL rule addresses currently supported by the WAF
+var graphqlAddresses = []string{
+	graphqlServerAllResolversAddr,
+}
+
 func init() {
 	// sort the address lists to avoid mistakes and use sort.SearchStrings()
 	sort.Strings(httpAddresses)
 	sort.Strings(grpcAddresses)
+	sort.Strings(graphqlAddresses)
 }
 
 // supportedAddresses returns the list of addresses we actually support from the
 // given rule addresses.
-func supportedAddresses(ruleAddresses []string) (supportedHTTP, supportedGRPC, notSupported []string) {
+func supportedAddresses(ruleAddresses []string) (supportedHTTP, supportedGRPC, supportedGraphQL, notSupported []string) {
 	// Filter the supported addresses only
 	for _, addr := range ruleAddresses {
 		if i := sort.SearchStrings(httpAddresses, addr); i < len(httpAddresses) && httpAddresses[i] == addr {
 			supportedHTTP = append(supportedHTTP, addr)
 		} else if i := sort.SearchStrings(grpcAddresses, addr); i < len(grpcAddresses) && grpcAddresses[i] == addr {
 			supportedGRPC = append(supportedGRPC, addr)
+		} else if i := sort.SearchStrings(graphqlAddresses, addr); i < len(graphqlAddresses) && graphqlAddresses[i] == addr {
+			supportedGraphQL = append(supportedGraphQL, addr)
 		} else {
 			notSupported = append(notSupported, addr)
 		}
@@ -0,0 +1,199 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/grpcsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// rulesWatchInterval is the polling interval of the rules files.
+var rulesWatchInterval = 5 * time.Second
+
+// wafSwapper holds the current WAF state and allows to atomically replace it
+// while the WAF event listeners stay registered. The WAF handles being
+// reference-counted, the requests being monitored by a previous WAF handle can
+// safely finish using it once it was swapped.
+type wafSwapper struct {
+	current atomic.Value // *wafState
+
+	mu sync.Mutex
+	// rulesData is the last rules data received through remote config, which
+	// needs to be applied again to the new WAF handles.
+	rulesData []rc.ASMDataRuleData
+}
+
+func (s *wafSwapper) load() *wafState {
+	state, _ := s.current.Load().(*wafState)
+	return state
+}
+
+// swap replaces the current WAF state with the given one and releases the
+// previous WAF handle.
+func (s *wafSwapper) swap(state *wafState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rulesData != nil {
+		if err := state.handle.UpdateRulesData(s.rulesData); err != nil {
+			log.Error("appsec: could not update the rules data of the new WAF handle: %v", err)
+		}
+	}
+	prev := s.load()
+	s.current.Store(state)
+	if prev != nil {
+		prev.handle.Close()
+	}
+}
+
+// UpdateRulesData updates the rules data of the current WAF handle and keeps
+// them for the next ones.
+func (s *wafSwapper) UpdateRulesData(data []rc.ASMDataRuleData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rulesData = data
+	return s.load().handle.UpdateRulesData(data)
+}
+
+// close releases the current WAF handle.
+func (s *wafSwapper) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state := s.load(); state != nil {
+		state.handle.Close()
+	}
+}
+
+// listeners returns the event listeners forwarding the events to the
+// listeners of the current WAF state.
+func (s *wafSwapper) listeners() []dyngo.EventListener {
+	return []dyngo.EventListener{
+		httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, args httpsec.HandlerOperationArgs) {
+			if l := s.load().http; l != nil {
+				l.Call(op, args)
+			}
+		}),
+		grpcsec.OnHandlerOperationStart(func(op *grpcsec.HandlerOperation, args grpcsec.HandlerOperationArgs) {
+			if l := s.load().grpc; l != nil {
+				l.Call(op, args)
+			}
+		}),
+		graphqlsec.OnRequestOperationStart(func(op *graphqlsec.RequestOperation, args graphqlsec.RequestOperationArgs) {
+			if l := s.load().graphql; l != nil {
+				l.Call(op, args)
+			}
+		}),
+	}
+}
+
+// reloadRules reloads the security rules from the rules files and swaps the
+// current WAF state with a new one using them. The current WAF state is kept
+// when the new rules cannot be loaded.
+func (a *appsec) reloadRules(wafs *wafSwapper) {
+	rules, err := loadRules(a.cfg.rulesFile, a.cfg.customRulesFile)
+	if err != nil {
+		log.Error("appsec: could not reload the security rules, keeping the current ones: %v", err)
+		return
+	}
+	state, err := newWAFState(rules, a.cfg, a.limiter, a.metrics)
+	if err != nil {
+		a.metrics.recordRuleset(wafUpdatesMetric, waf.RulesetInfo{}, false)
+		log.Error("appsec: could not instantiate the WAF with the reloaded security rules, keeping the current ones: %v", err)
+		return
+	}
+	info := state.handle.RulesetInfo()
+	if len(info.Errors) > 0 {
+		log.Error("appsec: reloaded security rules errors: %v", info.Errors)
+	}
+	a.metrics.recordRuleset(wafUpdatesMetric, info, info.Loaded > 0)
+	if info.Loaded == 0 {
+		state.handle.Close()
+		log.Error("appsec: no valid security rules could be reloaded, keeping the current ones")
+		return
+	}
+	wafs.swap(state)
+	log.Info("appsec: security rules reloaded (version %s, loaded %d, failed %d)", info.Version, info.Loaded, info.Failed)
+}
+
+// watchFiles polls the given files every interval and calls onChange when the
+// modification time or the size of one of them changes. Empty file paths are
+// ignored and nothing is watched when there are no file paths. The returned
+// function stops watching the files.
+func watchFiles(files []string, interval time.Duration, onChange func()) (stop func()) {
+	var paths []string
+	for _, f := range files {
+		if f != "" {
+			paths = append(paths, f)
+		}
+	}
+	if len(paths) == 0 {
+		return func() {}
+	}
+
+	type fileStat struct {
+		modTime time.Time
+		size    int64
+		err     bool
+	}
+	statFiles := func() []fileStat {
+		stats := make([]fileStat, len(paths))
+		for i, p := range paths {
+			fi, err := os.Stat(p)
+			if err != nil {
+				stats[i].err = true
+				continue
+			}
+			stats[i] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+		}
+		return stats
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := statFiles()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			stats := statFiles()
+			changed := false
+			for i := range stats {
+				if stats[i] != last[i] {
+					changed = true
+					break
+				}
+			}
+			last = stats
+			if changed {
+				onChange()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,62 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFiles(t *testing.T) {
+	t.Run("no-files", func(t *testing.T) {
+		stop := watchFiles([]string{"", ""}, time.Millisecond, func() {
+			t.Fatal("unexpected call")
+		})
+		stop()
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rules.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+
+		changes := make(chan struct{}, 10)
+		stop := watchFiles([]string{path, ""}, 10*time.Millisecond, func() {
+			changes <- struct{}{}
+		})
+		defer stop()
+
+		// Let the watcher take the initial snapshot of the file
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-changes:
+			t.Fatal("unexpected change")
+		default:
+		}
+
+		// Changing the file size is detected
+		require.NoError(t, os.WriteFile(path, []byte(`{"rules": []}`), 0644))
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("the file change was not detected")
+		}
+
+		// Removing the file is detected
+		require.NoError(t, os.Remove(path))
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("the file removal was not detected")
+		}
+	})
+}
@@ -13,13 +13,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	pAppsec "gopkg.in/DataDog/dd-trace-go.v1/appsec"
 	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/waf"
 
 	"github.com/stretchr/testify/require"
 )
@@ -34,6 +38,9 @@ func TestWAF(t *testing.T) {
 	if !appsec.Enabled() {
 		t.Skip("appsec disabled")
 	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available: these tests rely on the waf rules, the denylist fallback is tested by internal/appsec")
+	}
 
 	// Start and trace an HTTP server
 	mux := httptrace.NewServeMux()
@@ -172,3 +179,148 @@ func TestWAF(t *testing.T) {
 		require.NotContains(t, event, sensitivePayloadValue)
 	})
 }
+
+// blockingRules is a ruleset blocking the requests sent with a user-agent
+// header containing "blocking-test".
+const blockingRules = `{
+  "version": "2.2",
+  "metadata": {"rules_version": "1.4.2"},
+  "rules": [
+    {
+      "id": "block-ua",
+      "name": "Block user agent",
+      "tags": {"type": "security_scanner", "category": "attack_attempt"},
+      "conditions": [
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "server.request.headers.no_cookies", "key_path": ["user-agent"]}], "regex": "blocking-test"}
+        }
+      ],
+      "transformers": [],
+      "on_match": ["block"]
+    },
+    {
+      "id": "block-user",
+      "name": "Block user",
+      "tags": {"type": "security_scanner", "category": "attack_attempt"},
+      "conditions": [
+        {
+          "operator": "match_regex",
+          "parameters": {"inputs": [{"address": "usr.id"}], "regex": "blocked-user"}
+        }
+      ],
+      "transformers": [],
+      "on_match": ["block"]
+    }
+  ]
+}`
+
+// TestBlocking validates that the requests matching a rule with the block
+// action are blocked before reaching the handler of a net/http server.
+func TestBlocking(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(rules, []byte(blockingRules), 0644))
+	t.Setenv("DD_APPSEC_RULES", rules)
+	appsec.Start()
+	defer appsec.Stop()
+
+	if !appsec.Enabled() {
+		t.Skip("appsec disabled")
+	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available: these tests rely on the waf rules, the denylist fallback is tested by internal/appsec")
+	}
+
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello World!\n"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name        string
+		userAgent   string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{name: "not-blocked", userAgent: "Mozilla/5.0", status: http.StatusOK},
+		{name: "json", userAgent: "blocking-test", status: http.StatusForbidden, contentType: "application/json"},
+		{name: "html", userAgent: "blocking-test", accept: "text/html", status: http.StatusForbidden, contentType: "text/html"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			req, err := http.NewRequest("GET", srv.URL, nil)
+			require.NoError(t, err)
+			req.Header.Set("User-Agent", tc.userAgent)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			res, err := srv.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, tc.status, res.StatusCode)
+
+			finished := mt.FinishedSpans()
+			require.Len(t, finished, 1)
+			if tc.status == http.StatusOK {
+				require.Nil(t, finished[0].Tag("appsec.blocked"))
+				return
+			}
+			require.Equal(t, tc.contentType, res.Header.Get("Content-Type"))
+			require.Equal(t, true, finished[0].Tag("appsec.blocked"))
+			require.Contains(t, finished[0].Tag("_dd.appsec.json"), "block-ua")
+		})
+	}
+}
+
+// TestUserMonitoringRace validates that the user id can be monitored from
+// goroutines started by the handler, including after the handler returned
+// and released its WAF context. It is meant to be run with -race.
+func TestUserMonitoringRace(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(rules, []byte(blockingRules), 0644))
+	t.Setenv("DD_APPSEC_RULES", rules)
+	appsec.Start()
+	defer appsec.Stop()
+
+	if !appsec.Enabled() {
+		t.Skip("appsec disabled")
+	}
+	if waf.Health() != nil {
+		t.Skip("the waf is not available: these tests rely on the waf rules, the denylist fallback is tested by internal/appsec")
+	}
+
+	var wg sync.WaitGroup
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				user := "monitored-user"
+				if i%2 == 0 {
+					user = "blocked-user"
+				}
+				pAppsec.SetUser(ctx, user)
+			}
+		}()
+		w.Write([]byte("Hello World!\n"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	for i := 0; i < 10; i++ {
+		res, err := srv.Client().Get(srv.URL)
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+	wg.Wait()
+	require.Len(t, mt.FinishedSpans(), 10)
+}
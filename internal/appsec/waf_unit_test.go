@@ -38,3 +38,30 @@ func TestTagsTypes(t *testing.T) {
 		require.Contains(t, tags, tag)
 	}
 }
+
+func TestWithoutAddressMatches(t *testing.T) {
+	const (
+		metadataMatch = `{"rule":{"id":"metadata"},"rule_matches":[{"parameters":[{"address":"grpc.server.request.metadata"}]}]}`
+		messageMatch  = `{"rule":{"id":"message"},"rule_matches":[{"parameters":[{"address":"grpc.server.request.message"}]}]}`
+		combinedMatch = `{"rule":{"id":"combined"},"rule_matches":[{"parameters":[{"address":"grpc.server.request.metadata"}]},{"parameters":[{"address":"grpc.server.request.message"}]}]}`
+	)
+	for _, tc := range []struct {
+		name     string
+		matches  string
+		expected string
+	}{
+		{name: "metadata-only", matches: "[" + metadataMatch + "]"},
+		{name: "message-only", matches: "[" + messageMatch + "]", expected: "[" + messageMatch + "]"},
+		{name: "combined", matches: "[" + combinedMatch + "]", expected: "[" + combinedMatch + "]"},
+		{name: "mixed", matches: "[" + metadataMatch + "," + messageMatch + "]", expected: "[" + messageMatch + "]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := withoutAddressMatches([]byte(tc.matches), grpcServerRequestMetadata)
+			if tc.expected == "" {
+				require.Nil(t, filtered)
+				return
+			}
+			require.JSONEq(t, tc.expected, string(filtered))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package datastreams
+
+import "context"
+
+type contextKey struct{}
+
+// ContextWithPathway returns a copy of ctx carrying the given pathway.
+func ContextWithPathway(ctx context.Context, p Pathway) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PathwayFromContext returns the pathway carried by ctx, if any.
+func PathwayFromContext(ctx context.Context) (p Pathway, ok bool) {
+	if ctx == nil {
+		return p, false
+	}
+	p, ok = ctx.Value(contextKey{}).(Pathway)
+	return p, ok
+}
+
+// SetCheckpoint sets a checkpoint identified by the given edge tags on the
+// pathway carried by ctx, or on a new pathway when ctx carries none. It
+// returns the resulting pathway along with a copy of ctx carrying it.
+func SetCheckpoint(ctx context.Context, edgeTags ...string) (Pathway, context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p, ok := PathwayFromContext(ctx)
+	if ok {
+		p = p.SetCheckpoint(edgeTags...)
+	} else {
+		p = NewPathway(edgeTags...)
+	}
+	return p, ContextWithPathway(ctx, p)
+}
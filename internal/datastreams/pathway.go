@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+// Package datastreams implements Data Streams Monitoring, which measures the
+// end-to-end latency of the pipelines messages go through. Each service a
+// message goes through sets a checkpoint on its pathway, which is propagated
+// along with the message, and the latencies between the checkpoints are
+// aggregated and sent to the agent.
+package datastreams
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// Pathway is the path a message went through, identified by the hash of the
+// checkpoints it went through, along with the time at which it started and
+// the time of its latest checkpoint.
+type Pathway struct {
+	hash         uint64
+	pathwayStart time.Time
+	edgeStart    time.Time
+}
+
+// Hash returns the hash of the pathway, identifying all the checkpoints the
+// message went through.
+func (p Pathway) Hash() uint64 { return p.hash }
+
+// PathwayStart returns the time at which the pathway started.
+func (p Pathway) PathwayStart() time.Time { return p.pathwayStart }
+
+// EdgeStart returns the time of the latest checkpoint of the pathway.
+func (p Pathway) EdgeStart() time.Time { return p.edgeStart }
+
+// NewPathway starts a new pathway with a first checkpoint identified by the
+// given edge tags, such as "direction:out", "topic:orders" or "type:kafka".
+func NewPathway(edgeTags ...string) Pathway {
+	return newPathway(time.Now(), edgeTags...)
+}
+
+func newPathway(now time.Time, edgeTags ...string) Pathway {
+	p := Pathway{
+		pathwayStart: now,
+		edgeStart:    now,
+	}
+	return p.setCheckpoint(now, edgeTags)
+}
+
+// SetCheckpoint returns the pathway resulting from adding a checkpoint
+// identified by the given edge tags to p, and records the latency since the
+// previous checkpoint and since the start of the pathway when Data Streams
+// Monitoring is enabled.
+func (p Pathway) SetCheckpoint(edgeTags ...string) Pathway {
+	return p.setCheckpoint(time.Now(), edgeTags)
+}
+
+func (p Pathway) setCheckpoint(now time.Time, edgeTags []string) Pathway {
+	proc := getGlobalProcessor()
+	var service, env string
+	if proc != nil {
+		service, env = proc.service, proc.env
+	}
+	child := Pathway{
+		hash:         pathwayHash(nodeHash(service, env, edgeTags), p.hash),
+		pathwayStart: p.pathwayStart,
+		edgeStart:    now,
+	}
+	if proc != nil {
+		proc.add(statsPoint{
+			edgeTags:       edgeTags,
+			parentHash:     p.hash,
+			hash:           child.hash,
+			timestamp:      now.UnixNano(),
+			pathwayLatency: now.Sub(p.pathwayStart).Nanoseconds(),
+			edgeLatency:    now.Sub(p.edgeStart).Nanoseconds(),
+		})
+	}
+	return child
+}
+
+// nodeHash returns the hash of a checkpoint of the given service and env,
+// identified by the given edge tags regardless of their order.
+func nodeHash(service, env string, edgeTags []string) uint64 {
+	tags := append([]string(nil), edgeTags...)
+	sort.Strings(tags)
+	h := fnv.New64()
+	h.Write([]byte(service))
+	h.Write([]byte(env))
+	for _, t := range tags {
+		h.Write([]byte(t))
+	}
+	return h.Sum64()
+}
+
+// pathwayHash returns the hash of the pathway resulting from adding the
+// checkpoint of the given node hash to the pathway of the given parent hash.
+func pathwayHash(nodeHash, parentHash uint64) uint64 {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b, nodeHash)
+	binary.LittleEndian.PutUint64(b[8:], parentHash)
+	h := fnv.New64()
+	h.Write(b)
+	return h.Sum64()
+}
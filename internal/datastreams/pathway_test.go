@@ -0,0 +1,80 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package datastreams
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathway(t *testing.T) {
+	t.Run("hash", func(t *testing.T) {
+		start := time.Now()
+		p := newPathway(start, "direction:out", "topic:topic1", "type:kafka")
+		assert.Equal(t, pathwayHash(nodeHash("", "", []string{"direction:out", "topic:topic1", "type:kafka"}), 0), p.Hash())
+		assert.Equal(t, start, p.PathwayStart())
+		assert.Equal(t, start, p.EdgeStart())
+
+		// The edge tags order doesn't matter
+		assert.Equal(t, p.Hash(), newPathway(start, "type:kafka", "topic:topic1", "direction:out").Hash())
+		// Different edge tags lead to different hashes
+		assert.NotEqual(t, p.Hash(), newPathway(start, "direction:out", "topic:topic2", "type:kafka").Hash())
+	})
+
+	t.Run("checkpoint", func(t *testing.T) {
+		start := time.Now()
+		p := newPathway(start, "direction:out", "topic:topic1", "type:kafka")
+		now := start.Add(time.Second)
+		child := p.setCheckpoint(now, []string{"direction:in", "topic:topic1", "type:kafka"})
+		assert.Equal(t, pathwayHash(nodeHash("", "", []string{"direction:in", "topic:topic1", "type:kafka"}), p.Hash()), child.Hash())
+		assert.Equal(t, start, child.PathwayStart())
+		assert.Equal(t, now, child.EdgeStart())
+	})
+
+	t.Run("processor", func(t *testing.T) {
+		proc := newProcessor("service", "env", &fakeTransport{})
+		setGlobalProcessor(proc)
+		defer setGlobalProcessor(nil)
+
+		start := time.Now()
+		p := newPathway(start, "direction:out", "type:kafka")
+		assert.Equal(t, pathwayHash(nodeHash("service", "env", []string{"direction:out", "type:kafka"}), 0), p.Hash())
+		child := p.setCheckpoint(start.Add(time.Second), []string{"direction:in", "type:kafka"})
+
+		require.Len(t, proc.in, 2)
+		<-proc.in
+		point := <-proc.in
+		assert.Equal(t, statsPoint{
+			edgeTags:       []string{"direction:in", "type:kafka"},
+			hash:           child.Hash(),
+			parentHash:     p.Hash(),
+			timestamp:      start.Add(time.Second).UnixNano(),
+			pathwayLatency: time.Second.Nanoseconds(),
+			edgeLatency:    time.Second.Nanoseconds(),
+		}, point)
+	})
+}
+
+func TestSetCheckpoint(t *testing.T) {
+	p, ctx := SetCheckpoint(context.Background(), "direction:out", "type:kafka")
+	got, ok := PathwayFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, p, got)
+
+	child, ctx := SetCheckpoint(ctx, "direction:in", "type:kafka")
+	assert.Equal(t, pathwayHash(nodeHash("", "", []string{"direction:in", "type:kafka"}), p.Hash()), child.Hash())
+	assert.Equal(t, p.PathwayStart(), child.PathwayStart())
+	got, ok = PathwayFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, child, got)
+
+	_, ok = PathwayFromContext(context.Background())
+	assert.False(t, ok)
+}
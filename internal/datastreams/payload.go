@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+//go:generate msgp -unexported -marshal=false -o=payload_msgp.go -tests=false
+
+package datastreams
+
+// statsPayload is the data streams stats payload sent to the agent.
+type statsPayload struct {
+	// Env specifies the env of the application, as defined by the user.
+	Env string
+	// Service specifies the service of the application.
+	Service string
+	// Stats holds all stats buckets computed within this payload.
+	Stats []statsBucket
+	// TracerVersion is the version of the tracer.
+	TracerVersion string
+	// Lang is the tracer language.
+	Lang string
+}
+
+// statsBucket specifies a set of stats computed over a duration.
+type statsBucket struct {
+	// Start specifies the beginning of this bucket in unix nanoseconds.
+	Start uint64
+	// Duration specifies the duration of this bucket in nanoseconds.
+	Duration uint64
+	// Stats contains a set of statistics computed for the duration of this bucket.
+	Stats []statsGroup
+}
+
+// statsGroup contains the latency statistics of a pathway checkpoint.
+type statsGroup struct {
+	// Service is the service of the checkpoint.
+	Service string
+	// EdgeTags are the tags identifying the checkpoint.
+	EdgeTags []string
+	// Hash is the hash of the pathway ending with the checkpoint.
+	Hash uint64
+	// ParentHash is the hash of the pathway before the checkpoint.
+	ParentHash uint64
+	// PathwayLatency is the DDSketch of the latencies since the start of the
+	// pathway, encoded as protobuf.
+	PathwayLatency []byte
+	// EdgeLatency is the DDSketch of the latencies since the previous
+	// checkpoint, encoded as protobuf.
+	EdgeLatency []byte
+}
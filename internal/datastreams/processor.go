@@ -0,0 +1,296 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package datastreams
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"google.golang.org/protobuf/proto"
+)
+
+// bucketDuration is the time span covered by a stats bucket.
+var bucketDuration = 10 * time.Second
+
+// statsPoint is the latency measurement of a checkpoint.
+type statsPoint struct {
+	edgeTags       []string
+	hash           uint64
+	parentHash     uint64
+	timestamp      int64
+	pathwayLatency int64
+	edgeLatency    int64
+}
+
+// statsGroupKey is the aggregation key of the stats points of a bucket.
+type statsGroupKey struct {
+	hash       uint64
+	parentHash uint64
+}
+
+type rawStatsGroup struct {
+	edgeTags       []string
+	pathwayLatency *ddsketch.DDSketch
+	edgeLatency    *ddsketch.DDSketch
+}
+
+func newRawStatsGroup(edgeTags []string) *rawStatsGroup {
+	const (
+		// relativeAccuracy is the value accuracy we have on the percentiles.
+		relativeAccuracy = 0.01
+		// maxNumBins is the maximum number of bins of the DDSketch we use to
+		// store percentiles.
+		maxNumBins = 2048
+	)
+	pathwayLatency, err := ddsketch.LogCollapsingLowestDenseDDSketch(relativeAccuracy, maxNumBins)
+	if err != nil {
+		log.Error("Error when creating ddsketch: %v", err)
+	}
+	edgeLatency, err := ddsketch.LogCollapsingLowestDenseDDSketch(relativeAccuracy, maxNumBins)
+	if err != nil {
+		log.Error("Error when creating ddsketch: %v", err)
+	}
+	return &rawStatsGroup{
+		edgeTags:       edgeTags,
+		pathwayLatency: pathwayLatency,
+		edgeLatency:    edgeLatency,
+	}
+}
+
+func (g *rawStatsGroup) export(service string, k statsGroupKey) (statsGroup, error) {
+	pathwayLatency, err := proto.Marshal(g.pathwayLatency.ToProto())
+	if err != nil {
+		return statsGroup{}, err
+	}
+	edgeLatency, err := proto.Marshal(g.edgeLatency.ToProto())
+	if err != nil {
+		return statsGroup{}, err
+	}
+	return statsGroup{
+		Service:        service,
+		EdgeTags:       g.edgeTags,
+		Hash:           k.hash,
+		ParentHash:     k.parentHash,
+		PathwayLatency: pathwayLatency,
+		EdgeLatency:    edgeLatency,
+	}, nil
+}
+
+// Processor aggregates the latencies of the pathway checkpoints into time
+// buckets, and periodically flushes them to the agent.
+type Processor struct {
+	// in receives the stats points to aggregate.
+	in chan statsPoint
+
+	// mu guards the buckets
+	mu sync.Mutex
+	// buckets maps the start time of the buckets, in nanoseconds, to their
+	// aggregated stats groups.
+	buckets map[int64]map[statsGroupKey]*rawStatsGroup
+
+	// stopped reports whether the processor is stopped (when non-zero)
+	stopped uint32
+
+	wg        sync.WaitGroup // waits for any active goroutines
+	stop      chan struct{}  // closing this channel triggers shutdown
+	service   string
+	env       string
+	transport transport
+}
+
+// NewProcessor returns a new processor of the checkpoints of the given service
+// and env, sending the data streams stats to the agent at the given URL using
+// the given HTTP client.
+func NewProcessor(service, env, agentURL string, client *http.Client) *Processor {
+	return newProcessor(service, env, newHTTPTransport(agentURL, client))
+}
+
+func newProcessor(service, env string, t transport) *Processor {
+	return &Processor{
+		in:        make(chan statsPoint, 10000),
+		buckets:   make(map[int64]map[statsGroupKey]*rawStatsGroup),
+		stopped:   1,
+		service:   service,
+		env:       env,
+		transport: t,
+	}
+}
+
+// Start starts the processor and sets it as the global processor used by the
+// pathway checkpoints. A started processor needs to be stopped in order to
+// gracefully shut down, using Stop.
+func (p *Processor) Start() {
+	if atomic.SwapUint32(&p.stopped, 0) == 0 {
+		// already running
+		log.Warn("(*datastreams.Processor).Start called more than once. This is likely a programming error.")
+		return
+	}
+	p.stop = make(chan struct{})
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		tick := time.NewTicker(bucketDuration)
+		defer tick.Stop()
+		p.run(tick.C)
+	}()
+	setGlobalProcessor(p)
+}
+
+// run runs the loop aggregating the stats points and flushing the buckets.
+func (p *Processor) run(tick <-chan time.Time) {
+	for {
+		select {
+		case s := <-p.in:
+			p.aggregate(s)
+		case now := <-tick:
+			p.flush(now, withoutCurrentBucket)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the processor, flushes all the buckets and blocks until the
+// operation completes.
+func (p *Processor) Stop() {
+	if atomic.SwapUint32(&p.stopped, 1) > 0 {
+		return
+	}
+	setGlobalProcessor(nil)
+	close(p.stop)
+	p.wg.Wait()
+drain:
+	for {
+		select {
+		case s := <-p.in:
+			p.aggregate(s)
+		default:
+			break drain
+		}
+	}
+	p.flush(time.Now(), withCurrentBucket)
+}
+
+// add adds the stats point to the processor, dropping it when the processor is
+// overwhelmed.
+func (p *Processor) add(s statsPoint) {
+	select {
+	case p.in <- s:
+	default:
+		log.Debug("datastreams: dropping a stats point: the processor queue is full")
+	}
+}
+
+// aggregate adds the stats point into its stats bucket.
+func (p *Processor) aggregate(s statsPoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	size := bucketDuration.Nanoseconds()
+	btime := s.timestamp - s.timestamp%size
+	b, ok := p.buckets[btime]
+	if !ok {
+		b = make(map[statsGroupKey]*rawStatsGroup)
+		p.buckets[btime] = b
+	}
+	k := statsGroupKey{hash: s.hash, parentHash: s.parentHash}
+	g, ok := b[k]
+	if !ok {
+		g = newRawStatsGroup(s.edgeTags)
+		b[k] = g
+	}
+	// Latencies are recorded in seconds
+	if err := g.pathwayLatency.Add(float64(s.pathwayLatency) / float64(time.Second)); err != nil {
+		log.Debug("datastreams: could not add the pathway latency: %v", err)
+	}
+	if err := g.edgeLatency.Add(float64(s.edgeLatency) / float64(time.Second)); err != nil {
+		log.Debug("datastreams: could not add the edge latency: %v", err)
+	}
+}
+
+const (
+	withCurrentBucket    = true
+	withoutCurrentBucket = false
+)
+
+// flush flushes the stats buckets older than the given time, and sends them to
+// the agent. The current bucket is only included if includeCurrent is true,
+// such as during shutdown.
+func (p *Processor) flush(timenow time.Time, includeCurrent bool) {
+	sp := func() statsPayload {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		now := timenow.UnixNano()
+		size := bucketDuration.Nanoseconds()
+		sp := statsPayload{
+			Env:           p.env,
+			Service:       p.service,
+			TracerVersion: version.Tag,
+			Lang:          "go",
+		}
+		for ts, groups := range p.buckets {
+			if !includeCurrent && ts > now-size {
+				// do not flush the current bucket
+				continue
+			}
+			b := statsBucket{
+				Start:    uint64(ts),
+				Duration: uint64(size),
+				Stats:    make([]statsGroup, 0, len(groups)),
+			}
+			for k, g := range groups {
+				s, err := g.export(p.service, k)
+				if err != nil {
+					log.Error("datastreams: could not export stats group: %v", err)
+					continue
+				}
+				b.Stats = append(b.Stats, s)
+			}
+			sp.Stats = append(sp.Stats, b)
+			delete(p.buckets, ts)
+		}
+		return sp
+	}()
+
+	if len(sp.Stats) == 0 {
+		// nothing to flush
+		return
+	}
+	if err := p.transport.sendPipelineStats(&sp); err != nil {
+		log.Error("datastreams: error sending pipeline stats payload: %v", err)
+	}
+}
+
+var (
+	globalProcessorMu sync.RWMutex
+	globalProcessor   *Processor
+)
+
+func setGlobalProcessor(p *Processor) {
+	globalProcessorMu.Lock()
+	defer globalProcessorMu.Unlock()
+	globalProcessor = p
+}
+
+// getGlobalProcessor returns the started processor, or nil when Data Streams
+// Monitoring is disabled.
+func getGlobalProcessor() *Processor {
+	globalProcessorMu.RLock()
+	defer globalProcessorMu.RUnlock()
+	return globalProcessor
+}
+
+// Enabled returns true when Data Streams Monitoring is enabled, meaning that a
+// processor is started.
+func Enabled() bool {
+	return getGlobalProcessor() != nil
+}
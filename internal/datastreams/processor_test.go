@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package datastreams
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/DataDog/sketches-go/ddsketch/pb/sketchpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+	"google.golang.org/protobuf/proto"
+)
+
+type fakeTransport struct {
+	mu       sync.Mutex
+	payloads []*statsPayload
+}
+
+func (t *fakeTransport) sendPipelineStats(p *statsPayload) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.payloads = append(t.payloads, p)
+	return nil
+}
+
+func decodeSketch(t *testing.T, data []byte) *ddsketch.DDSketch {
+	var pb sketchpb.DDSketch
+	require.NoError(t, proto.Unmarshal(data, &pb))
+	sketch, err := ddsketch.FromProto(&pb)
+	require.NoError(t, err)
+	return sketch
+}
+
+func TestProcessor(t *testing.T) {
+	transport := &fakeTransport{}
+	p := newProcessor("service", "env", transport)
+	size := bucketDuration.Nanoseconds()
+	tp1 := time.Now().Truncate(bucketDuration).UnixNano()
+	tp2 := tp1 + size
+
+	p.aggregate(statsPoint{edgeTags: []string{"type:kafka"}, hash: 2, parentHash: 1, timestamp: tp1, pathwayLatency: int64(5 * time.Second), edgeLatency: int64(time.Second)})
+	p.aggregate(statsPoint{edgeTags: []string{"type:kafka"}, hash: 2, parentHash: 1, timestamp: tp1 + 1, pathwayLatency: int64(5 * time.Second), edgeLatency: int64(time.Second)})
+	p.aggregate(statsPoint{edgeTags: []string{"type:kafka", "direction:in"}, hash: 3, parentHash: 2, timestamp: tp1, pathwayLatency: int64(2 * time.Second), edgeLatency: int64(time.Second)})
+	p.aggregate(statsPoint{edgeTags: []string{"type:kafka"}, hash: 2, parentHash: 1, timestamp: tp2, pathwayLatency: int64(time.Second), edgeLatency: int64(time.Second)})
+
+	// The current bucket is not flushed
+	p.flush(time.Unix(0, tp2), withoutCurrentBucket)
+	require.Len(t, transport.payloads, 1)
+	payload := transport.payloads[0]
+	assert.Equal(t, "service", payload.Service)
+	assert.Equal(t, "env", payload.Env)
+	assert.Equal(t, "go", payload.Lang)
+	require.Len(t, payload.Stats, 1)
+	bucket := payload.Stats[0]
+	assert.Equal(t, uint64(tp1), bucket.Start)
+	assert.Equal(t, uint64(size), bucket.Duration)
+	require.Len(t, bucket.Stats, 2)
+	sort.Slice(bucket.Stats, func(i, j int) bool { return bucket.Stats[i].Hash < bucket.Stats[j].Hash })
+
+	group := bucket.Stats[0]
+	assert.Equal(t, "service", group.Service)
+	assert.Equal(t, []string{"type:kafka"}, group.EdgeTags)
+	assert.Equal(t, uint64(2), group.Hash)
+	assert.Equal(t, uint64(1), group.ParentHash)
+	pathwayLatency := decodeSketch(t, group.PathwayLatency)
+	assert.Equal(t, 2.0, pathwayLatency.GetCount())
+	assert.InEpsilon(t, 10.0, pathwayLatency.GetSum(), 0.1)
+	edgeLatency := decodeSketch(t, group.EdgeLatency)
+	assert.InEpsilon(t, 2.0, edgeLatency.GetSum(), 0.1)
+	assert.Equal(t, uint64(3), bucket.Stats[1].Hash)
+
+	// All the remaining buckets are flushed when the current one is included
+	p.flush(time.Unix(0, tp2), withCurrentBucket)
+	require.Len(t, transport.payloads, 2)
+	require.Len(t, transport.payloads[1].Stats, 1)
+	assert.Equal(t, uint64(tp2), transport.payloads[1].Stats[0].Start)
+
+	// Nothing is sent when there's nothing to flush
+	p.flush(time.Unix(0, tp2), withCurrentBucket)
+	require.Len(t, transport.payloads, 2)
+}
+
+func TestProcessorStartStop(t *testing.T) {
+	transport := &fakeTransport{}
+	p := newProcessor("service", "env", transport)
+	require.False(t, Enabled())
+	p.Start()
+	require.True(t, Enabled())
+
+	NewPathway("direction:out", "type:kafka")
+	p.Stop()
+	require.False(t, Enabled())
+
+	// The points are flushed when stopping the processor
+	require.Len(t, transport.payloads, 1)
+	require.Len(t, transport.payloads[0].Stats, 1)
+	require.Len(t, transport.payloads[0].Stats[0].Stats, 1)
+	assert.Equal(t, []string{"direction:out", "type:kafka"}, transport.payloads[0].Stats[0].Stats[0].EdgeTags)
+
+	// Checkpoints don't get recorded once stopped
+	NewPathway("direction:out", "type:kafka")
+	assert.Len(t, p.in, 0)
+}
+
+func TestHTTPTransport(t *testing.T) {
+	var got statsPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v0.1/pipeline_stats", r.URL.Path)
+		assert.Equal(t, "application/msgpack", r.Header.Get("Content-Type"))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		gzr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, msgp.Decode(gzr, &got))
+	}))
+	defer srv.Close()
+
+	transport := newHTTPTransport(srv.URL, nil)
+	payload := statsPayload{
+		Env:     "env",
+		Service: "service",
+		Stats: []statsBucket{{
+			Start:    1,
+			Duration: 2,
+			Stats:    []statsGroup{{Service: "service", EdgeTags: []string{"type:kafka"}, Hash: 3, ParentHash: 4}},
+		}},
+		Lang: "go",
+	}
+	require.NoError(t, transport.sendPipelineStats(&payload))
+	assert.Equal(t, payload, got)
+
+	errSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer errSrv.Close()
+	assert.Error(t, newHTTPTransport(errSrv.URL, nil).sendPipelineStats(&payload))
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package datastreams
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// PropagationKey is the key of the message header carrying the encoded
+// pathway.
+const PropagationKey = "dd-pathway-ctx"
+
+// errInvalidPathway is returned when decoding an invalid encoded pathway.
+var errInvalidPathway = errors.New("datastreams: invalid encoded pathway")
+
+// Encode encodes the pathway in order to propagate it along with a message.
+// The encoding is the pathway hash, followed by the varint-encoded pathway
+// start and edge start times, in milliseconds since epoch.
+func (p Pathway) Encode() []byte {
+	data := make([]byte, 8, 8+2*binary.MaxVarintLen64)
+	binary.LittleEndian.PutUint64(data, p.hash)
+	data = appendVarint(data, toMillis(p.pathwayStart))
+	data = appendVarint(data, toMillis(p.edgeStart))
+	return data
+}
+
+// EncodeToString encodes the pathway as a base64 string, for the message
+// headers only supporting strings.
+func (p Pathway) EncodeToString() string {
+	return base64.StdEncoding.EncodeToString(p.Encode())
+}
+
+// Decode decodes a pathway encoded with Encode.
+func Decode(data []byte) (p Pathway, err error) {
+	if len(data) < 8 {
+		return p, errInvalidPathway
+	}
+	hash := binary.LittleEndian.Uint64(data)
+	data = data[8:]
+	pathwayStart, n := binary.Varint(data)
+	if n <= 0 {
+		return p, errInvalidPathway
+	}
+	data = data[n:]
+	edgeStart, n := binary.Varint(data)
+	if n <= 0 {
+		return p, errInvalidPathway
+	}
+	return Pathway{
+		hash:         hash,
+		pathwayStart: fromMillis(pathwayStart),
+		edgeStart:    fromMillis(edgeStart),
+	}, nil
+}
+
+// TextMapReader is implemented by the message header carriers the pathway can
+// be extracted from. It matches tracer.TextMapReader.
+type TextMapReader interface {
+	// ForeachKey iterates over all the keys and values of the carrier.
+	ForeachKey(handler func(key, val string) error) error
+}
+
+// TextMapWriter is implemented by the message header carriers the pathway can
+// be injected into. It matches tracer.TextMapWriter.
+type TextMapWriter interface {
+	// Set sets the given key and value in the carrier.
+	Set(key, val string)
+}
+
+// Extract extracts the pathway from the PropagationKey header of the carrier,
+// as injected by Inject.
+func Extract(carrier TextMapReader) (p Pathway, ok bool) {
+	var data string
+	carrier.ForeachKey(func(key, val string) error {
+		if key == PropagationKey {
+			data = val
+		}
+		return nil
+	})
+	if data == "" {
+		return p, false
+	}
+	p, err := Decode([]byte(data))
+	return p, err == nil
+}
+
+// Inject injects the pathway into the PropagationKey header of the carrier.
+// The header value holds the binary encoding of the pathway, so Inject is
+// meant for the carriers supporting binary values, such as Kafka headers.
+func Inject(p Pathway, carrier TextMapWriter) {
+	carrier.Set(PropagationKey, string(p.Encode()))
+}
+
+// DecodeString decodes a pathway encoded with EncodeToString.
+func DecodeString(s string) (Pathway, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return Pathway{}, err
+	}
+	return Decode(data)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
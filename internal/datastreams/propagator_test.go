@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package datastreams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	p := Pathway{
+		hash:         234,
+		pathwayStart: time.Unix(0, 1665000000000*int64(time.Millisecond)),
+		edgeStart:    time.Unix(0, 1665000001000*int64(time.Millisecond)),
+	}
+
+	t.Run("bytes", func(t *testing.T) {
+		decoded, err := Decode(p.Encode())
+		require.NoError(t, err)
+		assert.Equal(t, p, decoded)
+	})
+
+	t.Run("string", func(t *testing.T) {
+		decoded, err := DecodeString(p.EncodeToString())
+		require.NoError(t, err)
+		assert.Equal(t, p, decoded)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, data := range [][]byte{nil, {1, 2, 3}, p.Encode()[:8], p.Encode()[:9]} {
+			_, err := Decode(data)
+			assert.Error(t, err)
+		}
+		_, err := DecodeString("not base64!")
+		assert.Error(t, err)
+	})
+}
+
+type mapCarrier map[string]string
+
+func (c mapCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, v := range c {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c mapCarrier) Set(key, val string) { c[key] = val }
+
+func TestInjectExtract(t *testing.T) {
+	p := Pathway{
+		hash:         234,
+		pathwayStart: time.Unix(0, 1665000000000*int64(time.Millisecond)),
+		edgeStart:    time.Unix(0, 1665000001000*int64(time.Millisecond)),
+	}
+	carrier := mapCarrier{"other": "value"}
+	Inject(p, carrier)
+	extracted, ok := Extract(carrier)
+	require.True(t, ok)
+	assert.Equal(t, p, extracted)
+
+	_, ok = Extract(mapCarrier{"other": "value"})
+	assert.False(t, ok)
+	_, ok = Extract(mapCarrier{PropagationKey: "invalid"})
+	assert.False(t, ok)
+}
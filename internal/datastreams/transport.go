@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2022 Datadog, Inc.
+
+package datastreams
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// transport sends the data streams stats payloads.
+type transport interface {
+	// sendPipelineStats sends the given stats payload to the agent.
+	sendPipelineStats(p *statsPayload) error
+}
+
+type httpTransport struct {
+	url     string            // the delivery URL for pipeline stats
+	client  *http.Client      // the HTTP client used in the POST
+	headers map[string]string // the transport headers
+}
+
+// newHTTPTransport returns a transport sending the data streams stats to the
+// agent at the given URL, using the given HTTP client.
+func newHTTPTransport(agentURL string, client *http.Client) *httpTransport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	headers := map[string]string{
+		"Datadog-Meta-Lang":             "go",
+		"Datadog-Meta-Lang-Version":     strings.TrimPrefix(runtime.Version(), "go"),
+		"Datadog-Meta-Lang-Interpreter": runtime.Compiler + "-" + runtime.GOARCH + "-" + runtime.GOOS,
+		"Datadog-Meta-Tracer-Version":   version.Tag,
+		"Content-Type":                  "application/msgpack",
+		"Content-Encoding":              "gzip",
+	}
+	if cid := internal.ContainerID(); cid != "" {
+		headers["Datadog-Container-ID"] = cid
+	}
+	return &httpTransport{
+		url:     fmt.Sprintf("%s/v0.1/pipeline_stats", agentURL),
+		client:  client,
+		headers: headers,
+	}
+}
+
+func (t *httpTransport) sendPipelineStats(p *statsPayload) error {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	if err := msgp.Encode(gzw, p); err != nil {
+		return err
+	}
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", t.url, &buf)
+	if err != nil {
+		return err
+	}
+	for header, value := range t.headers {
+		req.Header.Set(header, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if code := resp.StatusCode; code >= 400 {
+		// error, check the body for context information and
+		// return a nice error.
+		msg := make([]byte, 1000)
+		n, _ := resp.Body.Read(msg)
+		txt := http.StatusText(code)
+		if n > 0 {
+			return fmt.Errorf("%s (Status: %s)", msg[:n], txt)
+		}
+		return fmt.Errorf("%s", txt)
+	}
+	return nil
+}
@@ -131,6 +131,8 @@ type Client struct {
 	// metrics are sent
 	metrics    map[string]*metric
 	newMetrics bool
+	// distributions holds un-sent distribution metrics values
+	distributions map[string]*distribution
 }
 
 func (c *Client) log(msg string, args ...interface{}) {
@@ -157,6 +159,7 @@ func (c *Client) Start(integrations []Integration, configuration []Configuration
 
 	// XXX: Should we let metrics persist between starting and stopping?
 	c.metrics = make(map[string]*metric)
+	c.distributions = make(map[string]*distribution)
 
 	payload := &AppStarted{
 		Integrations:  append([]Integration{}, integrations...),
@@ -304,6 +307,43 @@ func (c *Client) Count(name string, value float64, tags []string, common bool) {
 	c.newMetrics = true
 }
 
+// maxDistributionValues is the maximum number of values kept per distribution
+// metric in between two flushes. Further values are dropped.
+const maxDistributionValues = 1024
+
+type distribution struct {
+	name   string
+	values []float64
+	tags   []string
+	common bool
+}
+
+// Distribution adds the value to a distribution with the given name and tags.
+// All the values are sent to the backend in order to compute the percentiles
+// of the distribution. If the metric is not language-specific, common should
+// be set to true
+func (c *Client) Distribution(name string, value float64, tags []string, common bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started {
+		return
+	}
+	key := metricKey(name, tags)
+	d, ok := c.distributions[key]
+	if !ok {
+		d = &distribution{
+			name:   name,
+			tags:   append([]string{}, tags...),
+			common: common,
+		}
+		c.distributions[key] = d
+	}
+	if len(d.values) >= maxDistributionValues {
+		return
+	}
+	d.values = append(d.values, value)
+}
+
 // flush sends any outstanding telemetry messages and aggregated metrics to be
 // sent to the backend. Requests are sent in the background. Should be called
 // with c.mu locked
@@ -330,6 +370,25 @@ func (c *Client) flush() {
 		r.Payload = payload
 		submissions = append(submissions, r)
 	}
+	if len(c.distributions) > 0 {
+		r := c.newRequest(RequestTypeDistributions)
+		payload := &DistributionMetrics{
+			Namespace:   c.Namespace,
+			LibLanguage: "golang",
+			LibVersion:  version.Tag,
+		}
+		for key, d := range c.distributions {
+			payload.Series = append(payload.Series, DistributionSeries{
+				Metric: d.name,
+				Points: d.values,
+				Tags:   d.tags,
+				Common: d.common,
+			})
+			delete(c.distributions, key)
+		}
+		r.Payload = payload
+		submissions = append(submissions, r)
+	}
 
 	// copy over requests so we can do the actual submission without holding
 	// the lock. Zero out the old stuff so we don't leak references
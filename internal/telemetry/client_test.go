@@ -130,6 +130,73 @@ func TestMetrics(t *testing.T) {
 	}
 }
 
+func TestDistributions(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []telemetry.DistributionSeries
+	)
+	closed := make(chan struct{}, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch telemetry.RequestType(r.Header.Get("DD-Telemetry-Request-Type")) {
+		case telemetry.RequestTypeAppClosing:
+			select {
+			case closed <- struct{}{}:
+			default:
+			}
+			return
+		case telemetry.RequestTypeDistributions:
+		default:
+			return
+		}
+		req := telemetry.Request{
+			Payload: new(telemetry.DistributionMetrics),
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		v, ok := req.Payload.(*telemetry.DistributionMetrics)
+		if !ok {
+			t.Fatal("payload set distributions but didn't get distributions")
+		}
+		if v.Namespace != telemetry.NamespaceASM {
+			t.Fatalf("unexpected namespace %s", v.Namespace)
+		}
+		mu.Lock()
+		got = append(got, v.Series...)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	go func() {
+		client := &telemetry.Client{
+			URL:       server.URL,
+			Namespace: telemetry.NamespaceASM,
+		}
+		client.Start(nil, nil)
+
+		// All the values should be kept
+		client.Distribution("foobar", 1, nil, true)
+		client.Distribution("foobar", 2, nil, true)
+		// Tags should be passed through
+		client.Distribution("bonk", 4, []string{"org:1"}, false)
+		client.Stop()
+	}()
+
+	<-closed
+
+	want := []telemetry.DistributionSeries{
+		{Metric: "bonk", Points: []float64{4}, Tags: []string{"org:1"}},
+		{Metric: "foobar", Points: []float64{1, 2}, Tags: []string{}, Common: true},
+	}
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].Metric < got[j].Metric
+	})
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+}
+
 func TestDisabledClient(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Fatal("shouldn't have got any requests")
@@ -32,6 +32,9 @@ const (
 	// RequestTypeGenerateMetrics contains all metrics accumulated by the
 	// client, and is sent periodically along with the heartbeat
 	RequestTypeGenerateMetrics RequestType = "generate-metrics"
+	// RequestTypeDistributions contains all distribution metrics accumulated
+	// by the client, and is sent periodically along with the heartbeat
+	RequestTypeDistributions RequestType = "distributions"
 	// RequestTypeAppClosing is sent when the telemetry client is stopped
 	RequestTypeAppClosing RequestType = "app-closing"
 )
@@ -144,5 +147,25 @@ type Series struct {
 	Common bool `json:"common"`
 }
 
+// DistributionMetrics corresponds to the "distributions" request type
+type DistributionMetrics struct {
+	Namespace   Namespace            `json:"namespace"`
+	LibLanguage string               `json:"lib_language"`
+	LibVersion  string               `json:"lib_version"`
+	Series      []DistributionSeries `json:"series"`
+}
+
+// DistributionSeries is a sequence of observations for a distribution metric.
+// Unlike Series, it contains all of the observed values instead of aggregated
+// timestamped points.
+type DistributionSeries struct {
+	Metric string    `json:"metric"`
+	Points []float64 `json:"points"`
+	Tags   []string  `json:"tags"`
+	// Common distinguishes metrics which are cross-language vs.
+	// language-specific.
+	Common bool `json:"common"`
+}
+
 // TODO: app-dependencies-loaded and app-integrations-change? Does this really
 // apply to Go?
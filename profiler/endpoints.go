@@ -0,0 +1,208 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pproflite"
+)
+
+// endpointLabel is the pprof label attached by the tracer to the samples
+// recorded while serving a request when endpoint collection is enabled, see
+// tracer.WithProfilerEndpoints.
+const endpointLabel = "trace endpoint"
+
+// endpointValues maps the sample types we aggregate per endpoint to the
+// endpointStats field they are accumulated in. Allocations are not included:
+// the runtime does not record pprof labels in heap profiles, so they can't be
+// attributed to endpoints.
+var endpointValues = []struct {
+	typ, unit string
+	field     func(*endpointStats) *int64
+}{
+	{"cpu", "nanoseconds", func(s *endpointStats) *int64 { return &s.CPUNanoseconds }},
+}
+
+// endpointStats holds the resources consumed on behalf of a single endpoint
+// during a profiling period.
+type endpointStats struct {
+	Endpoint       string `json:"endpoint"`
+	CPUNanoseconds int64  `json:"cpu_nanoseconds"`
+}
+
+// endpointAggregator sums up the values of profile samples per "trace
+// endpoint" label. It is safe for concurrent use since profiles of different
+// types are collected concurrently.
+type endpointAggregator struct {
+	mu    sync.Mutex
+	stats map[string]*endpointStats
+
+	// the fields below are reused across calls to add and guarded by mu.
+	dec     pproflite.Decoder
+	gzr     gzip.Reader
+	strings [][]byte
+}
+
+func newEndpointAggregator() *endpointAggregator {
+	return &endpointAggregator{stats: make(map[string]*endpointStats)}
+}
+
+// add aggregates the values of the given pprof profile, which may be gzip
+// compressed, into the stats of the current period. Profiles without the
+// supported sample types or without endpoint labels are ignored.
+func (a *endpointAggregator) add(data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if isGzipData(data) {
+		if err := a.gzr.Reset(bytes.NewReader(data)); err != nil {
+			return err
+		}
+		var err error
+		if data, err = io.ReadAll(&a.gzr); err != nil {
+			return fmt.Errorf("decompressing profile: %v", err)
+		}
+	}
+
+	// The string table usually comes last, so we need a first pass to
+	// resolve the label key and the sample types before looking at samples.
+	var sampleTypes []pproflite.ValueType
+	a.strings = a.strings[:0]
+	a.dec.Reset(data)
+	err := a.dec.FieldEach(func(f pproflite.Field) error {
+		switch t := f.(type) {
+		case *pproflite.SampleType:
+			sampleTypes = append(sampleTypes, t.ValueType)
+		case *pproflite.StringTable:
+			a.strings = append(a.strings, t.Value)
+		}
+		return nil
+	}, pproflite.SampleTypeDecoder, pproflite.StringTableDecoder)
+	if err != nil {
+		return err
+	}
+	str := func(i int64) string {
+		if i < 0 || int(i) >= len(a.strings) {
+			return ""
+		}
+		return string(a.strings[i])
+	}
+
+	// fields maps value indexes of the samples to the stats they are
+	// accumulated into, nil entries are ignored.
+	fields := make([]func(*endpointStats) *int64, len(sampleTypes))
+	var found bool
+	for i, st := range sampleTypes {
+		for _, v := range endpointValues {
+			if str(st.Type) == v.typ && str(st.Unit) == v.unit {
+				fields[i] = v.field
+				found = true
+			}
+		}
+	}
+	key := int64(-1)
+	for i, s := range a.strings {
+		if string(s) == endpointLabel {
+			key = int64(i)
+			break
+		}
+	}
+	if !found || key < 0 {
+		return nil
+	}
+
+	totals := make(map[int64][]int64)
+	err = a.dec.FieldEach(func(f pproflite.Field) error {
+		s := f.(*pproflite.Sample)
+		for _, l := range s.Label {
+			if l.Key != key {
+				continue
+			}
+			sum, ok := totals[l.Str]
+			if !ok {
+				sum = make([]int64, len(fields))
+				totals[l.Str] = sum
+			}
+			for i, v := range s.Value {
+				if i < len(sum) {
+					sum[i] += v
+				}
+			}
+			break
+		}
+		return nil
+	}, pproflite.SampleDecoder)
+	if err != nil {
+		return err
+	}
+
+	for idx, sum := range totals {
+		endpoint := str(idx)
+		stats, ok := a.stats[endpoint]
+		if !ok {
+			stats = &endpointStats{Endpoint: endpoint}
+			a.stats[endpoint] = stats
+		}
+		for i, field := range fields {
+			if field != nil {
+				*field(stats) += sum[i]
+			}
+		}
+	}
+	return nil
+}
+
+// flush returns the stats aggregated since the last call to flush, sorted by
+// decreasing CPU time, and resets the aggregator.
+func (a *endpointAggregator) flush() []endpointStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	res := make([]endpointStats, 0, len(a.stats))
+	for endpoint, s := range a.stats {
+		res = append(res, *s)
+		delete(a.stats, endpoint)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CPUNanoseconds != res[j].CPUNanoseconds {
+			return res[i].CPUNanoseconds > res[j].CPUNanoseconds
+		}
+		return res[i].Endpoint < res[j].Endpoint
+	})
+	return res
+}
+
+// endpointsProfile flushes the endpoint aggregator of p and returns its
+// content as a JSON attachment. If the configured statsd client supports it,
+// the values are also reported as distributions tagged with the endpoint.
+func (p *profiler) endpointsProfile() (*profile, error) {
+	stats := p.endpoints.flush()
+	if p.redactor != nil {
+		if p.redactor.rules.dropLabel(endpointLabel) {
+			stats = stats[:0]
+		}
+		for i := range stats {
+			stats[i].Endpoint = p.redactor.rules.labelValue(stats[i].Endpoint)
+		}
+	}
+	if d, ok := p.cfg.statsd.(statsdDistribution); ok {
+		for _, s := range stats {
+			tags := append(p.cfg.tags.Slice(), "endpoint:"+s.Endpoint)
+			_ = d.Distribution("datadog.profiling.go.endpoint.cpu_time", float64(s.CPUNanoseconds), tags, 1)
+		}
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	return &profile{name: "endpoints.json", data: data}, nil
+}
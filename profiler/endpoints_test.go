@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// labelledProfile returns a gzipped pprof profile with a single sample per
+// entry of values, labelled with the "trace endpoint" label matching the map
+// key unless it's empty.
+func labelledProfile(t *testing.T, sampleType, unit string, values map[string]int64) []byte {
+	t.Helper()
+	fn := &pprofile.Function{ID: 1, Name: "main.handler"}
+	loc := &pprofile.Location{ID: 1, Line: []pprofile.Line{{Function: fn}}}
+	p := &pprofile.Profile{
+		SampleType: []*pprofile.ValueType{{Type: sampleType, Unit: unit}},
+		Function:   []*pprofile.Function{fn},
+		Location:   []*pprofile.Location{loc},
+	}
+	for endpoint, v := range values {
+		s := &pprofile.Sample{Location: []*pprofile.Location{loc}, Value: []int64{v}}
+		if endpoint != "" {
+			s.Label = map[string][]string{endpointLabel: {endpoint}, "span id": {"1234"}}
+		}
+		p.Sample = append(p.Sample, s)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	return buf.Bytes()
+}
+
+func TestEndpointAggregator(t *testing.T) {
+	a := newEndpointAggregator()
+	require.NoError(t, a.add(labelledProfile(t, "cpu", "nanoseconds", map[string]int64{
+		"GET /users": 30,
+		"GET /items": 50,
+		"":           1000,
+	})))
+	require.NoError(t, a.add(labelledProfile(t, "cpu", "nanoseconds", map[string]int64{
+		"GET /users": 40,
+	})))
+	// unsupported sample types are ignored
+	require.NoError(t, a.add(labelledProfile(t, "alloc_space", "bytes", map[string]int64{
+		"GET /items": 512,
+	})))
+	require.NoError(t, a.add(labelledProfile(t, "contentions", "count", map[string]int64{
+		"GET /items": 3,
+	})))
+
+	assert.Equal(t, []endpointStats{
+		{Endpoint: "GET /users", CPUNanoseconds: 70},
+		{Endpoint: "GET /items", CPUNanoseconds: 50},
+	}, a.flush())
+	assert.Empty(t, a.flush())
+}
+
+func TestEndpointsProfile(t *testing.T) {
+	p, err := unstartedProfiler(
+		CPUDuration(time.Millisecond),
+		WithPeriod(time.Millisecond),
+		WithEndpointAggregation(true),
+	)
+	require.NoError(t, err)
+	p.testHooks.startCPUProfile = func(w io.Writer) error {
+		_, err := w.Write(labelledProfile(t, "cpu", "nanoseconds", map[string]int64{"GET /": 42}))
+		return err
+	}
+	p.testHooks.stopCPUProfile = func() {}
+	_, err = p.runProfile(CPUProfile)
+	require.NoError(t, err)
+
+	prof, err := p.endpointsProfile()
+	require.NoError(t, err)
+	assert.Equal(t, "endpoints.json", prof.name)
+	var stats []endpointStats
+	require.NoError(t, json.Unmarshal(prof.data, &stats))
+	assert.Equal(t, []endpointStats{{Endpoint: "GET /", CPUNanoseconds: 42}}, stats)
+}
@@ -0,0 +1,230 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/gostackparse"
+	pprofile "github.com/google/pprof/profile"
+)
+
+// leakMinPeriods is the number of consecutive profiling periods during which
+// the goroutine count or the wait duration of a stack must grow before the
+// stack is reported as a leak suspect. A growing wait duration alone is not
+// enough: long-lived idle goroutines such as worker pools, accept loops or
+// tickers wait longer every period, so the goroutine count must have grown
+// as well.
+const leakMinPeriods = 3
+
+// Leak reasons used as the value of the "leak suspect" pprof label.
+const (
+	leakReasonCount = "count_growth"
+	leakReasonWait  = "wait_growth"
+)
+
+// goroutineLeakDetector tracks groups of goroutines sharing the same stack
+// across profiling periods in order to identify stacks that look like they
+// are leaking goroutines. It is not safe for concurrent use, but the profiler
+// only ever collects a given profile type from a single goroutine.
+type goroutineLeakDetector struct {
+	stacks map[string]*leakStack
+}
+
+// leakStack holds the state of a group of goroutines sharing the same stack.
+type leakStack struct {
+	frames []*gostackparse.Frame
+	// count is the number of goroutines observed with this stack during the
+	// last period.
+	count int
+	// wait is the longest wait duration observed among these goroutines
+	// during the last period.
+	wait time.Duration
+	// countGrowth is the number of periods during which count has been
+	// increasing since it last decreased, and waitGrowth the number of
+	// consecutive periods during which wait has been increasing.
+	countGrowth int
+	waitGrowth  int
+}
+
+// reason returns why s is a leak suspect, or an empty string if it isn't.
+func (s *leakStack) reason() string {
+	switch {
+	case s.countGrowth >= leakMinPeriods:
+		return leakReasonCount
+	case s.waitGrowth >= leakMinPeriods && s.countGrowth > 0:
+		return leakReasonWait
+	default:
+		return ""
+	}
+}
+
+func newGoroutineLeakDetector() *goroutineLeakDetector {
+	return &goroutineLeakDetector{stacks: make(map[string]*leakStack)}
+}
+
+// profile reads a goroutine dump (debug=2 format) from r, updates the detector
+// state and writes the current leak suspects to w as a pprof profile. It
+// returns the number of suspect stacks.
+func (d *goroutineLeakDetector) profile(r io.Reader, w io.Writer, t time.Time) (n int, err error) {
+	// See goroutineDebug2ToPprof, we really want to avoid crashing customer
+	// applications because of a parsing bug.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	suspects, errs := d.update(r)
+	return len(suspects), leakSuspectsToPprof(suspects, errs, w, t)
+}
+
+// update parses the goroutine dump (debug=2 format) read from r, merges it
+// into the state tracked from the previous periods and returns the stacks that
+// are currently leak suspects, sorted by decreasing goroutine count. Stacks
+// which are not present in the dump anymore are forgotten.
+func (d *goroutineLeakDetector) update(r io.Reader) (suspects []*leakStack, errs []error) {
+	goroutines, errs := gostackparse.Parse(r)
+
+	type group struct {
+		frames []*gostackparse.Frame
+		count  int
+		wait   time.Duration
+	}
+	groups := make(map[string]*group)
+	for _, g := range goroutines {
+		frames := g.Stack
+		if g.CreatedBy != nil {
+			frames = append(frames, g.CreatedBy)
+		}
+		key := leakStackKey(frames)
+		grp, ok := groups[key]
+		if !ok {
+			grp = &group{frames: frames}
+			groups[key] = grp
+		}
+		grp.count++
+		if g.Wait > grp.wait {
+			grp.wait = g.Wait
+		}
+	}
+
+	for key := range d.stacks {
+		if _, ok := groups[key]; !ok {
+			delete(d.stacks, key)
+		}
+	}
+	for key, grp := range groups {
+		s, ok := d.stacks[key]
+		if !ok {
+			d.stacks[key] = &leakStack{frames: grp.frames, count: grp.count, wait: grp.wait}
+			continue
+		}
+		switch {
+		case grp.count > s.count:
+			s.countGrowth++
+		case grp.count < s.count:
+			s.countGrowth = 0
+		}
+		if grp.wait > s.wait {
+			s.waitGrowth++
+		} else {
+			s.waitGrowth = 0
+		}
+		s.count, s.wait = grp.count, grp.wait
+		if s.reason() != "" {
+			suspects = append(suspects, s)
+		}
+	}
+	sort.Slice(suspects, func(i, j int) bool {
+		if suspects[i].count != suspects[j].count {
+			return suspects[i].count > suspects[j].count
+		}
+		return leakStackKey(suspects[i].frames) < leakStackKey(suspects[j].frames)
+	})
+	return suspects, errs
+}
+
+// leakStackKey returns a string uniquely identifying the given stack.
+func leakStackKey(frames []*gostackparse.Frame) string {
+	var b strings.Builder
+	for _, f := range frames {
+		b.WriteString(f.Func)
+		b.WriteByte(' ')
+		b.WriteString(f.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(f.Line))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// leakSuspectsToPprof writes the given leak suspects to w as a pprof profile.
+func leakSuspectsToPprof(suspects []*leakStack, errs []error, w io.Writer, t time.Time) error {
+	functionID := uint64(1)
+	locationID := uint64(1)
+
+	p := &pprofile.Profile{
+		TimeNanos: t.UnixNano(),
+	}
+	m := &pprofile.Mapping{ID: 1, HasFunctions: true}
+	p.Mapping = []*pprofile.Mapping{m}
+	p.SampleType = []*pprofile.ValueType{
+		{Type: "goroutines", Unit: "count"},
+		{Type: "waitduration", Unit: "nanoseconds"},
+	}
+
+	for _, s := range suspects {
+		growth := s.countGrowth
+		if s.reason() == leakReasonWait {
+			growth = s.waitGrowth
+		}
+		sample := &pprofile.Sample{
+			Value: []int64{int64(s.count), s.wait.Nanoseconds()},
+			Label: map[string][]string{
+				"leak suspect": {s.reason()},
+			},
+			NumLabel: map[string][]int64{"growth periods": {int64(growth)}},
+		}
+		for _, call := range s.frames {
+			function := &pprofile.Function{
+				ID:       functionID,
+				Name:     call.Func,
+				Filename: call.File,
+			}
+			p.Function = append(p.Function, function)
+			functionID++
+
+			location := &pprofile.Location{
+				ID:      locationID,
+				Mapping: m,
+				Line: []pprofile.Line{{
+					Function: function,
+					Line:     int64(call.Line),
+				}},
+			}
+			p.Location = append(p.Location, location)
+			locationID++
+
+			sample.Location = append(sample.Location, location)
+		}
+		p.Sample = append(p.Sample, sample)
+	}
+	for _, err := range errs {
+		p.Comments = append(p.Comments, "error: "+err.Error())
+	}
+
+	if err := p.CheckValid(); err != nil {
+		return fmt.Errorf("marshalGoroutineLeakProfile: %s", err)
+	} else if err := p.Write(w); err != nil {
+		return fmt.Errorf("marshalGoroutineLeakProfile: %s", err)
+	}
+	return nil
+}
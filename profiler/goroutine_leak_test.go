@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+// goroutineDump returns a goroutine dump in debug=2 format containing n
+// goroutines blocked in main.leaky since waitMinutes and one goroutine
+// running main.main.
+func goroutineDump(n, waitMinutes int) string {
+	var b strings.Builder
+	b.WriteString("goroutine 1 [running]:\nmain.main()\n\t/example/main.go:10 +0x3d2\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "goroutine %d [chan receive, %d minutes]:\n", i+2, waitMinutes)
+		b.WriteString("main.leaky()\n\t/example/main.go:20 +0x31\n")
+		b.WriteString("created by main.main\n\t/example/main.go:12 +0x35\n\n")
+	}
+	return b.String()
+}
+
+func TestGoroutineLeakDetector(t *testing.T) {
+	t.Run("count", func(t *testing.T) {
+		d := newGoroutineLeakDetector()
+		for i := 1; i <= leakMinPeriods; i++ {
+			suspects, errs := d.update(strings.NewReader(goroutineDump(i, 0)))
+			require.Empty(t, errs)
+			require.Empty(t, suspects)
+		}
+		suspects, _ := d.update(strings.NewReader(goroutineDump(leakMinPeriods+1, 0)))
+		require.Len(t, suspects, 1)
+		require.Equal(t, leakReasonCount, suspects[0].reason())
+		require.Equal(t, leakMinPeriods+1, suspects[0].count)
+		require.Equal(t, "main.leaky", suspects[0].frames[0].Func)
+		require.Equal(t, "main.main", suspects[0].frames[1].Func)
+
+		// a stable count keeps the stack suspect, a decreasing one doesn't
+		suspects, _ = d.update(strings.NewReader(goroutineDump(leakMinPeriods+1, 0)))
+		require.Len(t, suspects, 1)
+		suspects, _ = d.update(strings.NewReader(goroutineDump(1, 0)))
+		require.Empty(t, suspects)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		d := newGoroutineLeakDetector()
+		for i := 0; i < leakMinPeriods; i++ {
+			// the count only grows once
+			suspects, _ := d.update(strings.NewReader(goroutineDump(1+i/2, i)))
+			require.Empty(t, suspects)
+		}
+		suspects, _ := d.update(strings.NewReader(goroutineDump(2, leakMinPeriods)))
+		require.Len(t, suspects, 1)
+		require.Equal(t, leakReasonWait, suspects[0].reason())
+		require.Equal(t, time.Duration(leakMinPeriods)*time.Minute, suspects[0].wait)
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		// long-lived goroutines wait longer every period without leaking
+		d := newGoroutineLeakDetector()
+		for i := 0; i < 2*leakMinPeriods; i++ {
+			suspects, _ := d.update(strings.NewReader(goroutineDump(4, i)))
+			require.Empty(t, suspects)
+		}
+	})
+
+	t.Run("forget", func(t *testing.T) {
+		d := newGoroutineLeakDetector()
+		d.update(strings.NewReader(goroutineDump(1, 0)))
+		require.Len(t, d.stacks, 2)
+		d.update(strings.NewReader(goroutineDump(0, 0)))
+		require.Len(t, d.stacks, 1)
+	})
+
+	t.Run("crash-safety", func(t *testing.T) {
+		d := newGoroutineLeakDetector()
+		_, err := d.profile(panicReader{}, io.Discard, time.Time{})
+		require.EqualError(t, err, "panic: 42")
+	})
+}
+
+func TestGoroutineLeakProfile(t *testing.T) {
+	var dumps []string
+	for i := 1; i <= leakMinPeriods+1; i++ {
+		dumps = append(dumps, goroutineDump(i, i))
+	}
+	p, err := unstartedProfiler(WithPeriod(time.Millisecond))
+	require.NoError(t, err)
+	p.testHooks.lookupProfile = func(_ string, w io.Writer, _ int) error {
+		_, err := w.Write([]byte(dumps[0]))
+		dumps = dumps[1:]
+		return err
+	}
+
+	var profs []*profile
+	for len(dumps) > 0 {
+		profs, err = p.runProfile(expGoroutineLeakProfile)
+		require.NoError(t, err)
+	}
+	require.Equal(t, "goroutinesleak.pprof", profs[0].name)
+
+	pp, err := pprofile.Parse(bytes.NewReader(profs[0].data))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(pp.SampleType))
+	require.Equal(t, 1, len(pp.Sample))
+	require.Equal(t, []int64{int64(leakMinPeriods + 1), (time.Duration(leakMinPeriods+1) * time.Minute).Nanoseconds()}, pp.Sample[0].Value)
+	require.Equal(t, []string{leakReasonCount}, pp.Sample[0].Label["leak suspect"])
+	require.Equal(t, []int64{leakMinPeriods}, pp.Sample[0].NumLabel["growth periods"])
+	require.Equal(t, "main.leaky", pp.Sample[0].Location[0].Line[0].Function.Name)
+}
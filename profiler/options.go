@@ -100,13 +100,24 @@ type config struct {
 	cpuDuration       time.Duration
 	cpuProfileRate    int
 	uploadTimeout     time.Duration
+	maxUploadSize     int
 	maxGoroutinesWait int
+	maxGoroutinesLeak int
 	mutexFraction     int
 	blockRate         int
 	outputDir         string
 	deltaProfiles     bool
 	deltaMethod       string
 	logStartup        bool
+	// endpointAggregation enables the per endpoint aggregation of CPU time,
+	// see WithEndpointAggregation.
+	endpointAggregation bool
+	// redaction holds the rules used to scrub pprof profiles before upload,
+	// see WithRedaction.
+	redaction *Redaction
+	// remoteConfig allows the backend to change the profiler settings at
+	// runtime, see WithRemoteConfig.
+	remoteConfig bool
 }
 
 // logStartup records the configuration to the configured logger in JSON format
@@ -133,7 +144,11 @@ func logStartup(c *config) {
 		BlockProfileRate     int      `json:"block_profile_rate"`
 		MutexProfileFraction int      `json:"mutex_profile_fraction"`
 		MaxGoroutinesWait    int      `json:"max_goroutines_wait"`
+		MaxGoroutinesLeak    int      `json:"max_goroutines_leak"`
 		UploadTimeout        string   `json:"upload_timeout"`
+		MaxUploadSize        int      `json:"max_upload_size"`
+		EndpointAggregation  bool     `json:"endpoint_aggregation"`
+		RemoteConfig         bool     `json:"remote_config"`
 	}{
 		Date:                 time.Now().Format(time.RFC3339),
 		OSName:               osinfo.OSName(),
@@ -155,7 +170,11 @@ func logStartup(c *config) {
 		BlockProfileRate:     c.blockRate,
 		MutexProfileFraction: c.mutexFraction,
 		MaxGoroutinesWait:    c.maxGoroutinesWait,
+		MaxGoroutinesLeak:    c.maxGoroutinesLeak,
 		UploadTimeout:        c.uploadTimeout.String(),
+		MaxUploadSize:        c.maxUploadSize,
+		EndpointAggregation:  c.endpointAggregation,
+		RemoteConfig:         c.remoteConfig,
 	}
 	for t := range c.types {
 		info.EnabledProfiles = append(info.EnabledProfiles, t.String())
@@ -196,19 +215,22 @@ func (c *config) addProfileType(t ProfileType) {
 
 func defaultConfig() (*config, error) {
 	c := config{
-		apiURL:            defaultAPIURL,
-		service:           filepath.Base(os.Args[0]),
-		statsd:            &statsd.NoOpClient{},
-		httpClient:        defaultClient,
-		period:            DefaultPeriod,
-		cpuDuration:       DefaultDuration,
-		blockRate:         DefaultBlockRate,
-		mutexFraction:     DefaultMutexFraction,
-		uploadTimeout:     DefaultUploadTimeout,
-		maxGoroutinesWait: 1000, // arbitrary value, should limit STW to ~30ms
-		deltaProfiles:     internal.BoolEnv("DD_PROFILING_DELTA", true),
-		deltaMethod:       os.Getenv("DD_PROFILING_DELTA_METHOD"),
-		logStartup:        internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true),
+		apiURL:              defaultAPIURL,
+		service:             filepath.Base(os.Args[0]),
+		statsd:              &statsd.NoOpClient{},
+		httpClient:          defaultClient,
+		period:              DefaultPeriod,
+		cpuDuration:         DefaultDuration,
+		blockRate:           DefaultBlockRate,
+		mutexFraction:       DefaultMutexFraction,
+		uploadTimeout:       DefaultUploadTimeout,
+		maxGoroutinesWait:   1000, // arbitrary value, should limit STW to ~30ms
+		maxGoroutinesLeak:   1000, // same as maxGoroutinesWait, both use debug=2 dumps
+		deltaProfiles:       internal.BoolEnv("DD_PROFILING_DELTA", true),
+		deltaMethod:         os.Getenv("DD_PROFILING_DELTA_METHOD"),
+		logStartup:          internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true),
+		endpointAggregation: internal.BoolEnv("DD_PROFILING_ENDPOINT_AGGREGATION_ENABLED", false),
+		remoteConfig:        internal.BoolEnv("DD_PROFILING_REMOTE_CONFIG_ENABLED", false),
 	}
 	c.tags = c.tags.Append(fmt.Sprintf("process_id:%d", os.Getpid()))
 	for _, t := range defaultProfileTypes {
@@ -237,6 +259,13 @@ func defaultConfig() (*config, error) {
 		}
 		WithUploadTimeout(d)(&c)
 	}
+	if v := os.Getenv("DD_PROFILING_MAX_UPLOAD_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("DD_PROFILING_MAX_UPLOAD_SIZE: %s", err)
+		}
+		WithMaxUploadSize(n)(&c)
+	}
 	if v := os.Getenv("DD_API_KEY"); v != "" {
 		WithAPIKey(v)(&c)
 	}
@@ -292,6 +321,13 @@ func defaultConfig() (*config, error) {
 		}
 		c.maxGoroutinesWait = n
 	}
+	if v := os.Getenv("DD_PROFILING_GOROUTINE_LEAK_PROFILE_MAX_GOROUTINES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("DD_PROFILING_GOROUTINE_LEAK_PROFILE_MAX_GOROUTINES: %s", err)
+		}
+		c.maxGoroutinesLeak = n
+	}
 	return &c, nil
 }
 
@@ -456,6 +492,19 @@ func WithUploadTimeout(d time.Duration) Option {
 	}
 }
 
+// WithMaxUploadSize specifies the maximum size, in bytes, of the profiles
+// uploaded at the end of each profiling period. If the profiles collected
+// during a period exceed this size, the largest ones are dropped until the
+// remaining ones fit, which is reported by the
+// datadog.profiling.go.profile_dropped metric. The default value of 0 means
+// that there is no limit. This option takes precedence over the
+// DD_PROFILING_MAX_UPLOAD_SIZE env variable.
+func WithMaxUploadSize(bytes int) Option {
+	return func(cfg *config) {
+		cfg.maxUploadSize = bytes
+	}
+}
+
 // WithSite specifies the datadog site (datadoghq.com, datadoghq.eu, etc.)
 // which profiles will be sent to.
 func WithSite(site string) Option {
@@ -515,3 +564,40 @@ func WithHostname(hostname string) Option {
 		cfg.hostname = hostname
 	}
 }
+
+// WithEndpointAggregation enables the aggregation of CPU time per endpoint,
+// based on the "trace endpoint" pprof label attached by the tracer when
+// tracer.WithProfilerEndpoints is enabled. The aggregated values are uploaded
+// along with the profiles and, if the client given to WithStatsd supports
+// distributions, reported as the datadog.profiling.go.endpoint.cpu_time metric
+// tagged by endpoint. Allocations are not aggregated since the Go runtime does
+// not record pprof labels in heap profiles. This option is disabled by default
+// and takes precedence over the DD_PROFILING_ENDPOINT_AGGREGATION_ENABLED env
+// variable.
+func WithEndpointAggregation(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.endpointAggregation = enabled
+	}
+}
+
+// WithRemoteConfig allows the Datadog backend to pause and resume profiling,
+// enable or disable profile types and change the block and mutex profiling
+// rates of the running profiler through the remote configuration feature of
+// the Datadog Agent, see Update and Pause. This option is disabled by default
+// and takes precedence over the DD_PROFILING_REMOTE_CONFIG_ENABLED env
+// variable. It has no effect in agentless mode.
+func WithRemoteConfig(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.remoteConfig = enabled
+	}
+}
+
+// WithRedaction scrubs the function names, paths and pprof labels of all the
+// pprof profiles, including delta profiles, before they are uploaded or
+// written to disk, according to the given rules. Redaction happens after
+// profiles have been collected and adds to the CPU overhead of the profiler.
+func WithRedaction(rules Redaction) Option {
+	return func(cfg *config) {
+		cfg.redaction = &rules
+	}
+}
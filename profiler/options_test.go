@@ -247,6 +247,33 @@ func TestOptions(t *testing.T) {
 		WithHostname("example")(&cfg)
 		assert.Equal(t, "example", cfg.hostname)
 	})
+
+	t.Run("WithMaxUploadSize", func(t *testing.T) {
+		var cfg config
+		WithMaxUploadSize(1024)(&cfg)
+		assert.Equal(t, 1024, cfg.maxUploadSize)
+	})
+
+	t.Run("WithRedaction", func(t *testing.T) {
+		var cfg config
+		WithRedaction(Redaction{PathPrefixes: []string{"/src/"}})(&cfg)
+		require.NotNil(t, cfg.redaction)
+		assert.Equal(t, []string{"/src/"}, cfg.redaction.PathPrefixes)
+	})
+
+	t.Run("WithEndpointAggregation", func(t *testing.T) {
+		var cfg config
+		WithEndpointAggregation(true)(&cfg)
+		assert.Equal(t, true, cfg.endpointAggregation)
+		WithEndpointAggregation(false)(&cfg)
+		assert.Equal(t, false, cfg.endpointAggregation)
+	})
+
+	t.Run("WithRemoteConfig", func(t *testing.T) {
+		var cfg config
+		WithRemoteConfig(true)(&cfg)
+		assert.Equal(t, true, cfg.remoteConfig)
+	})
 }
 
 func TestEnvVars(t *testing.T) {
@@ -271,6 +298,13 @@ func TestEnvVars(t *testing.T) {
 		assert.Equal(t, 3*time.Second, cfg.uploadTimeout)
 	})
 
+	t.Run("DD_PROFILING_MAX_UPLOAD_SIZE", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_MAX_UPLOAD_SIZE", "1024")
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		assert.Equal(t, 1024, cfg.maxUploadSize)
+	})
+
 	t.Run("DD_AGENT_HOST+DD_TRACE_AGENT_PORT", func(t *testing.T) {
 		t.Setenv("DD_AGENT_HOST", "agent_host_1")
 		t.Setenv("DD_TRACE_AGENT_PORT", "6218")
@@ -330,6 +364,20 @@ func TestEnvVars(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, cfg.deltaProfiles, false)
 	})
+
+	t.Run("DD_PROFILING_ENDPOINT_AGGREGATION_ENABLED", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_ENDPOINT_AGGREGATION_ENABLED", "true")
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		assert.Equal(t, cfg.endpointAggregation, true)
+	})
+
+	t.Run("DD_PROFILING_REMOTE_CONFIG_ENABLED", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_REMOTE_CONFIG_ENABLED", "true")
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		assert.Equal(t, cfg.remoteConfig, true)
+	})
 }
 
 func TestDefaultConfig(t *testing.T) {
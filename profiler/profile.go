@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/DataDog/gostackparse"
@@ -50,6 +51,13 @@ const (
 	expGoroutineWaitProfile
 	// MetricsProfile reports top-line metrics associated with user-specified profiles
 	MetricsProfile
+	// expGoroutineLeakProfile reports stack traces shared by goroutines whose
+	// number keeps growing across profiling periods, or whose wait duration
+	// keeps growing while their number grows, which usually indicates a
+	// goroutine leak. This feature is currently
+	// experimental and only available within DD by setting the
+	// DD_PROFILING_GOROUTINE_LEAK_PROFILE env variable.
+	expGoroutineLeakProfile
 )
 
 // profileType holds the implementation details of a ProfileType.
@@ -103,6 +111,11 @@ var profileTypes = map[ProfileType]profileType{
 			// the other profile types
 			p.pendingProfiles.Wait()
 			p.stopCPUProfile()
+			if p.endpoints != nil {
+				if err := p.endpoints.add(buf.Bytes()); err != nil {
+					log.Warn("Failed to aggregate CPU time per endpoint: %v", err)
+				}
+			}
 			return buf.Bytes(), nil
 		},
 	},
@@ -165,6 +178,35 @@ var profileTypes = map[ProfileType]profileType{
 			return pprof.Bytes(), err
 		},
 	},
+	expGoroutineLeakProfile: {
+		Name:     "goroutineleak",
+		Filename: "goroutinesleak.pprof",
+		Collect: func(p *profiler) ([]byte, error) {
+			if n := runtime.NumGoroutine(); n > p.cfg.maxGoroutinesLeak {
+				return nil, fmt.Errorf("skipping goroutines leak profile: %d goroutines exceeds DD_PROFILING_GOROUTINE_LEAK_PROFILE_MAX_GOROUTINES limit of %d", n, p.cfg.maxGoroutinesLeak)
+			}
+
+			p.interruptibleSleep(p.cfg.period)
+
+			var (
+				now   = now()
+				text  = &bytes.Buffer{}
+				pprof = &bytes.Buffer{}
+			)
+			if err := p.lookupProfile("goroutine", text, 2); err != nil {
+				return nil, err
+			}
+			n, err := p.leaks.profile(text, pprof, now)
+			if err != nil {
+				return nil, err
+			}
+			if g, ok := p.cfg.statsd.(statsdGauge); ok {
+				tags := append(p.cfg.tags.Slice(), expGoroutineLeakProfile.Tag())
+				_ = g.Gauge("datadog.profiling.go.goroutine_leak.suspects", float64(n), tags, 1)
+			}
+			return pprof.Bytes(), nil
+		},
+	},
 	MetricsProfile: {
 		Name:     "metrics",
 		Filename: "metrics.json",
@@ -252,6 +294,15 @@ func (b *batch) addProfile(p *profile) {
 	b.profiles = append(b.profiles, p)
 }
 
+// size returns the total size of the profiles in b, in bytes.
+func (b *batch) size() int {
+	var n int
+	for _, p := range b.profiles {
+		n += len(p.data)
+	}
+	return n
+}
+
 func (p *profiler) runProfile(pt ProfileType) ([]*profile, error) {
 	start := now()
 	t := pt.lookup()
@@ -259,6 +310,11 @@ func (p *profiler) runProfile(pt ProfileType) ([]*profile, error) {
 	if err != nil {
 		return nil, err
 	}
+	if p.redactor != nil && strings.HasSuffix(t.Filename, ".pprof") {
+		if data, err = p.redactor.Redact(data); err != nil {
+			return nil, fmt.Errorf("redaction error: %v", err)
+		}
+	}
 	end := now()
 	tags := append(p.cfg.tags.Slice(), pt.Tag())
 	filename := t.Filename
@@ -465,6 +521,13 @@ type statsdDistribution interface {
 	Distribution(event string, value float64, tags []string, rate float64) error
 }
 
+// statsdGauge is another extension of the public profiler.StatsdClient
+// interface, see statsdDistribution.
+type statsdGauge interface {
+	// Gauge measures the value of a metric at a particular time.
+	Gauge(name string, value float64, tags []string, rate float64) error
+}
+
 func (cdp *comparingDeltaProfiler) reportTiming(section string, dur time.Duration) {
 	statsdClient, ok := cdp.statsd.(statsdDistribution)
 	if !ok {
@@ -19,6 +19,7 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 )
 
@@ -70,10 +71,27 @@ type profiler struct {
 	wg              sync.WaitGroup    // wg waits for all goroutines to exit when stopping.
 	met             *metrics          // metric collector state
 	deltas          map[ProfileType]deltaProfiler
+	leaks           *goroutineLeakDetector // state of the goroutine leak profile
+	endpoints       *endpointAggregator    // per endpoint stats; nil unless enabled
+	redactor        *redactor              // scrubs uploaded profiles; nil unless enabled
 	telemetry       *telemetry.Client
 	seq             uint64         // seq is the value of the profile_seq tag
+	retained        []batch        // batches that failed to upload; only accessed by send
 	pendingProfiles sync.WaitGroup // signal that profile collection is done, for stopping CPU profiling
 
+	// settingsMu guards the fields below, as well as the settings of cfg
+	// changed by applySettings, see settings.go.
+	settingsMu sync.Mutex
+	pending    *settings     // settings to apply at the next period; nil if unchanged
+	paused     bool          // paused is true if collection must be paused
+	active     bool          // active is false while collection is paused
+	resumed    chan struct{} // resumed wakes up collect when paused is unset
+	user       settings      // the settings given to Start and Update, without the remote config overrides
+	userPaused bool          // userPaused is true if paused by Pause
+	rcOpts     []Option      // rcOpts are the settings overridden by remote config
+	rcPaused   bool          // rcPaused is true if paused by remote config
+	rc         *remoteconfig.Client
+
 	testHooks testHooks
 }
 
@@ -124,6 +142,10 @@ func newProfiler(opts ...Option) (*profiler, error) {
 	if os.Getenv("DD_PROFILING_WAIT_PROFILE") != "" {
 		cfg.addProfileType(expGoroutineWaitProfile)
 	}
+	// TODO(fg) remove this after making expGoroutineLeakProfile public.
+	if os.Getenv("DD_PROFILING_GOROUTINE_LEAK_PROFILE") != "" {
+		cfg.addProfileType(expGoroutineLeakProfile)
+	}
 	// Agentless upload is disabled by default as of v1.30.0, but
 	// WithAgentlessUpload can be used to enable it for testing and debugging.
 	if cfg.agentless {
@@ -184,12 +206,23 @@ func newProfiler(opts ...Option) (*profiler, error) {
 		exit:   make(chan struct{}),
 		met:    newMetrics(),
 		deltas: make(map[ProfileType]deltaProfiler),
+		leaks:  newGoroutineLeakDetector(),
+
+		active:  true,
+		resumed: make(chan struct{}, 1),
+		user:    cfg.settings(),
 	}
 	for pt := range cfg.types {
 		if d := profileTypes[pt].DeltaValues; len(d) > 0 {
 			p.deltas[pt] = newDeltaProfiler(p.cfg, d...)
 		}
 	}
+	if cfg.endpointAggregation {
+		p.endpoints = newEndpointAggregator()
+	}
+	if cfg.redaction != nil {
+		p.redactor = newRedactor(*cfg.redaction)
+	}
 	p.uploadFunc = p.upload
 	p.telemetry = &telemetry.Client{
 		APIKey:    cfg.apiKey,
@@ -259,9 +292,20 @@ func (p *profiler) run() {
 			{Name: "mutex_profile_enabled", Value: profileEnabled(MutexProfile)},
 			{Name: "goroutine_profile_enabled", Value: profileEnabled(GoroutineProfile)},
 			{Name: "goroutine_wait_profile_enabled", Value: profileEnabled(expGoroutineWaitProfile)},
+			{Name: "goroutine_leak_profile_enabled", Value: profileEnabled(expGoroutineLeakProfile)},
+			{Name: "max_goroutines_leak", Value: p.cfg.maxGoroutinesLeak},
 			{Name: "upload_timeout", Value: p.cfg.uploadTimeout.String()},
+			{Name: "max_upload_size", Value: p.cfg.maxUploadSize},
+			{Name: "endpoint_aggregation_enabled", Value: p.cfg.endpointAggregation},
+			{Name: "redaction_enabled", Value: p.cfg.redaction != nil},
+			{Name: "remote_config_enabled", Value: p.cfg.remoteConfig},
 		},
 	)
+	if p.cfg.remoteConfig && !p.cfg.agentless {
+		if err := p.startRC(); err != nil {
+			log.Warn("profiler: Remote config: disabled due to an initialization error: %v", err)
+		}
+	}
 
 	if profileEnabled(MutexProfile) {
 		runtime.SetMutexProfileFraction(p.cfg.mutexFraction)
@@ -275,7 +319,7 @@ func (p *profiler) run() {
 		tick := time.NewTicker(p.cfg.period)
 		defer tick.Stop()
 		p.met.reset(now()) // collect baseline metrics at profiler start
-		p.collect(tick.C)
+		p.collect(tick)
 	}()
 	p.wg.Add(1)
 	go func() {
@@ -286,7 +330,7 @@ func (p *profiler) run() {
 
 // collect runs the profile types found in the configuration whenever the ticker receives
 // an item.
-func (p *profiler) collect(ticker <-chan time.Time) {
+func (p *profiler) collect(ticker *time.Ticker) {
 	defer close(p.out)
 	var (
 		// mu guards completed
@@ -295,6 +339,12 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 		wg        sync.WaitGroup
 	)
 	for {
+		if p.applySettings(ticker) {
+			if !p.waitResumed() {
+				return
+			}
+			continue
+		}
 		now := now()
 		bat := batch{
 			seq:   p.seq,
@@ -342,9 +392,16 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 		for _, prof := range completed {
 			bat.addProfile(prof)
 		}
+		if p.endpoints != nil {
+			if prof, err := p.endpointsProfile(); err != nil {
+				log.Error("Error getting endpoints profile: %v; skipping.", err)
+			} else {
+				bat.addProfile(prof)
+			}
+		}
 		p.enqueueUpload(bat)
 		select {
-		case <-ticker:
+		case <-ticker.C:
 		case <-p.exit:
 			return
 		}
@@ -364,6 +421,7 @@ func (p *profiler) enabledProfileTypes() []ProfileType {
 		MutexProfile,
 		GoroutineProfile,
 		expGoroutineWaitProfile,
+		expGoroutineLeakProfile,
 		MetricsProfile,
 	}
 	enabled := []ProfileType{}
@@ -385,9 +443,10 @@ func (p *profiler) enqueueUpload(bat batch) {
 		default:
 			// queue is full; evict oldest
 			select {
-			case <-p.out:
+			case old := <-p.out:
 				p.cfg.statsd.Count("datadog.profiling.go.queue_full", 1, p.cfg.tags.Slice(), 1)
 				log.Warn("Evicting one profile batch from the upload queue to make room.")
+				p.dropBatch(old, dropReasonQueueFull)
 			default:
 				// this case should be almost impossible to trigger, it would require a
 				// full p.out to completely drain within nanoseconds or extreme
@@ -402,12 +461,16 @@ func (p *profiler) send() {
 	for {
 		select {
 		case <-p.exit:
+			p.dropRetained()
 			return
 		case bat := <-p.out:
 			if err := p.outputDir(bat); err != nil {
 				log.Error("Failed to output profile to dir: %v", err)
 			}
-			if err := p.uploadFunc(bat); err != nil {
+			if err := p.uploadFunc(bat); err == errProfilerStopped {
+				p.dropRetained()
+				return
+			} else if err != nil {
 				log.Error("Failed to upload profile: %v", err)
 			}
 		}
@@ -449,6 +512,9 @@ func (p *profiler) interruptibleSleep(d time.Duration) {
 func (p *profiler) stop() {
 	p.stopOnce.Do(func() {
 		close(p.exit)
+		if p.rc != nil {
+			p.rc.Stop()
+		}
 		p.telemetry.Stop()
 	})
 	p.wg.Wait()
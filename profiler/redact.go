@@ -0,0 +1,257 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pproflite"
+)
+
+// redactedValue replaces the values of pprof labels matching
+// Redaction.LabelValues.
+const redactedValue = "<redacted>"
+
+// Redaction describes how the strings contained in pprof profiles are
+// scrubbed before being uploaded. See WithRedaction.
+type Redaction struct {
+	// PathPrefixes are trimmed from the beginning of source file and
+	// binary paths. The first matching prefix is used.
+	PathPrefixes []string
+	// LabelKeys matches the keys of pprof labels which are removed from
+	// profile samples altogether.
+	LabelKeys *regexp.Regexp
+	// LabelValues matches the values of pprof labels which are replaced with
+	// "<redacted>".
+	LabelValues *regexp.Regexp
+	// Rewrite is an optional function called with every function name and
+	// path, after PathPrefixes have been trimmed. It returns the string to
+	// upload instead. Returning an empty string drops the value.
+	Rewrite func(s string) string
+}
+
+func (r *Redaction) path(s string) string {
+	for _, prefix := range r.PathPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			s = strings.TrimPrefix(s, prefix)
+			break
+		}
+	}
+	return r.function(s)
+}
+
+func (r *Redaction) function(s string) string {
+	if r.Rewrite != nil {
+		return r.Rewrite(s)
+	}
+	return s
+}
+
+// dropLabel reports whether the label with the given key must be removed.
+func (r *Redaction) dropLabel(key string) bool {
+	return r.LabelKeys != nil && r.LabelKeys.MatchString(key)
+}
+
+func (r *Redaction) labelValue(s string) string {
+	if r.LabelValues != nil && r.LabelValues.MatchString(s) {
+		return redactedValue
+	}
+	return s
+}
+
+// redactor applies a Redaction to pprof profiles using pproflite. It is safe
+// for concurrent use.
+type redactor struct {
+	rules Redaction
+
+	// the fields below are reused across calls to Redact and guarded by mu.
+	mu      sync.Mutex
+	dec     pproflite.Decoder
+	enc     pproflite.Encoder
+	gzr     gzip.Reader
+	strings []string
+}
+
+func newRedactor(rules Redaction) *redactor {
+	return &redactor{rules: rules}
+}
+
+// Redact returns a copy of the given pprof profile, which may be gzip
+// compressed, with its strings scrubbed according to the redaction rules. The
+// returned profile is gzip compressed.
+//
+// Strings are never removed from the string table since samples, locations
+// and functions reference them by index. Instead, rewritten strings are
+// appended to the table and the original entries which are not referenced
+// anymore are replaced with empty strings.
+func (r *redactor) Redact(data []byte) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if isGzipData(data) {
+		if err := r.gzr.Reset(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		var err error
+		if data, err = io.ReadAll(&r.gzr); err != nil {
+			return nil, fmt.Errorf("decompressing profile: %v", err)
+		}
+	}
+
+	// First pass: load the string table.
+	r.strings = r.strings[:0]
+	r.dec.Reset(data)
+	err := r.dec.FieldEach(func(f pproflite.Field) error {
+		r.strings = append(r.strings, string(f.(*pproflite.StringTable).Value))
+		return nil
+	}, pproflite.StringTableDecoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		n       = int64(len(r.strings))
+		index   = make(map[string]int64, n)
+		used    = make([]bool, n)
+		appends []string
+		// the maps below cache the index of the rewritten strings per
+		// kind, since the same string may be used as e.g. a label value
+		// and a function name.
+		paths     = make(map[int64]int64)
+		functions = make(map[int64]int64)
+		values    = make(map[int64]int64)
+	)
+	for i, s := range r.strings {
+		if _, ok := index[s]; !ok {
+			index[s] = int64(i)
+		}
+	}
+	str := func(i int64) string {
+		if i < 0 || i >= n {
+			return ""
+		}
+		return r.strings[i]
+	}
+	// remap returns the index of rewrite(str(i)), adding it to the string
+	// table if needed, and marks it as used.
+	remap := func(cache map[int64]int64, i int64, rewrite func(string) string) int64 {
+		j, ok := cache[i]
+		if !ok {
+			j = i
+			if s := str(i); i != 0 {
+				if rs := rewrite(s); rs != s {
+					if k, ok := index[rs]; ok {
+						j = k
+					} else {
+						j = n + int64(len(appends))
+						index[rs] = j
+						appends = append(appends, rs)
+					}
+				}
+			}
+			cache[i] = j
+		}
+		if j < n {
+			used[j] = true
+		}
+		return j
+	}
+	keep := func(i int64) {
+		if i >= 0 && i < n {
+			used[i] = true
+		}
+	}
+
+	// Second pass: rewrite every field referencing strings and encode the
+	// result. The string table is not decoded and gets written at the end,
+	// once we know which strings are still used.
+	var out bytes.Buffer
+	gzw := gzip.NewWriter(&out)
+	r.enc.Reset(gzw)
+	err = r.dec.FieldEach(func(f pproflite.Field) error {
+		switch t := f.(type) {
+		case *pproflite.SampleType:
+			keep(t.Type)
+			keep(t.Unit)
+		case *pproflite.PeriodType:
+			keep(t.Type)
+			keep(t.Unit)
+		case *pproflite.Sample:
+			labels := t.Label[:0]
+			for _, l := range t.Label {
+				if r.rules.dropLabel(str(l.Key)) {
+					continue
+				}
+				keep(l.Key)
+				keep(l.NumUnit)
+				if l.Str != 0 {
+					l.Str = remap(values, l.Str, r.rules.labelValue)
+				}
+				labels = append(labels, l)
+			}
+			t.Label = labels
+		case *pproflite.Mapping:
+			t.Filename = remap(paths, t.Filename, r.rules.path)
+			keep(t.BuildID)
+		case *pproflite.Function:
+			t.Name = remap(functions, t.Name, r.rules.function)
+			t.SystemName = remap(functions, t.SystemName, r.rules.function)
+			t.FileName = remap(paths, t.FileName, r.rules.path)
+		case *pproflite.DropFrames:
+			keep(t.Value)
+		case *pproflite.KeepFrames:
+			keep(t.Value)
+		case *pproflite.Comment:
+			keep(t.Value)
+		case *pproflite.DefaultSampleType:
+			keep(t.Value)
+		}
+		return r.enc.Encode(f)
+	},
+		pproflite.SampleTypeDecoder,
+		pproflite.SampleDecoder,
+		pproflite.MappingDecoder,
+		pproflite.LocationFastDecoder,
+		pproflite.FunctionDecoder,
+		pproflite.DropFramesDecoder,
+		pproflite.KeepFramesDecoder,
+		pproflite.TimeNanosDecoder,
+		pproflite.DurationNanosDecoder,
+		pproflite.PeriodTypeDecoder,
+		pproflite.PeriodDecoder,
+		pproflite.CommentDecoder,
+		pproflite.DefaultSampleTypeDecoder,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var st pproflite.StringTable
+	for i, s := range r.strings {
+		if i != 0 && !used[i] {
+			s = ""
+		}
+		st.Value = []byte(s)
+		if err := r.enc.Encode(&st); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range appends {
+		st.Value = []byte(s)
+		if err := r.enc.Encode(&st); err != nil {
+			return nil, err
+		}
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, fmt.Errorf("error flushing gzip writer: %v", err)
+	}
+	return out.Bytes(), nil
+}
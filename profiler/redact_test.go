@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redactionTestProfile returns a gzipped pprof profile referencing secrets
+// in its paths and labels.
+func redactionTestProfile(t *testing.T) []byte {
+	t.Helper()
+	m := &pprofile.Mapping{ID: 1, File: "/home/secret-customer/bin/app", HasFunctions: true}
+	handler := &pprofile.Function{ID: 1, Name: "secretcustomer.com/app.handler", Filename: "/home/secret-customer/src/app/handler.go"}
+	main := &pprofile.Function{ID: 2, Name: "main.main", Filename: "/home/secret-customer/src/app/main.go"}
+	loc1 := &pprofile.Location{ID: 1, Mapping: m, Line: []pprofile.Line{{Function: handler, Line: 12}}}
+	loc2 := &pprofile.Location{ID: 2, Mapping: m, Line: []pprofile.Line{{Function: main, Line: 3}}}
+	p := &pprofile.Profile{
+		SampleType: []*pprofile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Mapping:    []*pprofile.Mapping{m},
+		Function:   []*pprofile.Function{handler, main},
+		Location:   []*pprofile.Location{loc1, loc2},
+		Sample: []*pprofile.Sample{{
+			Location: []*pprofile.Location{loc1, loc2},
+			Value:    []int64{10},
+			Label: map[string][]string{
+				"customer":       {"secret-customer"},
+				"trace endpoint": {"GET /accounts/secret-customer"},
+				"region":         {"us-east-1"},
+			},
+		}},
+		TimeNanos: time.Now().UnixNano(),
+	}
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	return buf.Bytes()
+}
+
+func TestRedactor(t *testing.T) {
+	r := newRedactor(Redaction{
+		PathPrefixes: []string{"/home/secret-customer/"},
+		LabelKeys:    regexp.MustCompile("^customer$"),
+		LabelValues:  regexp.MustCompile("secret-customer"),
+		Rewrite: func(s string) string {
+			return strings.ReplaceAll(s, "secretcustomer.com", "example.com")
+		},
+	})
+	data, err := r.Redact(redactionTestProfile(t))
+	require.NoError(t, err)
+
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret-customer")
+	assert.NotContains(t, string(raw), "secretcustomer")
+
+	p, err := pprofile.ParseData(data)
+	require.NoError(t, err)
+	require.NoError(t, p.CheckValid())
+	assert.Equal(t, "bin/app", p.Mapping[0].File)
+	assert.Equal(t, "example.com/app.handler", p.Function[0].Name)
+	assert.Equal(t, "src/app/handler.go", p.Function[0].Filename)
+	assert.Equal(t, "main.main", p.Function[1].Name)
+	assert.Equal(t, "src/app/main.go", p.Function[1].Filename)
+	assert.Equal(t, map[string][]string{
+		"trace endpoint": {redactedValue},
+		"region":         {"us-east-1"},
+	}, p.Sample[0].Label)
+	assert.Equal(t, []int64{10}, p.Sample[0].Value)
+	assert.Equal(t, "cpu", p.SampleType[0].Type)
+}
+
+func TestRedactionProfiles(t *testing.T) {
+	p, err := unstartedProfiler(
+		WithPeriod(time.Millisecond),
+		WithEndpointAggregation(true),
+		WithRedaction(Redaction{LabelValues: regexp.MustCompile("secret-customer")}),
+	)
+	require.NoError(t, err)
+	p.testHooks.lookupProfile = func(_ string, w io.Writer, _ int) error {
+		_, err := w.Write(redactionTestProfile(t))
+		return err
+	}
+	require.NoError(t, p.endpoints.add(redactionTestProfile(t)))
+
+	profs, err := p.runProfile(GoroutineProfile)
+	require.NoError(t, err)
+	prof, err := pprofile.ParseData(profs[0].data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{redactedValue}, prof.Sample[0].Label["customer"])
+
+	endpoints, err := p.endpointsProfile()
+	require.NoError(t, err)
+	assert.NotContains(t, string(endpoints.data), "secret-customer")
+}
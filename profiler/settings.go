@@ -0,0 +1,359 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+)
+
+// rcProductProfiling is the remote configuration product used to adjust the
+// settings of running profilers, see WithRemoteConfig.
+const rcProductProfiling = "APM_PROFILING"
+
+var errNotStarted = errors.New("profiler: not started")
+
+// Update changes the settings of the running profiler without resetting the
+// state of delta profiles or the profile_seq tag, unlike calling Stop and
+// Start. Only the following options have an effect, others are ignored:
+// WithPeriod, CPUDuration, CPUProfileRate, MutexProfileFraction,
+// BlockProfileRate and WithProfileTypes. The new settings are applied at the
+// start of the next profiling period, except for the settings overridden by
+// remote configuration which take effect once it is removed. An error is
+// returned if the profiler isn't running or if the settings are invalid.
+func Update(opts ...Option) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if activeProfiler == nil {
+		return errNotStarted
+	}
+	return activeProfiler.update(opts...)
+}
+
+// Pause suspends profile collection until Resume is called. Profiles already
+// being collected are still uploaded, and the block and mutex profiling rates
+// are turned off while paused to remove their overhead. It returns an error if
+// the profiler isn't running.
+func Pause() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if activeProfiler == nil {
+		return errNotStarted
+	}
+	activeProfiler.setPaused(true)
+	return nil
+}
+
+// Resume resumes profile collection after a call to Pause, unless it is
+// paused by remote configuration. It returns an error if the profiler isn't
+// running.
+func Resume() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if activeProfiler == nil {
+		return errNotStarted
+	}
+	activeProfiler.setPaused(false)
+	return nil
+}
+
+// settings holds the subset of the configuration which can be changed while
+// the profiler is running.
+type settings struct {
+	types          map[ProfileType]struct{}
+	period         time.Duration
+	cpuDuration    time.Duration
+	cpuProfileRate int
+	mutexFraction  int
+	blockRate      int
+}
+
+func (c *config) settings() settings {
+	types := make(map[ProfileType]struct{}, len(c.types))
+	for t := range c.types {
+		types[t] = struct{}{}
+	}
+	return settings{
+		types:          types,
+		period:         c.period,
+		cpuDuration:    c.cpuDuration,
+		cpuProfileRate: c.cpuProfileRate,
+		mutexFraction:  c.mutexFraction,
+		blockRate:      c.blockRate,
+	}
+}
+
+func (c *config) setSettings(s settings) {
+	c.types = s.types
+	c.period = s.period
+	c.cpuDuration = s.cpuDuration
+	c.cpuProfileRate = s.cpuProfileRate
+	c.mutexFraction = s.mutexFraction
+	c.blockRate = s.blockRate
+}
+
+// update applies opts to the settings requested by the user, and schedules
+// them to be applied by collect along with the remote config overrides.
+func (p *profiler) update(opts ...Option) error {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	user, err := withOptions(p.user, opts...)
+	if err != nil {
+		return err
+	}
+	s, err := withOptions(user, p.rcOpts...)
+	if err != nil {
+		return err
+	}
+	p.user = user
+	p.pending = &s
+	return nil
+}
+
+// setRCOverrides replaces the settings overridden by remote config with opts,
+// and schedules the resulting settings to be applied by collect. A nil opts
+// restores the settings requested by the user.
+func (p *profiler) setRCOverrides(opts []Option) error {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	s, err := withOptions(p.user, opts...)
+	if err != nil {
+		return err
+	}
+	p.rcOpts = opts
+	p.pending = &s
+	return nil
+}
+
+// withOptions validates and returns the settings resulting from applying opts
+// to a copy of s.
+func withOptions(s settings, opts ...Option) (settings, error) {
+	var cfg config
+	cfg.setSettings(s)
+	// settings returns a copy, so that options can't modify s.
+	cfg.setSettings(cfg.settings())
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for pt := range cfg.types {
+		if _, ok := profileTypes[pt]; !ok {
+			return settings{}, fmt.Errorf("unknown profile type: %d", pt)
+		}
+	}
+	if cfg.period <= 0 {
+		return settings{}, fmt.Errorf("invalid period, must be > 0: %s", cfg.period)
+	}
+	if cfg.cpuDuration > cfg.period {
+		cfg.cpuDuration = cfg.period
+	}
+	return cfg.settings(), nil
+}
+
+// setPaused pauses or resumes profile collection on behalf of the user.
+// Collection stays paused while remote config pauses it.
+func (p *profiler) setPaused(paused bool) {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	p.userPaused = paused
+	p.updatePaused()
+}
+
+// setRCPaused pauses or resumes profile collection on behalf of remote
+// config. Collection stays paused while the user pauses it.
+func (p *profiler) setRCPaused(paused bool) {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	p.rcPaused = paused
+	p.updatePaused()
+}
+
+// updatePaused pauses collection if either the user or remote config paused
+// it, and resumes it otherwise. p.settingsMu must be held.
+func (p *profiler) updatePaused() {
+	paused := p.userPaused || p.rcPaused
+	if p.paused == paused {
+		return
+	}
+	p.paused = paused
+	if !paused {
+		select {
+		case p.resumed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// uploadPeriod returns the current profiling period. Unlike p.cfg.period it
+// is safe to call outside of the collect goroutine.
+func (p *profiler) uploadPeriod() time.Duration {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	return p.cfg.period
+}
+
+// applySettings applies the settings changed by update, if any, and returns
+// whether collection is paused. It must only be called by collect, between
+// two profiling periods.
+func (p *profiler) applySettings(tick *time.Ticker) (paused bool) {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	prev := p.cfg.settings()
+	changed := p.pending != nil
+	if changed {
+		p.cfg.setSettings(*p.pending)
+		p.pending = nil
+		for pt := range p.cfg.types {
+			if d := profileTypes[pt].DeltaValues; len(d) > 0 && p.deltas[pt] == nil {
+				p.deltas[pt] = newDeltaProfiler(p.cfg, d...)
+			}
+		}
+		if p.cfg.period != prev.period {
+			tick.Reset(p.cfg.period)
+		}
+	}
+	active := !p.paused
+	if changed || active != p.active {
+		p.setRuntimeRates(prev.types, p.active, active)
+	}
+	if active && !p.active {
+		// The metrics profile would otherwise cover the pause as well.
+		p.met.reset(now())
+	}
+	p.active = active
+	return p.paused
+}
+
+// setRuntimeRates updates the runtime mutex and block profiling rates after
+// the enabled profile types or the paused state changed. Rates are never
+// modified for profile types that were not and are not enabled, in order not
+// to interfere with applications setting them on their own.
+func (p *profiler) setRuntimeRates(prevTypes map[ProfileType]struct{}, wasActive, active bool) {
+	set := func(t ProfileType, rate int, setRate func(int)) {
+		_, was := prevTypes[t]
+		_, is := p.cfg.types[t]
+		switch {
+		case is && active:
+			setRate(rate)
+		case was && wasActive:
+			setRate(0)
+		}
+	}
+	set(MutexProfile, p.cfg.mutexFraction, func(rate int) { runtime.SetMutexProfileFraction(rate) })
+	set(BlockProfile, p.cfg.blockRate, runtime.SetBlockProfileRate)
+}
+
+// waitResumed blocks until profiling gets resumed or the profiler is stopped.
+// It returns false in the latter case.
+func (p *profiler) waitResumed() bool {
+	select {
+	case <-p.resumed:
+		return true
+	case <-p.exit:
+		return false
+	}
+}
+
+// rcSettings is the payload of the APM_PROFILING remote configuration
+// product. Omitted fields keep the settings requested by the user.
+type rcSettings struct {
+	Paused               *bool    `json:"paused"`
+	ProfileTypes         []string `json:"profile_types"`
+	BlockProfileRate     *int     `json:"block_profile_rate"`
+	MutexProfileFraction *int     `json:"mutex_profile_fraction"`
+}
+
+// startRC starts the remote configuration client of the profiler.
+func (p *profiler) startRC() error {
+	cfg := remoteconfig.DefaultClientConfig()
+	cfg.AgentURL = strings.TrimSuffix(p.cfg.agentURL, "/profiling/v1/input")
+	cfg.HTTP = p.cfg.httpClient
+	cfg.Env = p.cfg.env
+	cfg.ServiceName = p.cfg.service
+	cfg.Products = []string{rcProductProfiling}
+	client, err := remoteconfig.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	client.RegisterCallback(p.rcCallback, rcProductProfiling)
+	client.Start()
+	p.rc = client
+	return nil
+}
+
+// rcCallback applies the settings received through remote configuration on
+// top of the settings requested by the user. A nil configuration means it was
+// removed, in which case the settings requested by the user are restored.
+func (p *profiler) rcCallback(u remoteconfig.ProductUpdate) map[string]rc.ApplyStatus {
+	statuses := make(map[string]rc.ApplyStatus, len(u))
+	for path, raw := range u {
+		log.Debug("profiler: Remote config: processing %s", path)
+		if raw == nil {
+			p.setRCOverrides(nil)
+			p.setRCPaused(false)
+			statuses[path] = rc.ApplyStatus{State: rc.ApplyStateAcknowledged}
+			continue
+		}
+		if err := p.applyRCSettings(raw); err != nil {
+			log.Error("profiler: Remote config: error while processing %s: %v. Configuration won't be applied.", path, err)
+			statuses[path] = rc.ApplyStatus{State: rc.ApplyStateError, Error: err.Error()}
+			continue
+		}
+		statuses[path] = rc.ApplyStatus{State: rc.ApplyStateAcknowledged}
+	}
+	return statuses
+}
+
+func (p *profiler) applyRCSettings(raw []byte) error {
+	var s rcSettings
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	var opts []Option
+	if s.ProfileTypes != nil {
+		var types []ProfileType
+		for _, name := range s.ProfileTypes {
+			t, ok := profileTypeByName(name)
+			if !ok {
+				return fmt.Errorf("unknown profile type: %q", name)
+			}
+			types = append(types, t)
+		}
+		opts = append(opts, WithProfileTypes(types...))
+	}
+	if s.BlockProfileRate != nil {
+		opts = append(opts, func(cfg *config) { cfg.blockRate = *s.BlockProfileRate })
+	}
+	if s.MutexProfileFraction != nil {
+		opts = append(opts, func(cfg *config) { cfg.mutexFraction = *s.MutexProfileFraction })
+	}
+	if err := p.setRCOverrides(opts); err != nil {
+		return err
+	}
+	if s.Paused != nil {
+		p.setRCPaused(*s.Paused)
+	}
+	return nil
+}
+
+// profileTypeByName returns the profile type with the given name, as returned
+// by ProfileType.String.
+func profileTypeByName(name string) (ProfileType, bool) {
+	for t, pt := range profileTypes {
+		if pt.Name == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
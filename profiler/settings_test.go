@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package profiler
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+)
+
+// batchProfiler returns a running profiler sending its batches to the
+// returned channel.
+func batchProfiler(t *testing.T, opts ...Option) (*profiler, <-chan batch) {
+	t.Helper()
+	out := make(chan batch, 100)
+	opts = append([]Option{WithPeriod(10 * time.Millisecond), CPUDuration(time.Millisecond)}, opts...)
+	p, err := unstartedProfiler(opts...)
+	require.NoError(t, err)
+	p.uploadFunc = func(bat batch) error {
+		out <- bat
+		return nil
+	}
+	p.run()
+	t.Cleanup(p.stop)
+	return p, out
+}
+
+// waitForProfile returns the first batch received from out containing a
+// profile with the given name.
+func waitForProfile(t *testing.T, out <-chan batch, name string) batch {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case bat := <-out:
+			for _, prof := range bat.profiles {
+				if prof.name == name {
+					return bat
+				}
+			}
+		case <-timeout:
+			t.Fatalf("no %s profile received", name)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	start := runtime.SetMutexProfileFraction(0)
+	defer runtime.SetMutexProfileFraction(start)
+
+	p, out := batchProfiler(t, WithProfileTypes(HeapProfile))
+	first := waitForProfile(t, out, "delta-heap.pprof")
+	assert.Zero(t, runtime.SetMutexProfileFraction(-1))
+
+	require.NoError(t, p.update(WithProfileTypes(HeapProfile, MutexProfile), MutexProfileFraction(42)))
+	bat := waitForProfile(t, out, "delta-mutex.pprof")
+	assert.Greater(t, bat.seq, first.seq)
+	assert.Equal(t, 42, runtime.SetMutexProfileFraction(-1))
+
+	require.NoError(t, p.update(WithProfileTypes(HeapProfile)))
+	waitForProfile(t, out, "delta-heap.pprof")
+	waitForProfile(t, out, "delta-heap.pprof")
+	assert.Zero(t, runtime.SetMutexProfileFraction(-1))
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Error(t, p.update(WithPeriod(0)))
+		assert.Error(t, p.update(WithProfileTypes(ProfileType(-1))))
+	})
+}
+
+func TestPauseResume(t *testing.T) {
+	start := runtime.SetMutexProfileFraction(0)
+	defer runtime.SetMutexProfileFraction(start)
+
+	p, out := batchProfiler(t, WithProfileTypes(MutexProfile))
+	first := waitForProfile(t, out, "delta-mutex.pprof")
+	assert.Equal(t, DefaultMutexFraction, runtime.SetMutexProfileFraction(-1))
+
+	p.setPaused(true)
+	require.Eventually(t, func() bool {
+		p.settingsMu.Lock()
+		defer p.settingsMu.Unlock()
+		return !p.active
+	}, 5*time.Second, time.Millisecond)
+	assert.Zero(t, runtime.SetMutexProfileFraction(-1))
+	// batches collected before pausing may still be in flight
+	time.Sleep(50 * time.Millisecond)
+	var last uint64
+	for len(out) > 0 {
+		last = (<-out).seq
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, out)
+
+	p.setPaused(false)
+	bat := waitForProfile(t, out, "delta-mutex.pprof")
+	assert.Greater(t, bat.seq, first.seq)
+	assert.Greater(t, bat.seq, last)
+	assert.Equal(t, DefaultMutexFraction, runtime.SetMutexProfileFraction(-1))
+}
+
+func TestNotStarted(t *testing.T) {
+	Stop()
+	assert.Equal(t, errNotStarted, Update(WithPeriod(time.Second)))
+	assert.Equal(t, errNotStarted, Pause())
+	assert.Equal(t, errNotStarted, Resume())
+}
+
+func TestRemoteConfigCallback(t *testing.T) {
+	p, err := unstartedProfiler(WithProfileTypes(HeapProfile), BlockProfileRate(10))
+	require.NoError(t, err)
+
+	statuses := p.rcCallback(remoteconfig.ProductUpdate{
+		"config": []byte(`{"profile_types": ["heap", "block"], "block_profile_rate": 100, "paused": true}`),
+	})
+	assert.Equal(t, rc.ApplyStateAcknowledged, statuses["config"].State)
+	require.NotNil(t, p.pending)
+	assert.Equal(t, map[ProfileType]struct{}{HeapProfile: {}, BlockProfile: {}, MetricsProfile: {}}, p.pending.types)
+	assert.Equal(t, 100, p.pending.blockRate)
+	assert.True(t, p.paused)
+
+	// settings changed by the user are kept once the remote config is removed
+	require.NoError(t, p.update(BlockProfileRate(20), MutexProfileFraction(30)))
+	assert.Equal(t, map[ProfileType]struct{}{HeapProfile: {}, BlockProfile: {}, MetricsProfile: {}}, p.pending.types)
+	assert.Equal(t, 100, p.pending.blockRate)
+	assert.Equal(t, 30, p.pending.mutexFraction)
+
+	statuses = p.rcCallback(remoteconfig.ProductUpdate{"config": nil})
+	assert.Equal(t, rc.ApplyStateAcknowledged, statuses["config"].State)
+	assert.Equal(t, map[ProfileType]struct{}{HeapProfile: {}, BlockProfile: {}, MutexProfile: {}, MetricsProfile: {}}, p.pending.types)
+	assert.Equal(t, 20, p.pending.blockRate)
+	assert.Equal(t, 30, p.pending.mutexFraction)
+	assert.False(t, p.paused)
+
+	// the remote config doesn't resume a profiler paused by the user
+	p.setPaused(true)
+	p.rcCallback(remoteconfig.ProductUpdate{"config": []byte(`{"paused": false}`)})
+	assert.True(t, p.paused)
+	p.rcCallback(remoteconfig.ProductUpdate{"config": nil})
+	assert.True(t, p.paused)
+	p.setPaused(false)
+	assert.False(t, p.paused)
+
+	statuses = p.rcCallback(remoteconfig.ProductUpdate{
+		"bad-type": []byte(`{"profile_types": ["nope"]}`),
+		"bad-json": []byte(`{`),
+	})
+	assert.Equal(t, rc.ApplyStateError, statuses["bad-type"].State)
+	assert.Equal(t, rc.ApplyStateError, statuses["bad-json"].State)
+}
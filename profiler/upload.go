@@ -16,33 +16,112 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"sort"
 	"strings"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
 
-// maxRetries specifies the maximum number of retries to have when an error occurs.
-const maxRetries = 2
+const (
+	// maxRetries specifies the maximum number of retries to have when an error occurs.
+	maxRetries = 4
+
+	// uploadBackoffBase is the upper bound of the wait time before the first
+	// retry. It doubles for every subsequent retry, see (*profiler).backoff.
+	uploadBackoffBase = time.Second
+
+	// maxRetainedBatches is the maximum number of batches kept in memory after
+	// running out of retries, in order to survive short outages of the agent or
+	// intake. Retained batches are uploaded after the next successful upload.
+	maxRetainedBatches = 2
+)
+
+// Reasons for dropping profiles or batches, used as the "reason" tag of the
+// datadog.profiling.go.upload_dropped and datadog.profiling.go.profile_dropped
+// metrics.
+const (
+	dropReasonQueueFull     = "queue_full"
+	dropReasonRetentionFull = "retention_full"
+	dropReasonUploadError   = "upload_error"
+	dropReasonTooLarge      = "payload_too_large"
+	dropReasonStopped       = "profiler_stopped"
+)
 
 var errOldAgent = errors.New("Datadog Agent is not accepting profiles. Agent-based profiling deployments " +
 	"require Datadog Agent >= 7.20")
 
-// upload tries to upload a batch of profiles. It has retry and backoff mechanisms.
+// errProfilerStopped is returned when the profiler is stopped before a batch
+// could be uploaded.
+var errProfilerStopped = errors.New("profiler stopped before the upload completed")
+
+// upload tries to upload a batch of profiles. It has retry and backoff
+// mechanisms. Batches which can't be uploaded because of transient errors are
+// retained and uploaded after the next successful upload.
 func (p *profiler) upload(bat batch) error {
+	if !p.limitBatchSize(&bat) {
+		return fmt.Errorf("profile batch %d exceeds max upload size of %d bytes", bat.seq, p.cfg.maxUploadSize)
+	}
+	err := p.uploadWithRetries(bat)
+	if err == errProfilerStopped {
+		p.dropBatch(bat, dropReasonStopped)
+		return err
+	}
+	if _, ok := err.(*retriableError); ok {
+		p.retain(bat)
+		return err
+	} else if err != nil {
+		p.dropBatch(bat, dropReasonUploadError)
+		return err
+	}
+	// The agent or intake is reachable again, try to catch up with the
+	// batches that couldn't be uploaded during the outage.
+	for len(p.retained) > 0 {
+		select {
+		case <-p.exit:
+			return errProfilerStopped
+		default:
+		}
+		old := p.retained[0]
+		if err := p.doRequest(old); err != nil {
+			if _, ok := err.(*retriableError); !ok {
+				p.retained = p.retained[1:]
+				p.dropBatch(old, dropReasonUploadError)
+				continue
+			}
+			break
+		}
+		p.retained = p.retained[1:]
+		p.cfg.statsd.Count("datadog.profiling.go.upload_success", 1, nil, 1)
+	}
+	return nil
+}
+
+// uploadWithRetries uploads bat, retrying with exponential backoff on
+// transient errors. The total time spent waiting between retries is bounded
+// by the profiling period, so that a failing upload can't delay the upload of
+// the following batches indefinitely. It returns a *retriableError if the
+// retries have been exhausted, and errProfilerStopped if the profiler is
+// stopped in the meantime.
+func (p *profiler) uploadWithRetries(bat batch) error {
 	statsd := p.cfg.statsd
+	budget := p.uploadPeriod()
 	var err error
-	for i := 0; i < maxRetries; i++ {
+	for i := 0; i <= maxRetries; i++ {
 		select {
 		case <-p.exit:
-			return nil
+			return errProfilerStopped
 		default:
 		}
 
 		err = p.doRequest(bat)
 		if rerr, ok := err.(*retriableError); ok {
+			wait := p.backoff(i)
+			if i == maxRetries || wait > budget {
+				break
+			}
+			budget -= wait
 			statsd.Count("datadog.profiling.go.upload_retry", 1, nil, 1)
-			wait := time.Duration(rand.Int63n(p.cfg.period.Nanoseconds())) * time.Nanosecond
 			log.Error("Uploading profile failed: %v. Trying again in %s...", rerr, wait)
 			p.interruptibleSleep(wait)
 			continue
@@ -51,15 +130,93 @@ func (p *profiler) upload(bat batch) error {
 			statsd.Count("datadog.profiling.go.upload_error", 1, nil, 1)
 		} else {
 			statsd.Count("datadog.profiling.go.upload_success", 1, nil, 1)
-			var b int64
-			for _, p := range bat.profiles {
-				b += int64(len(p.data))
-			}
-			statsd.Count("datadog.profiling.go.uploaded_profile_bytes", b, nil, 1)
+			statsd.Count("datadog.profiling.go.uploaded_profile_bytes", int64(bat.size()), nil, 1)
 		}
 		return err
 	}
-	return fmt.Errorf("failed after %d retries, last error was: %v", maxRetries, err)
+	statsd.Count("datadog.profiling.go.upload_error", 1, nil, 1)
+	return &retriableError{fmt.Errorf("failed after %d retries, last error was: %v", maxRetries, err)}
+}
+
+// backoff returns a randomized wait duration before the given retry attempt,
+// starting at 0. The upper bound of the duration doubles with every attempt
+// and is capped by the profiling period.
+func (p *profiler) backoff(attempt int) time.Duration {
+	period := p.uploadPeriod()
+	max := uploadBackoffBase << uint(attempt)
+	if max > period || max <= 0 {
+		max = period
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)) + 1)
+}
+
+// retain keeps bat in memory so it can be uploaded later, evicting the oldest
+// retained batch if there is no room.
+func (p *profiler) retain(bat batch) {
+	if len(p.retained) >= maxRetainedBatches {
+		p.dropBatch(p.retained[0], dropReasonRetentionFull)
+		p.retained = p.retained[1:]
+	}
+	p.retained = append(p.retained, bat)
+}
+
+// dropRetained records the batches still retained when the profiler stops as
+// dropped.
+func (p *profiler) dropRetained() {
+	for _, bat := range p.retained {
+		p.dropBatch(bat, dropReasonStopped)
+	}
+	p.retained = nil
+}
+
+// dropBatch records that bat will never be uploaded for the given reason. The
+// profile_seq of the batch is logged to help understanding gaps in the
+// uploaded sequence numbers.
+func (p *profiler) dropBatch(bat batch, reason string) {
+	tags := append(p.cfg.tags.Slice(), "reason:"+reason)
+	p.cfg.statsd.Count("datadog.profiling.go.upload_dropped", 1, tags, 1)
+	log.Warn("Dropping profile batch with profile_seq:%d: %s", bat.seq, reason)
+}
+
+// limitBatchSize drops the largest profiles from bat until its size fits in
+// the configured max upload size. It returns false if no profile is left, in
+// which case the whole batch has been dropped.
+func (p *profiler) limitBatchSize(bat *batch) bool {
+	max := p.cfg.maxUploadSize
+	if max <= 0 || bat.size() <= max {
+		return true
+	}
+	profiles := append([]*profile(nil), bat.profiles...)
+	sort.SliceStable(profiles, func(i, j int) bool {
+		return len(profiles[i].data) > len(profiles[j].data)
+	})
+	size := bat.size()
+	dropped := make(map[*profile]bool)
+	for _, prof := range profiles {
+		if size <= max {
+			break
+		}
+		size -= len(prof.data)
+		dropped[prof] = true
+		tags := append(p.cfg.tags.Slice(), "reason:"+dropReasonTooLarge, "profile_name:"+prof.name)
+		p.cfg.statsd.Count("datadog.profiling.go.profile_dropped", 1, tags, 1)
+		log.Warn("Dropping %s profile of %d bytes from profile batch with profile_seq:%d: max upload size is %d bytes", prof.name, len(prof.data), bat.seq, max)
+	}
+	kept := bat.profiles[:0:0]
+	for _, prof := range bat.profiles {
+		if !dropped[prof] {
+			kept = append(kept, prof)
+		}
+	}
+	bat.profiles = kept
+	if len(kept) == 0 {
+		p.dropBatch(*bat, dropReasonTooLarge)
+		return false
+	}
+	return true
 }
 
 // retriableError is an error returned by the server which may be retried at a later time.
@@ -6,14 +6,19 @@
 package profiler
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -175,6 +180,191 @@ func TestContainerIDHeader(t *testing.T) {
 	assert.Equal(t, containerID, profile.headers.Get("Datadog-Container-Id"))
 }
 
+// countingStatsd is a StatsdClient recording the counts it receives, keyed
+// by metric name and tags.
+type countingStatsd struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *countingStatsd) Count(event string, times int64, tags []string, _ float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "reason:") {
+			event += "|" + tag
+		}
+	}
+	c.counts[event] += times
+	return nil
+}
+
+func (c *countingStatsd) Timing(_ string, _ time.Duration, _ []string, _ float64) error {
+	return nil
+}
+
+func (c *countingStatsd) count(event string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[event]
+}
+
+func TestUploadRetention(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		fail = true
+		seqs []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		form, err := multipart.NewReader(r.Body, params["boundary"]).ReadForm(1 << 20)
+		require.NoError(t, err)
+		f, err := form.File["event"][0].Open()
+		require.NoError(t, err)
+		var event uploadEvent
+		require.NoError(t, json.NewDecoder(f).Decode(&event))
+		for _, tag := range strings.Split(event.Tags, ",") {
+			if strings.HasPrefix(tag, "profile_seq:") {
+				seqs = append(seqs, tag)
+			}
+		}
+	}))
+	defer server.Close()
+
+	stats := &countingStatsd{}
+	p, err := unstartedProfiler(
+		WithAgentAddr(server.Listener.Addr().String()),
+		WithPeriod(time.Millisecond),
+		WithStatsd(stats),
+	)
+	require.NoError(t, err)
+
+	for seq := uint64(0); seq < maxRetainedBatches+1; seq++ {
+		bat := testBatch
+		bat.seq = seq
+		require.Error(t, p.upload(bat))
+	}
+	assert.Len(t, p.retained, maxRetainedBatches)
+	assert.EqualValues(t, 1, stats.count("datadog.profiling.go.upload_dropped|reason:"+dropReasonRetentionFull))
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	bat := testBatch
+	bat.seq = 10
+	require.NoError(t, p.upload(bat))
+	assert.Empty(t, p.retained)
+	assert.Equal(t, []string{"profile_seq:10", "profile_seq:1", "profile_seq:2"}, seqs)
+}
+
+func TestUploadStopped(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	stats := &countingStatsd{}
+	p, err := unstartedProfiler(
+		WithAgentAddr(server.Listener.Addr().String()),
+		WithPeriod(time.Millisecond),
+		WithStatsd(stats),
+	)
+	require.NoError(t, err)
+	require.Error(t, p.upload(testBatch))
+	require.Len(t, p.retained, 1)
+
+	close(p.exit)
+	sent := atomic.LoadInt32(&requests)
+	assert.Equal(t, errProfilerStopped, p.upload(testBatch))
+	assert.Equal(t, sent, atomic.LoadInt32(&requests), "no upload should be attempted once stopped")
+	assert.Len(t, p.retained, 1)
+	assert.EqualValues(t, 1, stats.count("datadog.profiling.go.upload_dropped|reason:"+dropReasonStopped))
+
+	// the retained batches are dropped when the sender returns
+	p.send()
+	assert.Empty(t, p.retained)
+	assert.EqualValues(t, 2, stats.count("datadog.profiling.go.upload_dropped|reason:"+dropReasonStopped))
+}
+
+func TestUploadStoppedCatchingUp(t *testing.T) {
+	var (
+		requests int32
+		up       int32
+		p        *profiler
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// stop the profiler while the batch which ends the outage is uploaded
+		close(p.exit)
+	}))
+	defer server.Close()
+
+	stats := &countingStatsd{}
+	p, err := unstartedProfiler(
+		WithAgentAddr(server.Listener.Addr().String()),
+		WithPeriod(time.Millisecond),
+		WithStatsd(stats),
+	)
+	require.NoError(t, err)
+	require.Error(t, p.upload(testBatch))
+	require.Len(t, p.retained, 1)
+
+	atomic.StoreInt32(&up, 1)
+	sent := atomic.LoadInt32(&requests)
+	assert.Equal(t, errProfilerStopped, p.upload(testBatch))
+	assert.Equal(t, sent+1, atomic.LoadInt32(&requests), "retained batches should not be uploaded once stopped")
+	assert.Len(t, p.retained, 1)
+	assert.EqualValues(t, 0, stats.count("datadog.profiling.go.upload_dropped|reason:"+dropReasonUploadError))
+}
+
+func TestUploadBackoff(t *testing.T) {
+	p, err := unstartedProfiler(WithPeriod(10 * time.Second))
+	require.NoError(t, err)
+	for attempt := 0; attempt < 10; attempt++ {
+		d := p.backoff(attempt)
+		assert.Greater(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, uploadBackoffBase<<uint(attempt))
+		assert.LessOrEqual(t, d, 10*time.Second)
+	}
+}
+
+func TestMaxUploadSize(t *testing.T) {
+	stats := &countingStatsd{}
+	p, err := unstartedProfiler(WithMaxUploadSize(30), WithStatsd(stats))
+	require.NoError(t, err)
+
+	bat := batch{profiles: []*profile{
+		{name: "cpu.pprof", data: make([]byte, 20)},
+		{name: "delta-heap.pprof", data: make([]byte, 25)},
+		{name: "metrics.json", data: make([]byte, 5)},
+	}}
+	require.True(t, p.limitBatchSize(&bat))
+	require.Len(t, bat.profiles, 2)
+	assert.Equal(t, "cpu.pprof", bat.profiles[0].name)
+	assert.Equal(t, "metrics.json", bat.profiles[1].name)
+	assert.EqualValues(t, 1, stats.count("datadog.profiling.go.profile_dropped|reason:"+dropReasonTooLarge))
+
+	bat = batch{profiles: []*profile{{name: "cpu.pprof", data: make([]byte, 31)}}}
+	require.Error(t, p.upload(bat))
+	assert.EqualValues(t, 1, stats.count("datadog.profiling.go.upload_dropped|reason:"+dropReasonTooLarge))
+}
+
 func BenchmarkDoRequest(b *testing.B) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		_, err := io.ReadAll(req.Body)